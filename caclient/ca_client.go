@@ -0,0 +1,180 @@
+// Package caclient is a client for revocation transparency CAs found in the CA list
+package caclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/utils"
+
+	ctca "github.com/n-ct/ct-certificate-authority"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+
+	// Most bytes of an error response body kept in an RspError
+	maxErrorBodySize = 1024
+)
+
+// CAClient represents a client for a given revocation transparency CA instance
+type CAClient struct {
+	httpClient *http.Client
+	CAInfo entitylist.CAInfo	// Struct that contains the json data about the CA found in ca_list.json
+}
+
+// Create a CAClient instance to access the CA at the URL found in its CAInfo
+func NewCAClient(ca *entitylist.CAInfo) (*CAClient, error) {
+	if ca.CAURL == "" {
+		return nil, fmt.Errorf("error creating new CAClient for CA %s: no URL in CA list", ca.CAID)
+	}
+	return &CAClient{&http.Client{Timeout: defaultRequestTimeout}, *ca}, nil
+}
+
+// Ask the CA to revoke the given percent of totalCerts and produce the SRDWithRevData for its new CRV
+// The SRDWithRevData is verified to be signed by the CA before it is encapsulated within a CTObject
+func (c *CAClient) RevokeAndProduceSRD(ctx context.Context, percentRevoked uint8, totalCerts uint64) (*mtr.CTObject, error) {
+	revAndProdSRDReq := ctca.RevokeAndProduceSRDRequest{
+		PercentRevoked: percentRevoked,
+		TotalCerts: totalCerts,
+	}
+	var srd mtr.SRDWithRevData
+	if err := c.do(ctx, "GET", ctca.RevokeAndProduceSRDPath, &revAndProdSRDReq, &srd); err != nil {
+		return nil, fmt.Errorf("failed to get SRDWithRevData from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	srdCT, err := c.constructSRDCTObject(&srd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SRDWithRevData from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	glog.Infof("Received SRDWithRevData from CA %s", c.CAInfo.CAID)
+	return srdCT, nil
+}
+
+// Get the latest SRD of the CA from its revocation status, verified to be signed by the CA
+func (c *CAClient) GetLatestSRD(ctx context.Context) (*mtr.CTObject, error) {
+	var revStatus ctca.RevocationStatus
+	if err := c.do(ctx, "GET", ctca.GetRevocationStatusPath, nil, &revStatus); err != nil {
+		return nil, fmt.Errorf("failed to get latest SRD from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	caSRD := &revStatus.CASRD
+	if caSRD.TypeID != mtr.SRDWithRevDataTypeID {
+		return nil, fmt.Errorf("failed to get latest SRD from CA %s: got %s CTObject", c.CAInfo.CAID, caSRD.TypeID)
+	}
+	srd, err := caSRD.DeconstructSRD()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest SRD from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	revData, err := caSRD.DeconstructRevData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest SRD from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	srdCT, err := c.constructSRDCTObject(&mtr.SRDWithRevData{RevData: *revData, SRD: *srd})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest SRD from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	return srdCT, nil
+}
+
+// Get the SRDWithRevData the CA signed at the given timestamp
+func (c *CAClient) GetSRD(ctx context.Context, timestamp uint64) (*mtr.CTObject, error) {
+	var srd mtr.SRDWithRevData
+	path := fmt.Sprintf("%s?timestamp=%d", mtr.GetSRDPath, timestamp)
+	if err := c.do(ctx, "GET", path, nil, &srd); err != nil {
+		return nil, fmt.Errorf("failed to get SRD at timestamp %d from CA %s: %w", timestamp, c.CAInfo.CAID, err)
+	}
+	if srd.SRD.RevDigest.Timestamp != timestamp {
+		return nil, fmt.Errorf("failed to get SRD at timestamp %d from CA %s: got SRD at timestamp %d", timestamp, c.CAInfo.CAID, srd.SRD.RevDigest.Timestamp)
+	}
+	srdCT, err := c.constructSRDCTObject(&srd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SRD at timestamp %d from CA %s: %w", timestamp, c.CAInfo.CAID, err)
+	}
+	return srdCT, nil
+}
+
+// Get the SRDWithRevData of every MMD of the CA from the start to the end timestamp inclusive, ordered by timestamp
+// The RevData of each holds the CRVDelta of its MMD, so together they catch a CRV up from the SRD before start
+func (c *CAClient) GetRevData(ctx context.Context, start uint64, end uint64) ([]*mtr.CTObject, error) {
+	if start > end {
+		return nil, fmt.Errorf("failed to get RevData from CA %s: start timestamp %d is after end timestamp %d", c.CAInfo.CAID, start, end)
+	}
+	var revDataRsp mtr.GetRevDataResponse
+	path := fmt.Sprintf("%s?start=%d&end=%d", mtr.GetRevDataPath, start, end)
+	if err := c.do(ctx, "GET", path, nil, &revDataRsp); err != nil {
+		return nil, fmt.Errorf("failed to get RevData from CA %s: %w", c.CAInfo.CAID, err)
+	}
+	var srdCTs []*mtr.CTObject
+	prevTimestamp := start
+	for i := range revDataRsp.SRDs {
+		srd := &revDataRsp.SRDs[i]
+		timestamp := srd.SRD.RevDigest.Timestamp
+		if timestamp < prevTimestamp || timestamp > end || (i > 0 && timestamp == prevTimestamp) {
+			return nil, fmt.Errorf("failed to get RevData from CA %s: SRD at timestamp %d is out of order or outside timestamps %d to %d", c.CAInfo.CAID, timestamp, start, end)
+		}
+		srdCT, err := c.constructSRDCTObject(srd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get RevData from CA %s: %w", c.CAInfo.CAID, err)
+		}
+		srdCTs = append(srdCTs, srdCT)
+		prevTimestamp = timestamp
+	}
+	return srdCTs, nil
+}
+
+// Check that the SRDWithRevData was signed by the CA and encapsulate it within a CTObject
+// Whether the RevDigest matches the CRV isn't checked here, as a mismatch is evidence against the CA rather than a bad response
+func (c *CAClient) constructSRDCTObject(srd *mtr.SRDWithRevData) (*mtr.CTObject, error) {
+	if srd.SRD.EntityID != c.CAInfo.CAID {
+		return nil, fmt.Errorf("SRD is from CA %s", srd.SRD.EntityID)
+	}
+	if srd.RevData.EntityID != srd.SRD.EntityID || srd.RevData.Timestamp != srd.SRD.RevDigest.Timestamp {
+		return nil, fmt.Errorf("RevData of CA %s at timestamp %d doesn't belong to SRD at timestamp %d", srd.RevData.EntityID, srd.RevData.Timestamp, srd.SRD.RevDigest.Timestamp)
+	}
+	if err := mtr.VerifySRDSignature(&srd.SRD, c.CAInfo.CAKey); err != nil {
+		return nil, err
+	}
+	return mtr.ConstructCTObject(srd)
+}
+
+// Send a request with the JSON encoded body to the endpoint of the CA and decode the JSON response into rsp
+// Non-2xx responses are returned as an RspError
+func (c *CAClient) do(ctx context.Context, method string, path string, body interface{}, rsp interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBytes)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, utils.CreateRequestURL(c.CAInfo.CAURL, path), reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	httpRsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpRsp.Body.Close()
+	if httpRsp.StatusCode < 200 || httpRsp.StatusCode >= 300 {
+		rspBody, _ := ioutil.ReadAll(io.LimitReader(httpRsp.Body, maxErrorBodySize))
+		return mtr.RspError{Err: fmt.Errorf("got HTTP Status %q", httpRsp.Status), StatusCode: httpRsp.StatusCode, Body: rspBody}
+	}
+	if err := json.NewDecoder(httpRsp.Body).Decode(rsp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
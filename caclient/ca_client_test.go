@@ -0,0 +1,191 @@
+package caclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/testutil/fakeca"
+)
+
+func mustCreateCAClient(t *testing.T, caInfo *entitylist.CAInfo) *CAClient {
+	t.Helper()
+	caClient, err := NewCAClient(caInfo)
+	if err != nil {
+		t.Fatalf("failed to create CAClient: %v", err)
+	}
+	return caClient
+}
+
+func TestRevokeAndProduceSRD(t *testing.T) {
+	fakeCA := fakeca.New(t)
+	caClient := mustCreateCAClient(t, fakeCA.CAInfo())
+	srdCT, err := caClient.RevokeAndProduceSRD(context.Background(), 10, 100)
+	if err != nil {
+		t.Fatalf("failed to revoke and produce SRD: %v", err)
+	}
+	if srdCT.TypeID != mtr.SRDWithRevDataTypeID || srdCT.Signer != fakeCA.CAID() {
+		t.Fatalf("got %s CTObject signed by %s, want %s CTObject signed by %s", srdCT.TypeID, srdCT.Signer, mtr.SRDWithRevDataTypeID, fakeCA.CAID())
+	}
+	caSRDCT := fakeCA.SRD(srdCT.Timestamp)
+	if caSRDCT == nil || !bytes.Equal(srdCT.Digest, caSRDCT.Digest) {
+		t.Fatalf("SRDWithRevData at timestamp %d doesn't match the SRD produced by the CA", srdCT.Timestamp)
+	}
+}
+
+func TestRevokeAndProduceSRDRejectsOtherCA(t *testing.T) {
+	fakeCA := fakeca.New(t)
+	otherCA := fakeca.New(t)
+
+	// The CA list has the key of another CA for the CA at this URL
+	caInfo := otherCA.CAInfo()
+	caInfo.CAURL = fakeCA.URL()
+	if _, err := mustCreateCAClient(t, caInfo).RevokeAndProduceSRD(context.Background(), 10, 100); err == nil {
+		t.Fatalf("SRDWithRevData from another CA was accepted")
+	}
+
+	// Same CAID, but the SRD isn't signed with the key in the CA list
+	caInfo = fakeCA.CAInfo()
+	caInfo.CAKey = otherCA.Key()
+	if _, err := mustCreateCAClient(t, caInfo).RevokeAndProduceSRD(context.Background(), 10, 100); err == nil {
+		t.Fatalf("SRDWithRevData with bad signature was accepted")
+	}
+}
+
+func TestRevokeAndProduceSRDErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "failed to produce SRDWithRevData", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	caClient := mustCreateCAClient(t, &entitylist.CAInfo{CAID: "ca", CAURL: server.URL})
+	_, err := caClient.RevokeAndProduceSRD(context.Background(), 10, 100)
+	var rspErr mtr.RspError
+	if !errors.As(err, &rspErr) || rspErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got error %v, want RspError with status %d", err, http.StatusInternalServerError)
+	}
+}
+
+func TestRevokeAndProduceSRDTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	caClient := mustCreateCAClient(t, &entitylist.CAInfo{CAID: "ca", CAURL: server.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+	defer cancel()
+	if _, err := caClient.RevokeAndProduceSRD(ctx, 10, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestNewCAClientRequiresURL(t *testing.T) {
+	if _, err := NewCAClient(&entitylist.CAInfo{CAID: "ca"}); err == nil {
+		t.Fatalf("created CAClient for CA without URL")
+	}
+}
+
+// Produce numSRDs SRDs with the fake CA, returning their SRDWithRevData CTObjects in order
+func mustProduceSRDs(t *testing.T, fakeCA *fakeca.CA, numSRDs int) []*mtr.CTObject {
+	t.Helper()
+	var srdCTs []*mtr.CTObject
+	for i := 0; i < numSRDs; i++ {
+		fakeCA.Revoke(uint64(i))
+		srdCT, err := fakeCA.ProduceSRD()
+		if err != nil {
+			t.Fatalf("failed to produce SRD: %v", err)
+		}
+		srdCTs = append(srdCTs, srdCT)
+	}
+	return srdCTs
+}
+
+func TestGetLatestSRD(t *testing.T) {
+	fakeCA := fakeca.New(t)
+	caClient := mustCreateCAClient(t, fakeCA.CAInfo())
+
+	// A CA that hasn't produced an SRD has none to serve
+	_, err := caClient.GetLatestSRD(context.Background())
+	var rspErr mtr.RspError
+	if !errors.As(err, &rspErr) || rspErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("got error %v, want RspError with status %d", err, http.StatusNotFound)
+	}
+
+	srdCTs := mustProduceSRDs(t, fakeCA, 2)
+	latestCT, err := caClient.GetLatestSRD(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get latest SRD: %v", err)
+	}
+	if latestCT.TypeID != mtr.SRDWithRevDataTypeID || !bytes.Equal(latestCT.Digest, srdCTs[1].Digest) {
+		t.Fatalf("latest SRD at timestamp %d doesn't match the SRD produced by the CA at timestamp %d", latestCT.Timestamp, srdCTs[1].Timestamp)
+	}
+}
+
+func TestGetSRD(t *testing.T) {
+	fakeCA := fakeca.New(t)
+	caClient := mustCreateCAClient(t, fakeCA.CAInfo())
+	srdCTs := mustProduceSRDs(t, fakeCA, 2)
+	srdCT, err := caClient.GetSRD(context.Background(), srdCTs[0].Timestamp)
+	if err != nil {
+		t.Fatalf("failed to get SRD: %v", err)
+	}
+	if !bytes.Equal(srdCT.Digest, srdCTs[0].Digest) {
+		t.Fatalf("SRD at timestamp %d doesn't match the SRD produced by the CA", srdCT.Timestamp)
+	}
+	if _, err := caClient.GetSRD(context.Background(), srdCTs[1].Timestamp + 1); err == nil {
+		t.Fatalf("got SRD at timestamp the CA has no SRD at")
+	}
+}
+
+func TestGetRevData(t *testing.T) {
+	fakeCA := fakeca.New(t)
+	caClient := mustCreateCAClient(t, fakeCA.CAInfo())
+	srdCTs := mustProduceSRDs(t, fakeCA, 4)
+	gotCTs, err := caClient.GetRevData(context.Background(), srdCTs[1].Timestamp, srdCTs[2].Timestamp)
+	if err != nil {
+		t.Fatalf("failed to get RevData: %v", err)
+	}
+	if len(gotCTs) != 2 {
+		t.Fatalf("got %d SRDs, want 2", len(gotCTs))
+	}
+	for i, gotCT := range gotCTs {
+		if !bytes.Equal(gotCT.Digest, srdCTs[i + 1].Digest) {
+			t.Fatalf("SRD %d at timestamp %d doesn't match the SRD produced by the CA", i, gotCT.Timestamp)
+		}
+	}
+	if _, err := caClient.GetRevData(context.Background(), srdCTs[2].Timestamp, srdCTs[1].Timestamp); err == nil {
+		t.Fatalf("got RevData with start timestamp after end timestamp")
+	}
+}
+
+func TestGetRevDataRejectsUnrequestedSRDs(t *testing.T) {
+	fakeCA := fakeca.New(t)
+	srdCTs := mustProduceSRDs(t, fakeCA, 2)
+	srd, err := srdCTs[0].DeconstructSRD()
+	if err != nil {
+		t.Fatalf("failed to deconstruct SRD: %v", err)
+	}
+	revData, err := srdCTs[0].DeconstructRevData()
+	if err != nil {
+		t.Fatalf("failed to deconstruct RevData: %v", err)
+	}
+
+	// The CA answers with an SRD from before the requested timestamps
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(rw).Encode(&mtr.GetRevDataResponse{SRDs: []mtr.SRDWithRevData{{RevData: *revData, SRD: *srd}}})
+	}))
+	defer server.Close()
+	caInfo := fakeCA.CAInfo()
+	caInfo.CAURL = server.URL
+	if _, err := mustCreateCAClient(t, caInfo).GetRevData(context.Background(), srdCTs[1].Timestamp, srdCTs[1].Timestamp); err == nil {
+		t.Fatalf("accepted SRD outside the requested timestamps")
+	}
+}
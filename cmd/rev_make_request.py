@@ -2,7 +2,7 @@ import requests
 
 url = 'http://localhost:5000/ct/v1/srd-with-revdata-gossip'
 params = {
-    'LogId':"eMj/JnboS5r42I9T4Iq3uRIXRn15EQUbYtAcDMMYT84=",
+    'CAID':"LeYXK29QzQV9RxvgMw+hnOeyZV85A6a5quOLltev9H0=",
     'PercentRevoked': 10,
     'TotalCerts': 100,
 }
@@ -12,7 +12,6 @@ import (
 
 	"github.com/golang/glog"
 
-	mtr "github.com/n-ct/ct-monitor"
 	"github.com/n-ct/ct-monitor/monitor"
 	"github.com/n-ct/ct-monitor/handler"
 )
@@ -21,6 +20,7 @@ var (
 	monitorConfigName = flag.String("config", "monitor/monitor_config.json", "File containing Monitor configuration")
 	monitorListName = flag.String("monitorlist", "entitylist/monitor_list.json", "File containing MonitorList")
 	logListName = flag.String("loglist", "entitylist/log_list.json", "File containing LogList")
+	caListName = flag.String("calist", "entitylist/ca_list.json", "File containing CAList")
 )
 
 func main(){
@@ -32,7 +32,7 @@ func main(){
 	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	// Initalize the variables of the Monitor
-	monitorInstance, err := monitor.NewMonitor(*monitorConfigName, *monitorListName, *logListName)
+	monitorInstance, err := monitor.NewMonitor(*monitorConfigName, *monitorListName, *logListName, *caListName)
 	if err != nil {
 		fmt.Println("failed to create monitor: %w", err)	// Only for testing purposes
 		glog.Fatalf("Couldn't create monitor: %v", err)
@@ -48,10 +48,21 @@ func main(){
 	server := serverSetup(monitorInstance)
 	glog.Infoln("Created monitor http.Server")
 
+	// Start fetching STHs from the monitored logs every MMD
+	scheduleCtx, stopScheduler := context.WithCancel(context.Background())
+	scheduler := monitor.NewScheduler(monitorInstance)
+	go scheduler.Run(scheduleCtx)
+	glog.Infoln("Started monitor Scheduler")
+
+	// Deliver gossiped CTObjects to the gossiper in the background
+	go monitorInstance.Gossiper.Run(scheduleCtx)
+	glog.Infoln("Started monitor Gossiper")
+
 	// Handling the stop signal and closing things 
 	<-stop
 	glog.Infoln("Received stop signal")
-	shutdownServer(server, 0)
+	stopScheduler()
+	shutdownServer(server, monitorInstance, 0)
 }
 
 // Sets up the basic monitor http server
@@ -75,14 +86,7 @@ func serverSetup(m *monitor.Monitor) *http.Server{
 
 // Sets up the handler and the various path handle functions
 func handlerSetup(m *monitor.Monitor) (*http.ServeMux) {
-	handler := handler.NewHandler(m)
-	serveMux := http.NewServeMux()
-	serveMux.HandleFunc(mtr.AuditPath, handler.Audit)
-	serveMux.HandleFunc(mtr.NewInfoPath, handler.NewInfo)
-	serveMux.HandleFunc(mtr.MonitorDomainPath, handler.MonitorDomain)
-	serveMux.HandleFunc(mtr.STHGossipPath, handler.STHGossip)
-	serveMux.HandleFunc(mtr.STHWithPOCGossipPath, handler.STHWithPOCGossip)
-	serveMux.HandleFunc(mtr.SRDWithRevDataGossipPath, handler.SRDWithRevDataGossip)
+	serveMux := handler.NewServeMux(m)
 
 	// Return a 200 on the root so clients can easily check if server is up
 	serveMux.HandleFunc("/", func(resp http.ResponseWriter, req *http.Request) {
@@ -95,10 +99,14 @@ func handlerSetup(m *monitor.Monitor) (*http.ServeMux) {
 	return serveMux
 }
 
-// Shuts down the Monitor Server instance
-func shutdownServer(server *http.Server, returnCode int){
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+// Shuts down the Monitor Server instance and then closes the Monitor
+func shutdownServer(server *http.Server, m *monitor.Monitor, returnCode int){
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	server.Shutdown(ctx)
+	cancel()
+	if err := m.Close(); err != nil {
+		glog.Errorf("failed to close monitor storage: %v", err)
+	}
 	glog.Infoln("Shutting down Server")
 	glog.Flush()
 	os.Exit(returnCode)
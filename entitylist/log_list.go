@@ -71,6 +71,9 @@ type LogInfo struct {
 	MMDEnd *ClockTime `json:"mmd_end"`
 	// MMDAccessDelay indicates the amount of seconds that one can access the daily object after MMDEnd
 	MMDAccessDelay uint32 `json:"mmd_access_delay"`
+	// MaxGetEntries is the largest number of entries the log serves in a single get-entries response.
+	// Zero means the log list doesn't state a limit
+	MaxGetEntries uint64 `json:"max_get_entries,omitempty"`
 }
 
 // ClockTime has the UTC hour, minute, and second corresponding to a specific time in a day
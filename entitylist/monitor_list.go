@@ -98,4 +98,13 @@ func (ml *MonitorList) FindMonitorByMonitorURL(monitorURL string) *MonitorInfo {
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// Monitors returns the MonitorInfo of every Monitor in the list
+func (ml *MonitorList) Monitors() []*MonitorInfo {
+	var monitors []*MonitorInfo
+	for _, op := range ml.MonitorOperators {
+		monitors = append(monitors, op.Monitors...)
+	}
+	return monitors
+}
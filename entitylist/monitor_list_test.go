@@ -45,4 +45,17 @@ func TestFindMonitorByMonitorURL(t *testing.T) {
 	if monitorInfo.MonitorKey != testMonitorIDKey {
 		t.Fatalf("received wrong monitor from testMonitorIDURL (%s): %v", testMonitorIDURL,  monitorInfo)
 	}
-}
\ No newline at end of file
+}
+
+func TestMonitors(t *testing.T) {
+	monitorList, _ := mustCreateMonitorList(t)
+	monitors := monitorList.Monitors()
+	if len(monitors) == 0 {
+		t.Fatalf("no monitors found in %s", monitorListPath)
+	}
+	for _, monitorInfo := range monitors {
+		if monitorList.FindMonitorByMonitorID(monitorInfo.MonitorID) != monitorInfo {
+			t.Fatalf("monitor (%v) not found by its MonitorID", monitorInfo)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/n-ct/ct-monitor
 go 1.15
 
 require (
+	github.com/Workiva/go-datastructures v1.0.53
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/google/certificate-transparency-go v1.1.1
 	github.com/n-ct/ct-certificate-authority v0.0.0-20210408003514-086e14235d37
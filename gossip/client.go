@@ -0,0 +1,321 @@
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+const (
+	GossipPath = "/ct/v1/gossip"
+
+	// Headers identifying the monitor that sent a CTObject along with its signature over the RequestSignedFields of the request.
+	// The timestamp is in milliseconds since the epoch and the signature is a base64 encoded TLS DigitallySigned
+	MonitorIDHeader = "X-CT-Monitor-ID"
+	SignatureHeader = "X-CT-Monitor-Signature"
+	TimestampHeader = "X-CT-Monitor-Timestamp"
+
+	// Most a signed request's timestamp may differ from the receiver's clock, limiting how long a captured request can be replayed
+	DefaultMaxRequestAge = 5 * time.Minute
+
+	// Failed attempts in a row to deliver a CTObject before it waits for the next round of deliveries
+	defaultMaxAttempts = 5
+
+	// Backoff between attempts, doubling after each attempt up to the max
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff = 30 * time.Second
+
+	// Time between rounds of deliveries while CTObjects are pending
+	defaultRetryInterval = time.Minute
+
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// Gossiper delivers the CTObjects gossiped by the monitor to its peers
+type Gossiper interface {
+	// Queue the CTObject to be delivered by Run
+	Gossip(ctObject *mtr.CTObject) error
+	// Get the delivery status of the CTObject with the given identifier
+	Status(id mtr.ObjectIdentifier) DeliveryStatus
+	// Deliver queued CTObjects until ctx is canceled
+	Run(ctx context.Context)
+}
+
+// Client gossips CTObjects to the monitor's gossiper
+// CTObjects are queued by Gossip and delivered in order by Run, which retries each one with its own backoff until the gossiper acknowledges it
+type Client struct {
+	url string
+	monitorID string
+	signer *signature.Signer
+	queue *Queue
+	httpClient *http.Client
+	maxAttempts int
+	initialBackoff time.Duration
+	maxBackoff time.Duration
+	retryInterval time.Duration
+	wake chan struct{}
+	slots chan struct{}	// Limits the requests in flight across Clients sharing it. nil doesn't limit requests
+}
+
+// Create a new Client that gossips CTObjects signed by the monitor to the gossiper at gossiperURL
+func NewClient(gossiperURL string, monitorID string, signer *signature.Signer, queue *Queue) *Client {
+	return newClient(utils.CreateRequestURL(gossiperURL, GossipPath), monitorID, signer, queue)
+}
+
+// Create a new Client that posts CTObjects signed by the monitor to the given endpoint
+func newClient(url string, monitorID string, signer *signature.Signer, queue *Queue) *Client {
+	return &Client{
+		url: url,
+		monitorID: monitorID,
+		signer: signer,
+		queue: queue,
+		httpClient: &http.Client{Timeout: defaultRequestTimeout},
+		maxAttempts: defaultMaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff: defaultMaxBackoff,
+		retryInterval: defaultRetryInterval,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Queue the CTObject to be gossiped. CTObjects that are already pending or delivered aren't gossiped again
+func (c *Client) Gossip(ctObject *mtr.CTObject) error {
+	added, err := c.queue.Add(ctObject)
+	if err != nil {
+		return fmt.Errorf("failed to gossip: %w", err)
+	}
+	if added {
+		glog.V(1).Infof("queued %s CTObject for gossip", ctObject.TypeID)
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Get the delivery status of the CTObject with the given identifier
+func (c *Client) Status(id mtr.ObjectIdentifier) DeliveryStatus {
+	delivery := c.queue.Get(id)
+	if delivery == nil {
+		return StatusUnknown
+	}
+	return delivery.Status
+}
+
+// Deliver queued CTObjects until ctx is canceled
+func (c *Client) Run(ctx context.Context) {
+	for {
+		wait := c.retryInterval
+		if retryAt := c.deliverPending(ctx); !retryAt.IsZero() {
+			if untilRetry := time.Until(retryAt); untilRetry < wait {
+				wait = untilRetry
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Attempt to deliver each pending CTObject that isn't backing off from a failed attempt, in order
+// A CTObject the gossiper fails to acknowledge backs off on its own without holding up the CTObjects after it
+// Returns the earliest time a pending CTObject can be attempted again, or the zero time if none is pending
+func (c *Client) deliverPending(ctx context.Context) time.Time {
+	var nextRetry time.Time
+	for _, delivery := range c.queue.Pending() {
+		if ctx.Err() != nil {
+			return nextRetry
+		}
+		retryAt := delivery.RetryAt
+		if !time.Now().Before(retryAt) {
+			var err error
+			retryAt, err = c.deliver(ctx, &delivery)
+			if err != nil {
+				glog.Warningf("failed to gossip %s CTObject on attempt %d: %v", delivery.CTObject.TypeID, delivery.Attempts + 1, err)
+			}
+		}
+		if !retryAt.IsZero() && (nextRetry.IsZero() || retryAt.Before(nextRetry)) {
+			nextRetry = retryAt
+		}
+	}
+	return nextRetry
+}
+
+// Send the CTObject of the pending Delivery to the gossiper once, finishing the Delivery if it is acknowledged or rejected
+// A failed attempt is recorded along with the time of the next attempt, which backs off like backoff
+// Returns the time of the next attempt, or the zero time if the Delivery finished
+func (c *Client) deliver(ctx context.Context, delivery *Delivery) (time.Time, error) {
+	ctObject := &delivery.CTObject
+	id := ctObject.Identifier()
+	retryAfter, err := c.send(ctx, ctObject)
+	var rejectErr *RejectedError
+	if err == nil {
+		return time.Time{}, c.queue.finish(id, StatusDelivered, nil)
+	}
+	if errors.As(err, &rejectErr) {
+		glog.Errorf("gossiper rejected %s CTObject: %v", ctObject.TypeID, err)
+		return time.Time{}, c.queue.finish(id, StatusRejected, err)
+	}
+
+	// Wait at least as long as the gossiper asked before the next attempt
+	wait := c.backoff(delivery.Attempts + 1)
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	retryAt := time.Now().Add(wait)
+	c.queue.recordFailure(id, err, retryAt)
+	return retryAt, err
+}
+
+// Get the wait before the next attempt to deliver a CTObject after the given number of failed attempts
+// The wait doubles after each failed attempt up to the max. After maxAttempts failed attempts in a row
+// the CTObject waits for the next round of deliveries instead
+func (c *Client) backoff(failedAttempts int) time.Duration {
+	attempt := failedAttempts % c.maxAttempts
+	if attempt == 0 {
+		return c.retryInterval
+	}
+	backoff := c.initialBackoff
+	for i := 1; i < attempt && backoff < c.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.maxBackoff {
+		backoff = c.maxBackoff
+	}
+	return backoff
+}
+
+// Error returned when the gossiper refuses a CTObject in a way that retrying won't fix
+type RejectedError struct {
+	StatusCode int
+	Body string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("gossiper responded %d: %s", e.StatusCode, e.Body)
+}
+
+// Make a single post request to the gossiper with the signed CTObject
+// Returns the time the gossiper asked to wait before retrying along with the error of a failed attempt
+func (c *Client) send(ctx context.Context, ctObject *mtr.CTObject) (time.Duration, error) {
+	jsonBytes, err := json.Marshal(ctObject)
+	if err != nil {
+		return 0, &RejectedError{Body: fmt.Sprintf("failed to marshal %s CTObject: %v", ctObject.TypeID, err)}
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create gossip request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.sign(req, ctObject); err != nil {
+		return 0, err
+	}
+
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		defer func() { <-c.slots }()
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send %s CTObject to gossiper: %w", ctObject.TypeID, err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		retryAfter := time.Duration(0)
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return retryAfter, fmt.Errorf("gossiper responded %d: %s", resp.StatusCode, body)
+	default:
+		return 0, &RejectedError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+}
+
+// Add the monitor's ID and its signature over the CTObject to the request headers
+func (c *Client) sign(req *http.Request, ctObject *mtr.CTObject) error {
+	return SignRequest(req, c.monitorID, c.signer, ctObject)
+}
+
+// Fields signed by a monitor sending a CTObject, serialized as JSON like other signed monitor data
+// Gossipers relaying the CTObject must pass on all three headers unchanged for the receiving monitor to authenticate it
+type RequestSignedFields struct {
+	Timestamp 	uint64	// Value of the TimestampHeader
+	CTObject 	mtr.CTObject
+}
+
+// Add the ID of the sending monitor, the current time and its signature over both and the CTObject to the headers of a request carrying the CTObject
+func SignRequest(req *http.Request, monitorID string, signer *signature.Signer, ctObject *mtr.CTObject) error {
+	timestamp := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	sig, err := signer.CreateSignature(tls.SHA256, RequestSignedFields{Timestamp: timestamp, CTObject: *ctObject})
+	if err != nil {
+		return fmt.Errorf("failed to sign %s CTObject for gossip: %w", ctObject.TypeID, err)
+	}
+	b64Sig, err := sig.Base64String()
+	if err != nil {
+		return fmt.Errorf("failed to encode signature of %s CTObject for gossip: %w", ctObject.TypeID, err)
+	}
+	req.Header.Set(MonitorIDHeader, monitorID)
+	req.Header.Set(SignatureHeader, b64Sig)
+	req.Header.Set(TimestampHeader, strconv.FormatUint(timestamp, 10))
+	return nil
+}
+
+// Verify the signature in the headers of a request carrying the CTObject against the key of the sending monitor in the monitor list
+// Requests signed more than maxAge away from the current time are refused so they can't be replayed later
+// Returns the MonitorID of the sending monitor
+func VerifyRequest(req *http.Request, monitorList *entitylist.MonitorList, ctObject *mtr.CTObject, maxAge time.Duration) (string, error) {
+	monitorID := req.Header.Get(MonitorIDHeader)
+	b64Sig := req.Header.Get(SignatureHeader)
+	strTimestamp := req.Header.Get(TimestampHeader)
+	if monitorID == "" || b64Sig == "" || strTimestamp == "" {
+		return "", fmt.Errorf("request is missing the %s, %s and %s headers of a peer monitor", MonitorIDHeader, SignatureHeader, TimestampHeader)
+	}
+	timestamp, err := strconv.ParseUint(strTimestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp header from monitor %s: %w", monitorID, err)
+	}
+	age := time.Since(time.Unix(0, int64(timestamp) * int64(time.Millisecond)))
+	if age > maxAge || age < -maxAge {
+		return "", fmt.Errorf("request from monitor %s was signed %v ago, outside the %v allowed", monitorID, age, maxAge)
+	}
+	monitorInfo := monitorList.FindMonitorByMonitorID(monitorID)
+	if monitorInfo == nil {
+		return "", fmt.Errorf("MonitorID (%s) not found in monitor list", monitorID)
+	}
+	var sig ct.DigitallySigned
+	if err := sig.FromBase64String(b64Sig); err != nil {
+		return "", fmt.Errorf("invalid signature header from monitor %s: %w", monitorID, err)
+	}
+	if err := signature.VerifySignature(monitorInfo.MonitorKey, RequestSignedFields{Timestamp: timestamp, CTObject: *ctObject}, sig); err != nil {
+		return "", fmt.Errorf("invalid signature from monitor %s over %s CTObject: %w", monitorID, ctObject.TypeID, err)
+	}
+	return monitorID, nil
+}
@@ -0,0 +1,295 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/signature"
+)
+
+var (
+	testMonitorID = "LeYXK29QzQV9RxvgMw+hnOeyZV85A6a5quOLltev9H0="
+	testMonitorKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEmFk6QT48Ts4oxSkBPM4mQ/mnWICKVmZUP6urQVBH0vhDzJVYHc2ShvF2KjWzorVu2C+tY6lIU+61iiPLsGvZXw=="
+	testPrivKey = "MHcCAQEEIOWK47/9gxKjcpTe8UhL4PyXZS1lPcnqChRvlw/Jpnh0oAoGCCqGSM49AwEHoUQDQgAEmFk6QT48Ts4oxSkBPM4mQ/mnWICKVmZUP6urQVBH0vhDzJVYHc2ShvF2KjWzorVu2C+tY6lIU+61iiPLsGvZXw=="
+)
+
+// Gossiper that responds with the given status codes in turn, and 200 once they run out
+type testGossiper struct {
+	mu sync.Mutex
+	statusCodes []int
+	unavailable map[string]bool	// Subjects of CTObjects the gossiper always responds 503 to
+	received []*mtr.CTObject	// CTObjects the gossiper acknowledged
+	server *httptest.Server
+}
+
+func mustCreateGossiper(t *testing.T, statusCodes ...int) *testGossiper {
+	t.Helper()
+	g := &testGossiper{statusCodes: statusCodes}
+	g.server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		var ctObject mtr.CTObject
+		if req.URL.Path != GossipPath || json.NewDecoder(req.Body).Decode(&ctObject) != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if g.unavailable[ctObject.Subject] {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if len(g.statusCodes) > 0 {
+			statusCode := g.statusCodes[0]
+			g.statusCodes = g.statusCodes[1:]
+			if statusCode != http.StatusOK {
+				rw.WriteHeader(statusCode)
+				return
+			}
+		}
+
+		// Only acknowledge CTObjects signed by the monitor
+		var sig ct.DigitallySigned
+		if err := sig.FromBase64String(req.Header.Get(SignatureHeader)); err != nil || req.Header.Get(MonitorIDHeader) != testMonitorID {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		timestamp, err := strconv.ParseUint(req.Header.Get(TimestampHeader), 10, 64)
+		if err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := signature.VerifySignature(testMonitorKey, RequestSignedFields{Timestamp: timestamp, CTObject: ctObject}, sig); err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		g.received = append(g.received, &ctObject)
+	}))
+	t.Cleanup(g.server.Close)
+	return g
+}
+
+func (g *testGossiper) numReceived() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.received)
+}
+
+func mustCreateSigner(t *testing.T) *signature.Signer {
+	t.Helper()
+	signer, err := signature.NewSigner(testPrivKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer
+}
+
+// Create a Client for the gossiper that retries quickly
+func mustCreateClient(t *testing.T, gossiperURL string, queue *Queue) *Client {
+	t.Helper()
+	client := NewClient(gossiperURL, testMonitorID, mustCreateSigner(t), queue)
+	client.maxAttempts = 3
+	client.initialBackoff = time.Millisecond
+	client.maxBackoff = time.Millisecond
+	client.retryInterval = 10 * time.Millisecond
+	return client
+}
+
+func mustCreateQueue(t *testing.T, path string) *Queue {
+	t.Helper()
+	queue, err := NewQueue(path)
+	if err != nil {
+		t.Fatalf("failed to create gossip queue: %v", err)
+	}
+	return queue
+}
+
+// Create an Alert CTObject about the given log
+func createTestCTObject(logID string) *mtr.CTObject {
+	return &mtr.CTObject{
+		TypeID: mtr.AlertTypeID,
+		Version: mtr.VersionData{Major: 1},
+		Timestamp: 1000,
+		Signer: testMonitorID,
+		Subject: logID,
+		Blob: []byte(logID),
+	}
+}
+
+// Run the Client until every queued CTObject has left the Queue or the timeout expires
+func runUntilEmpty(t *testing.T, client *Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
+	for len(client.queue.Pending()) > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestGossipRetriesUntilDelivered(t *testing.T) {
+	gossiper := mustCreateGossiper(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusTooManyRequests)
+	client := mustCreateClient(t, gossiper.server.URL, mustCreateQueue(t, ""))
+	first, second := createTestCTObject("log1"), createTestCTObject("log2")
+	client.Gossip(first)
+	client.Gossip(first)
+
+	runUntilEmpty(t, client)
+	if gossiper.numReceived() != 1 {
+		t.Fatalf("gossiper received %d CTObjects, expected the CTObject once", gossiper.numReceived())
+	}
+	delivery := client.queue.Get(first.Identifier())
+	// The first round of deliveries gives up after 3 attempts and the next round delivers it on attempt 5
+	if delivery.Status != StatusDelivered || delivery.Attempts != 5 {
+		t.Fatalf("first CTObject is %s after %d attempts, expected it to be delivered on attempt 5", delivery.Status, delivery.Attempts)
+	}
+
+	client.Gossip(second)
+	runUntilEmpty(t, client)
+	if gossiper.numReceived() != 2 || gossiper.received[1].Subject != "log2" {
+		t.Fatalf("second CTObject wasn't delivered after the first")
+	}
+	if status := client.Status(second.Identifier()); status != StatusDelivered {
+		t.Fatalf("second CTObject is %s, expected it to be delivered", status)
+	}
+	if status := client.Status(createTestCTObject("log3").Identifier()); status != StatusUnknown {
+		t.Fatalf("CTObject that was never gossiped is %s", status)
+	}
+}
+
+func TestGossipDeliversPastFailingCTObject(t *testing.T) {
+	gossiper := mustCreateGossiper(t)
+	gossiper.unavailable = map[string]bool{"log1": true}
+	client := mustCreateClient(t, gossiper.server.URL, mustCreateQueue(t, ""))
+	failing, accepted := createTestCTObject("log1"), createTestCTObject("log2")
+	client.Gossip(failing)
+	client.Gossip(accepted)
+
+	// The CTObject the gossiper keeps failing backs off without holding up the CTObject after it
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(done)
+	}()
+	for client.Status(accepted.Identifier()) != StatusDelivered && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if status := client.Status(accepted.Identifier()); status != StatusDelivered {
+		t.Fatalf("CTObject after failing CTObject is %s, expected it to be delivered", status)
+	}
+	if delivery := client.queue.Get(failing.Identifier()); delivery.Status != StatusPending || delivery.Attempts == 0 {
+		t.Fatalf("failing CTObject is %s after %d attempts, expected it to stay pending", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestGossipRejected(t *testing.T) {
+	gossiper := mustCreateGossiper(t, http.StatusBadRequest)
+	client := mustCreateClient(t, gossiper.server.URL, mustCreateQueue(t, ""))
+	rejected, accepted := createTestCTObject("log1"), createTestCTObject("log2")
+	client.Gossip(rejected)
+	client.Gossip(accepted)
+
+	runUntilEmpty(t, client)
+	delivery := client.queue.Get(rejected.Identifier())
+	if delivery.Status != StatusRejected || delivery.Attempts != 1 {
+		t.Fatalf("CTObject refused by gossiper is %s after %d attempts, expected it to be rejected without retrying", delivery.Status, delivery.Attempts)
+	}
+	if status := client.Status(accepted.Identifier()); status != StatusDelivered {
+		t.Fatalf("CTObject after rejected CTObject is %s, expected it to be delivered", status)
+	}
+}
+
+func TestGossipQueuePersistsWhileGossiperDown(t *testing.T) {
+	gossiper := mustCreateGossiper(t)
+	gossiperURL := gossiper.server.URL
+	gossiper.server.Close()
+
+	queuePath := filepath.Join(t.TempDir(), "gossip_queue")
+	client := mustCreateClient(t, gossiperURL, mustCreateQueue(t, queuePath))
+	ctObject := createTestCTObject("log1")
+	client.Gossip(ctObject)
+	ctx, cancel := context.WithCancel(context.Background())
+	for client.queue.Pending()[0].Attempts < 3 {
+		client.deliverPending(ctx)
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	pending := client.queue.Pending()
+	if len(pending) != 1 || pending[0].Attempts != 3 || pending[0].LastError == "" {
+		t.Fatalf("pending deliveries (%v) should hold the CTObject after 3 failed attempts", pending)
+	}
+
+	// A restarted monitor delivers the CTObject once the gossiper is back
+	gossiper = mustCreateGossiper(t)
+	client = mustCreateClient(t, gossiper.server.URL, mustCreateQueue(t, queuePath))
+	if pending := client.queue.Pending(); len(pending) != 1 || pending[0].CTObject.Subject != "log1" {
+		t.Fatalf("restarted monitor lost the pending CTObject: %v", pending)
+	}
+	runUntilEmpty(t, client)
+	if gossiper.numReceived() != 1 {
+		t.Fatalf("gossiper received %d CTObjects after restart, expected 1", gossiper.numReceived())
+	}
+	queue := mustCreateQueue(t, queuePath)
+	if pending := queue.Pending(); len(pending) != 0 {
+		t.Fatalf("delivered CTObject is still pending in queue file")
+	}
+	if delivery := client.queue.Get(ctObject.Identifier()); delivery == nil || delivery.Status != StatusDelivered {
+		t.Fatalf("queue lost the delivery of the CTObject: %v", delivery)
+	}
+
+	// Finished deliveries are only kept in memory
+	if delivery := queue.Get(ctObject.Identifier()); delivery != nil {
+		t.Fatalf("queue file holds the finished delivery of the CTObject: %v", delivery)
+	}
+}
+
+func TestGossipQueueEvictsOldestFinished(t *testing.T) {
+	gossiper := mustCreateGossiper(t)
+	queuePath := filepath.Join(t.TempDir(), "gossip_queue")
+	queue := mustCreateQueue(t, queuePath)
+	queue.maxFinished = 2
+	client := mustCreateClient(t, gossiper.server.URL, queue)
+	var ctObjects []*mtr.CTObject
+	for _, logID := range []string{"log1", "log2", "log3"} {
+		ctObject := createTestCTObject(logID)
+		ctObjects = append(ctObjects, ctObject)
+		client.Gossip(ctObject)
+	}
+	runUntilEmpty(t, client)
+
+	// Only the 2 latest deliveries are kept
+	if delivery := queue.Get(ctObjects[0].Identifier()); delivery != nil {
+		t.Fatalf("oldest delivery wasn't evicted: %v", delivery)
+	}
+	for _, ctObject := range ctObjects[1:] {
+		if delivery := queue.Get(ctObject.Identifier()); delivery == nil || delivery.Status != StatusDelivered {
+			t.Fatalf("latest delivery of %s isn't kept: %v", ctObject.Subject, delivery)
+		}
+	}
+
+	// None of them are written to the queue file
+	reloaded := mustCreateQueue(t, queuePath)
+	for _, ctObject := range ctObjects {
+		if delivery := reloaded.Get(ctObject.Identifier()); delivery != nil {
+			t.Fatalf("queue file holds the finished delivery of %s: %v", ctObject.Subject, delivery)
+		}
+	}
+}
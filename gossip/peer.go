@@ -0,0 +1,131 @@
+package gossip
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+// Most identifiers of gossiped CTObjects a PeerGossiper remembers before forgetting the least recently gossiped
+const defaultMaxSeen = 10000
+
+// PeerGossiper gossips CTObjects directly to the new-info endpoint of every other monitor in the monitor list
+// so monitors can gossip without a separate gossiper. Each peer has its own Client and Queue
+type PeerGossiper struct {
+	mu sync.Mutex
+	peers []*Client
+	seen map[mtr.ObjectIdentifier]*list.Element	// CTObjects already queued for every peer
+	seenOrder *list.List	// Identifiers in seen from the most to the least recently gossiped
+	maxSeen int
+}
+
+// Create a PeerGossiper for the monitor with the given monitorID that has at most concurrency requests to peers in flight at once
+// Every CTObject still goes to every peer. A concurrency of 0 doesn't limit the requests in flight.
+// When queuePath isn't empty each peer's Queue persists to queuePath followed by the peer's ID
+func NewPeerGossiper(monitorList *entitylist.MonitorList, monitorID string, signer *signature.Signer, queuePath string, concurrency int) (*PeerGossiper, error) {
+	var slots chan struct{}
+	if concurrency > 0 {
+		slots = make(chan struct{}, concurrency)
+	}
+	p := &PeerGossiper{seen: make(map[mtr.ObjectIdentifier]*list.Element), seenOrder: list.New(), maxSeen: defaultMaxSeen}
+	for _, monitorInfo := range monitorList.Monitors() {
+		if monitorInfo.MonitorID == monitorID {
+			continue
+		}
+		peerQueuePath := ""
+		if queuePath != "" {
+			peerQueuePath = queuePath + "." + peerFileSuffix(monitorInfo.MonitorID)
+		}
+		queue, err := NewQueue(peerQueuePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gossip queue of peer monitor %s: %w", monitorInfo.MonitorID, err)
+		}
+		client := newClient(utils.CreateRequestURL(peerURL(monitorInfo.MonitorURL), mtr.NewInfoPath), monitorID, signer, queue)
+		client.slots = slots
+		p.peers = append(p.peers, client)
+	}
+	return p, nil
+}
+
+// Queue the CTObject to be delivered to every peer. CTObjects with an identifier that was recently gossiped are dropped
+// unless a peer rejected it, in which case it is queued again for the peers that didn't acknowledge it
+func (p *PeerGossiper) Gossip(ctObject *mtr.CTObject) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := ctObject.Identifier()
+	if elem, ok := p.seen[id]; ok {
+		if p.Status(id) != StatusRejected {
+			glog.V(1).Infof("dropping %s CTObject already gossiped to peers", ctObject.TypeID)
+			p.seenOrder.MoveToFront(elem)
+			return nil
+		}
+		p.seenOrder.Remove(elem)
+		delete(p.seen, id)
+	}
+	for _, peer := range p.peers {
+		if err := peer.Gossip(ctObject); err != nil {
+			return fmt.Errorf("failed to gossip to peer at %s: %w", peer.url, err)
+		}
+	}
+	p.seen[id] = p.seenOrder.PushFront(id)
+	if p.seenOrder.Len() > p.maxSeen {
+		oldest := p.seenOrder.Back()
+		p.seenOrder.Remove(oldest)
+		delete(p.seen, oldest.Value.(mtr.ObjectIdentifier))
+	}
+	return nil
+}
+
+// Get the delivery status of the CTObject across the peers
+// The CTObject is pending while any peer hasn't acknowledged it, and rejected if any peer refused it
+func (p *PeerGossiper) Status(id mtr.ObjectIdentifier) DeliveryStatus {
+	status := StatusUnknown
+	for _, peer := range p.peers {
+		switch peer.Status(id) {
+		case StatusPending:
+			return StatusPending
+		case StatusRejected:
+			status = StatusRejected
+		case StatusDelivered:
+			if status == StatusUnknown {
+				status = StatusDelivered
+			}
+		}
+	}
+	return status
+}
+
+// Deliver queued CTObjects to every peer until ctx is canceled
+func (p *PeerGossiper) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, peer := range p.peers {
+		wg.Add(1)
+		go func(peer *Client) {
+			defer wg.Done()
+			peer.Run(ctx)
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// Get the URL of a peer monitor, defaulting to http for monitor list URLs without a scheme
+func peerURL(monitorURL string) string {
+	if strings.Contains(monitorURL, "://") {
+		return monitorURL
+	}
+	return "http://" + monitorURL
+}
+
+// Get a file name safe suffix from the base64 encoded ID of a peer
+func peerFileSuffix(peerID string) string {
+	return strings.NewReplacer("/", "_", "+", "-", "=", "").Replace(peerID)
+}
@@ -0,0 +1,154 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+)
+
+// Peer monitors that record the CTObjects posted to their new-info endpoints and the most requests in flight across them
+type testPeers struct {
+	mu sync.Mutex
+	received map[string][]string	// Subjects of the CTObjects received, keyed by peer MonitorID
+	rejecting map[string]bool	// Subjects of the CTObjects the peers refuse
+	inFlight int32
+	maxInFlight int32
+}
+
+// Create a monitor list holding the monitor and numPeers peer monitors
+func mustCreatePeers(t *testing.T, numPeers int) (*testPeers, *entitylist.MonitorList) {
+	t.Helper()
+	peers := &testPeers{received: make(map[string][]string), rejecting: make(map[string]bool)}
+	monitors := []*entitylist.MonitorInfo{{MonitorID: testMonitorID, MonitorURL: "http://localhost:1"}}
+	for i := 0; i < numPeers; i++ {
+		peerID := string(rune('a' + i))
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			inFlight := atomic.AddInt32(&peers.inFlight, 1)
+			defer atomic.AddInt32(&peers.inFlight, -1)
+			peers.mu.Lock()
+			if inFlight > peers.maxInFlight {
+				peers.maxInFlight = inFlight
+			}
+			peers.mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+
+			var ctObject mtr.CTObject
+			if req.URL.Path != mtr.NewInfoPath || req.Header.Get(MonitorIDHeader) != testMonitorID || json.NewDecoder(req.Body).Decode(&ctObject) != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			peers.mu.Lock()
+			if peers.rejecting[ctObject.Subject] {
+				peers.mu.Unlock()
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			peers.received[peerID] = append(peers.received[peerID], ctObject.Subject)
+			peers.mu.Unlock()
+		}))
+		t.Cleanup(server.Close)
+		monitors = append(monitors, &entitylist.MonitorInfo{MonitorID: peerID, MonitorURL: server.URL})
+	}
+	monitorList := &entitylist.MonitorList{MonitorOperators: []*entitylist.MonitorOperator{{Monitors: monitors}}}
+	return peers, monitorList
+}
+
+func TestPeerGossiper(t *testing.T) {
+	peers, monitorList := mustCreatePeers(t, 3)
+	peerGossiper, err := NewPeerGossiper(monitorList, testMonitorID, mustCreateSigner(t), "", 1)
+	if err != nil {
+		t.Fatalf("failed to create peer gossiper: %v", err)
+	}
+	if len(peerGossiper.peers) != 3 {
+		t.Fatalf("peer gossiper has %d peers, expected every monitor but itself", len(peerGossiper.peers))
+	}
+	first, second := createTestCTObject("log1"), createTestCTObject("log2")
+	peerGossiper.Gossip(first)
+	peerGossiper.Gossip(second)
+	peerGossiper.Gossip(first)
+	if status := peerGossiper.Status(first.Identifier()); status != StatusPending {
+		t.Fatalf("CTObject is %s before delivery, expected it to be pending", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+	go peerGossiper.Run(ctx)
+	for peerGossiper.Status(first.Identifier()) != StatusDelivered || peerGossiper.Status(second.Identifier()) != StatusDelivered {
+		if ctx.Err() != nil {
+			t.Fatalf("CTObjects weren't delivered to every peer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	peers.mu.Lock()
+	defer peers.mu.Unlock()
+	for peerID, subjects := range peers.received {
+		if len(subjects) != 2 || subjects[0] != "log1" || subjects[1] != "log2" {
+			t.Fatalf("peer %s received %v, expected each CTObject once in order", peerID, subjects)
+		}
+	}
+	if len(peers.received) != 3 {
+		t.Fatalf("%d peers received CTObjects, expected 3", len(peers.received))
+	}
+	if peers.maxInFlight != 1 {
+		t.Fatalf("%d requests were in flight at once with a concurrency of 1", peers.maxInFlight)
+	}
+}
+
+// Run the PeerGossiper until the CTObject with the given identifier has the status or the timeout expires
+func waitForPeerStatus(t *testing.T, peerGossiper *PeerGossiper, id mtr.ObjectIdentifier, status DeliveryStatus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		peerGossiper.Run(ctx)
+		close(done)
+	}()
+	for peerGossiper.Status(id) != status {
+		if ctx.Err() != nil {
+			t.Fatalf("CTObject is %s, expected it to be %s", peerGossiper.Status(id), status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestPeerGossiperForgetsRejectedAndOldCTObjects(t *testing.T) {
+	peers, monitorList := mustCreatePeers(t, 2)
+	peerGossiper, err := NewPeerGossiper(monitorList, testMonitorID, mustCreateSigner(t), "", 0)
+	if err != nil {
+		t.Fatalf("failed to create peer gossiper: %v", err)
+	}
+	peerGossiper.maxSeen = 2
+
+	// A CTObject the peers rejected is gossiped again once they accept it
+	rejected := createTestCTObject("log1")
+	peers.mu.Lock()
+	peers.rejecting["log1"] = true
+	peers.mu.Unlock()
+	peerGossiper.Gossip(rejected)
+	waitForPeerStatus(t, peerGossiper, rejected.Identifier(), StatusRejected)
+	peers.mu.Lock()
+	peers.rejecting["log1"] = false
+	peers.mu.Unlock()
+	peerGossiper.Gossip(rejected)
+	waitForPeerStatus(t, peerGossiper, rejected.Identifier(), StatusDelivered)
+
+	// Only the most recently gossiped identifiers are remembered
+	for _, logID := range []string{"log2", "log3"} {
+		peerGossiper.Gossip(createTestCTObject(logID))
+	}
+	if len(peerGossiper.seen) != 2 || peerGossiper.seen[rejected.Identifier()] != nil {
+		t.Fatalf("peer gossiper remembers %d CTObjects, expected only the 2 latest", len(peerGossiper.seen))
+	}
+}
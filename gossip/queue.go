@@ -0,0 +1,194 @@
+package gossip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+// Status of the delivery of a CTObject to the gossiper
+type DeliveryStatus string
+
+const (
+	StatusUnknown 	DeliveryStatus = ""				// The CTObject was never gossiped
+	StatusPending 	DeliveryStatus = "PENDING"		// Waiting to be acknowledged by the gossiper
+	StatusDelivered DeliveryStatus = "DELIVERED"	// Acknowledged by the gossiper
+	StatusRejected 	DeliveryStatus = "REJECTED"		// Refused by the gossiper and won't be retried
+)
+
+// Delivery tracks the gossip of a single CTObject
+type Delivery struct {
+	CTObject 	mtr.CTObject
+	Status 		DeliveryStatus
+	Attempts 	int		// Number of times the CTObject was sent to the gossiper
+	LastError 	string	// Error of the latest failed attempt
+	RetryAt 	time.Time	// Earliest time of the next attempt after a failed attempt
+}
+
+// Most finished Deliveries a Queue keeps before evicting the oldest
+const defaultMaxFinished = 1000
+
+// Queue holds the CTObjects waiting to be gossiped along with the Delivery of the CTObjects gossiped by the monitor
+// Only the latest maxFinished delivered or rejected Deliveries are kept, so an evicted CTObject can be gossiped again
+// When created with a path, the pending Deliveries are written to the file whenever CTObjects are added or finished
+// so they survive restarts. Finished Deliveries are only kept in memory
+type Queue struct {
+	mu sync.Mutex
+	path string
+	deliveries map[mtr.ObjectIdentifier]*Delivery
+	pending []mtr.ObjectIdentifier	// Pending Deliveries in the order they were added
+	finished []mtr.ObjectIdentifier	// Delivered and rejected Deliveries in the order they finished
+	maxFinished int
+}
+
+// Create a Queue persisted to the file at path, loading the pending Deliveries in the file if it exists
+// An empty path creates a Queue kept only in memory
+func NewQueue(path string) (*Queue, error) {
+	q := &Queue{path: path, deliveries: make(map[mtr.ObjectIdentifier]*Delivery), maxFinished: defaultMaxFinished}
+	if path == "" {
+		return q, nil
+	}
+	byteData, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening gossip queue file %s: %w", path, err)
+	}
+	var deliveries []*Delivery
+	if err := json.Unmarshal(byteData, &deliveries); err != nil {
+		return nil, fmt.Errorf("error loading gossip queue file %s: %w", path, err)
+	}
+	for _, delivery := range deliveries {
+		// Files written before finished Deliveries were kept only in memory hold them too
+		if delivery.Status != StatusPending {
+			continue
+		}
+		id := delivery.CTObject.Identifier()
+		q.deliveries[id] = delivery
+		q.pending = append(q.pending, id)
+	}
+	return q, nil
+}
+
+// Add the CTObject to the end of the Queue
+// Returns false without adding it if the same CTObject is already pending or was delivered
+func (q *Queue) Add(ctObject *mtr.CTObject) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := ctObject.Identifier()
+	prev, ok := q.deliveries[id]
+	if ok && prev.Status != StatusRejected {
+		return false, nil
+	}
+	q.deliveries[id] = &Delivery{CTObject: *ctObject, Status: StatusPending}
+	q.pending = append(q.pending, id)
+	if err := q.save(); err != nil {
+		q.pending = q.pending[:len(q.pending) - 1]
+		if ok {
+			q.deliveries[id] = prev
+		} else {
+			delete(q.deliveries, id)
+		}
+		return false, fmt.Errorf("failed to queue %s CTObject: %w", ctObject.TypeID, err)
+	}
+	if ok {
+		q.finished = removeIdentifier(q.finished, id)
+	}
+	return true, nil
+}
+
+// Get copies of the pending Deliveries in the order they were added
+func (q *Queue) Pending() []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := make([]Delivery, len(q.pending))
+	for i, id := range q.pending {
+		pending[i] = *q.deliveries[id]
+	}
+	return pending
+}
+
+// Get a copy of the Delivery of the CTObject with the given identifier. Returns nil if it was never gossiped
+func (q *Queue) Get(id mtr.ObjectIdentifier) *Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delivery, ok := q.deliveries[id]
+	if !ok {
+		return nil
+	}
+	deliveryCopy := *delivery
+	return &deliveryCopy
+}
+
+// Record a failed attempt to deliver the pending CTObject with the given identifier, which is next attempted at retryAt
+// Failed attempts aren't written to the Queue's file until CTObjects are next added or finished
+func (q *Queue) recordFailure(id mtr.ObjectIdentifier, err error, retryAt time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delivery, ok := q.deliveries[id]
+	if !ok {
+		return
+	}
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	delivery.RetryAt = retryAt
+}
+
+// Finish the pending Delivery of the CTObject with the given identifier with the given status
+// err is the reason a rejected CTObject was refused
+func (q *Queue) finish(id mtr.ObjectIdentifier, status DeliveryStatus, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delivery, ok := q.deliveries[id]
+	if !ok || delivery.Status != StatusPending {
+		return nil
+	}
+	delivery.Attempts++
+	delivery.Status = status
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	q.pending = removeIdentifier(q.pending, id)
+	q.finished = append(q.finished, id)
+	for len(q.finished) > q.maxFinished {
+		delete(q.deliveries, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+	if err := q.save(); err != nil {
+		return fmt.Errorf("failed to remove %s CTObject from gossip queue: %w", delivery.CTObject.TypeID, err)
+	}
+	return nil
+}
+
+// Write the pending Deliveries to the Queue's file in order. Must be called with the lock held
+func (q *Queue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	deliveries := make([]*Delivery, 0, len(q.pending))
+	for _, id := range q.pending {
+		deliveries = append(deliveries, q.deliveries[id])
+	}
+	byteData, err := json.Marshal(deliveries)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(q.path, byteData)
+}
+
+// Remove the first occurrence of id from ids, returning a new slice
+func removeIdentifier(ids []mtr.ObjectIdentifier, id mtr.ObjectIdentifier) []mtr.ObjectIdentifier {
+	for i, otherID := range ids {
+		if otherID == id {
+			return append(append([]mtr.ObjectIdentifier{}, ids[:i]...), ids[i+1:]...)
+		}
+	}
+	return ids
+}
@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/golang/glog"
 
 	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/gossip"
 	"github.com/n-ct/ct-monitor/monitor"
 	"github.com/n-ct/ct-monitor/utils"
+	"github.com/n-ct/ct-monitor/watchlist"
 )
 
 type Handler struct {
@@ -22,6 +25,24 @@ func NewHandler(m *monitor.Monitor) Handler {
 	return Handler{m}
 }
 
+// Create a ServeMux routing every endpoint of the monitor to a Handler for it
+func NewServeMux(m *monitor.Monitor) *http.ServeMux {
+	handler := NewHandler(m)
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc(mtr.AuditPath, handler.Audit)
+	serveMux.HandleFunc(mtr.InclusionAuditPath, handler.InclusionAudit)
+	serveMux.HandleFunc(mtr.NewInfoPath, handler.NewInfo)
+	serveMux.HandleFunc(mtr.MonitorDomainPath, handler.MonitorDomain)
+	serveMux.HandleFunc(mtr.RevocationStatusPath, handler.RevocationStatus)
+	serveMux.HandleFunc(mtr.ObjectsPath, handler.Objects)
+	serveMux.HandleFunc(mtr.ObjectPath, handler.Object)
+	serveMux.HandleFunc(mtr.StreamPath, handler.Stream)
+	serveMux.HandleFunc(mtr.STHGossipPath, handler.STHGossip)
+	serveMux.HandleFunc(mtr.STHWithPOCGossipPath, handler.STHWithPOCGossip)
+	serveMux.HandleFunc(mtr.SRDWithRevDataGossipPath, handler.SRDWithRevDataGossip)
+	return serveMux
+}
+
 func writeWrongMethodResponse(rw *http.ResponseWriter, allowed string) {
 	(*rw).Header().Add("Allow", allowed)
 	(*rw).WriteHeader(http.StatusMethodNotAllowed)
@@ -52,6 +73,11 @@ func (h *Handler) Audit(rw http.ResponseWriter, req *http.Request){
 		return
 	}
 
+	if err := h.m.VerifyCTObject(&ctObject); err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Audit Request: %v", err))
+		return
+	}
+
 	// Get ctObject audit response. This can either be PoM CTObject or AuditOK CTObject
 	auditResp, err := h.m.Audit(&ctObject)
 	if err != nil {
@@ -63,7 +89,41 @@ func (h *Handler) Audit(rw http.ResponseWriter, req *http.Request){
 		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Couldn't encode Audit Response to return: %v", err))
 		return
 	}
-	rw.WriteHeader(http.StatusOK)
+}
+
+// Handle an inclusion audit request from a Relying Party
+func (h *Handler) InclusionAudit(rw http.ResponseWriter, req *http.Request){
+	glog.V(1).Infoln("Received InclusionAudit Request")
+	if req.Method != "POST" {
+		writeWrongMethodResponse(&rw, "POST")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	var auditReq mtr.InclusionAuditRequest
+	if err := decoder.Decode(&auditReq); err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid InclusionAudit Request: %v", err))
+		return
+	}
+
+	// Get InclusionAuditOK CTObject. The audit fails if the log can't prove inclusion
+	auditResp, err := h.m.AuditInclusion(req.Context(), &auditReq)
+	switch {
+	case errors.Is(err, monitor.ErrInvalidInclusionAuditRequest):
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid InclusionAudit Request: %v", err))
+		return
+	case errors.Is(err, monitor.ErrNotIncluded):
+		writeErrorResponse(&rw, http.StatusNotFound, fmt.Sprintf("Inclusion not proven: %v", err))
+		return
+	case err != nil:
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("failed to audit inclusion: %v", err))
+		return
+	}
+	encoder := json.NewEncoder(rw)
+	if err := encoder.Encode(*auditResp); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Couldn't encode InclusionAudit Response to return: %v", err))
+		return
+	}
 }
 
 // Handle receiving new data from another party
@@ -81,10 +141,82 @@ func (h *Handler) NewInfo(rw http.ResponseWriter, req *http.Request){
 		return
 	}
 
+	// Only accept new info from the monitors in the monitor list unless the monitor allows unauthenticated NewInfo
+	if !h.m.AllowUnauthenticatedNewInfo {
+		peerID, err := gossip.VerifyRequest(req, h.m.MonitorList, &ctObject, gossip.DefaultMaxRequestAge)
+		if err != nil {
+			glog.Warningf("rejected unauthenticated NewInfo %s CTObject: %v", ctObject.TypeID, err)
+			writeErrorResponse(&rw, http.StatusUnauthorized, fmt.Sprintf("Unauthenticated NewInfo Request: %v", err))
+			return
+		}
+		glog.V(1).Infof("NewInfo %s CTObject from monitor %s", ctObject.TypeID, peerID)
+	}
+
+	if err := h.m.VerifyCTObject(&ctObject); err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid NewInfo Request: %v", err))
+		return
+	}
+
+	// Don't store an STHWithPOC whose ConsistencyProof is broken. Store and gossip the PoM instead
+	if ctObject.TypeID == mtr.STHPOCTypeID {
+		pom, err := h.m.VerifySTHWithPOC(req.Context(), &ctObject)
+		if errors.Is(err, monitor.ErrInvalidConsistencyProof) {
+			writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid NewInfo Request: %v", err))
+			return
+		}
+		if err != nil {
+			glog.Warningf("unable to verify ConsistencyProof of NewInfo STHWithPOC: %v", err)
+		}
+		if pom != nil {
+			if err := h.m.AddEntry(pom); err != nil {
+				writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store PoM: %v", err))
+				return
+			}
+			h.m.Gossip(pom)
+			writeErrorResponse(&rw, http.StatusBadRequest, "Invalid NewInfo Request: ConsistencyProof failed verification")
+			return
+		}
+	}
+
+	// Don't store an SRDWithRevData whose digest doesn't match the CA's CRV. Store and gossip the PoM instead
+	if ctObject.TypeID == mtr.SRDWithRevDataTypeID {
+		pom, err := h.m.ProcessSRD(&ctObject)
+		if errors.Is(err, monitor.ErrInvalidRevData) {
+			writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid NewInfo Request: %v", err))
+			return
+		}
+		if err != nil {
+			glog.Warningf("unable to check CRV of NewInfo SRDWithRevData: %v", err)
+		}
+		if pom != nil {
+			if err := h.m.AddEntry(pom); err != nil {
+				writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store PoM: %v", err))
+				return
+			}
+			h.m.Gossip(pom)
+			writeErrorResponse(&rw, http.StatusBadRequest, "Invalid NewInfo Request: RevocationDigest doesn't match CRV")
+			return
+		}
+	}
+
+	// An STH proving its log misbehaved or an SRD proving its CA misbehaved is replaced by the PoM which AddEntry gossips
 	if err := h.m.AddEntry(&ctObject); err != nil {
+		var misbehavingLogErr *monitor.MisbehavingLogError
+		var misbehavingCAErr *monitor.MisbehavingCAError
+		if errors.As(err, &misbehavingLogErr) || errors.As(err, &misbehavingCAErr) {
+			writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid NewInfo Request: %v", err))
+			return
+		}
 		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store object: %v", err))
 		return
 	}
+
+	// Alerts from other monitors may complete a NonRespondingLogPOM
+	if ctObject.TypeID == mtr.AlertTypeID {
+		if _, err := h.m.ProcessAlert(&ctObject); err != nil {
+			glog.Errorf("failed to process Alert from monitor %s: %v", ctObject.Signer, err)
+		}
+	}
 	rw.WriteHeader(http.StatusOK)
 }
 
@@ -95,6 +227,61 @@ func (h *Handler) MonitorDomain(rw http.ResponseWriter, req *http.Request){
 		writeWrongMethodResponse(&rw, "POST")
 		return
 	}
+
+	decoder := json.NewDecoder(req.Body)
+	var domainReq mtr.MonitorDomainRequest
+	if err := decoder.Decode(&domainReq); err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid MonitorDomain Request: %v", err))
+		return
+	}
+	if _, err := watchlist.NormalizeDomain(domainReq.Domain); err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid MonitorDomain Request: %v", err))
+		return
+	}
+
+	// Get the matching entries found so far. New matches are found as the monitor scans new log entries
+	domainResp, err := h.m.MonitorDomain(domainReq.Domain)
+	if err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("failed to monitor domain: %v", err))
+		return
+	}
+	encoder := json.NewEncoder(rw)
+	if err := encoder.Encode(*domainResp); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Couldn't encode MonitorDomain Response to return: %v", err))
+		return
+	}
+}
+
+// Handle a request from a Relying Party for the revocation status of a certificate
+func (h *Handler) RevocationStatus(rw http.ResponseWriter, req *http.Request){
+	glog.V(1).Infoln("Received RevocationStatus Request")
+	if req.Method != "POST" {
+		writeWrongMethodResponse(&rw, "POST")
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	var statusReq mtr.RevocationStatusRequest
+	if err := decoder.Decode(&statusReq); err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid RevocationStatus Request: %v", err))
+		return
+	}
+	if h.m.CAList.FindCAByCAID(statusReq.CAID) == nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid RevocationStatus Request: CAID (%s) not found in CA list", statusReq.CAID))
+		return
+	}
+
+	// Get the revocation status along with the SRD CTObject the Relying Party can verify it with
+	statusResp, err := h.m.GetRevocationStatus(&statusReq)
+	if err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("failed to get revocation status: %v", err))
+		return
+	}
+	encoder := json.NewEncoder(rw)
+	if err := encoder.Encode(*statusResp); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Couldn't encode RevocationStatus Response to return: %v", err))
+		return
+	}
 }
 
 // Handle request to get an STH from a specific Logger and then to gossip to peers
@@ -120,6 +307,10 @@ func (h *Handler) STHGossip(rw http.ResponseWriter, req *http.Request) {
 		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Monitor failed to getSTH from logger with log-id (%v): %v", logID, err))
 		return
 	}
+	if err := h.m.AddEntry(sth); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store STH: %v", err))
+		return
+	}
 	h.m.Gossip(sth)
 	rw.WriteHeader(http.StatusOK)
 }
@@ -150,6 +341,29 @@ func (h *Handler) STHWithPOCGossip(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Gossip a PoM instead of the STHWithPOC if the ConsistencyProof is broken
+	pom, err := h.m.VerifySTHWithPOC(ctx, sth)
+	if errors.Is(err, monitor.ErrInvalidConsistencyProof) {
+		writeErrorResponse(&rw, http.StatusBadGateway, fmt.Sprintf("Monitor received an invalid STHWithPoC from logger with log-id (%v): %v", sthPOCGosReq.LogID, err))
+		return
+	}
+	if err != nil {
+		glog.Warningf("unable to verify ConsistencyProof of STHWithPOC from logger with log-id (%v): %v", sthPOCGosReq.LogID, err)
+	}
+	if pom != nil {
+		if err := h.m.AddEntry(pom); err != nil {
+			writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store PoM: %v", err))
+			return
+		}
+		h.m.Gossip(pom)
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := h.m.AddEntry(sth); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store STHWithPOC: %v", err))
+		return
+	}
+
 	// Log the size of the object
 	size, err := utils.GetSize(sth)
 	glog.Infof("Size of sth CTObject is %v: %v", size, err)
@@ -157,7 +371,7 @@ func (h *Handler) STHWithPOCGossip(rw http.ResponseWriter, req *http.Request) {
 	rw.WriteHeader(http.StatusOK)
 }
 
-// Handle request to get an SRD from a specific Logger and then to gossip to peers
+// Handle request to get an SRD from a specific CA and then to gossip to peers
 func (h *Handler) SRDWithRevDataGossip(rw http.ResponseWriter, req *http.Request) {
 	if req.Method != "GET" {
 		writeWrongMethodResponse(&rw, "GET")
@@ -170,14 +384,46 @@ func (h *Handler) SRDWithRevDataGossip(rw http.ResponseWriter, req *http.Request
 		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid SRDWithRevDataGossipRequest: %v", err))
 		return
 	}
-	logClient, ok := h.m.LogIDMap[srdGosReq.LogID]
-	if !ok {
-		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("SRDWithRevDataGossip request log-id param value invalid. %v log-id not found in Monitor's LogIDMap", srdGosReq.LogID))
+	if h.m.CAList.FindCAByCAID(srdGosReq.CAID) == nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid SRDWithRevDataGossipRequest: CAID (%s) not found in CA list", srdGosReq.CAID))
 		return
 	}
-	srdCTObj, err := h.m.GetSRDWithRevData(logClient.LogInfo.URL, &srdGosReq)
+	srdCTObj, err := h.m.GetSRDWithRevData(req.Context(), &srdGosReq)
 	if err != nil {
-		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Monitor failed to getSRDWithRevData from logger with log-id (%v): %v", srdGosReq.LogID, err))
+		writeErrorResponse(&rw, http.StatusBadGateway, fmt.Sprintf("Monitor failed to getSRDWithRevData from CA with ca-id (%v): %v", srdGosReq.CAID, err))
+		return
+	}
+
+	if err := h.m.VerifyCTObject(srdCTObj); err != nil {
+		writeErrorResponse(&rw, http.StatusBadGateway, fmt.Sprintf("Monitor received an invalid SRDWithRevData from CA with ca-id (%v): %v", srdGosReq.CAID, err))
+		return
+	}
+	// Gossip a PoM instead of the SRDWithRevData if its digest doesn't match the CA's CRV
+	pom, err := h.m.ProcessSRD(srdCTObj)
+	if errors.Is(err, monitor.ErrInvalidRevData) {
+		writeErrorResponse(&rw, http.StatusBadGateway, fmt.Sprintf("Monitor received an invalid SRDWithRevData from CA with ca-id (%v): %v", srdGosReq.CAID, err))
+		return
+	}
+	if err != nil {
+		glog.Warningf("unable to check CRV of SRDWithRevData from ca-id (%v): %v", srdGosReq.CAID, err)
+	}
+	if pom != nil {
+		if err := h.m.AddEntry(pom); err != nil {
+			writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store PoM: %v", err))
+			return
+		}
+		h.m.Gossip(pom)
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := h.m.AddEntry(srdCTObj); err != nil {
+		// The PoM of an SRD conflicting with the stored one was already gossiped by AddEntry
+		var misbehavingCAErr *monitor.MisbehavingCAError
+		if errors.As(err, &misbehavingCAErr) {
+			writeErrorResponse(&rw, http.StatusBadGateway, fmt.Sprintf("Monitor received a conflicting SRDWithRevData from CA with ca-id (%v): %v", srdGosReq.CAID, err))
+			return
+		}
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Unable to store SRDWithRevData: %v", err))
 		return
 	}
 
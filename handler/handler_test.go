@@ -0,0 +1,445 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/gossip"
+	"github.com/n-ct/ct-monitor/monitor"
+	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/storage"
+	"github.com/n-ct/ct-monitor/testutil/fakeca"
+)
+
+var (
+	monitorConfigName = "../monitor/monitor_config.json"
+	monitorListName = "../entitylist/monitor_list.json"
+	logListName = "../entitylist/log_list.json"
+	caListName = "../entitylist/ca_list.json"
+
+	testLogID = "9lyUL9F3MCIUVBgIMJRWjuNNExkzv98MLyALzE7xZOM="
+	testMonitorKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEmFk6QT48Ts4oxSkBPM4mQ/mnWICKVmZUP6urQVBH0vhDzJVYHc2ShvF2KjWzorVu2C+tY6lIU+61iiPLsGvZXw=="
+	// Key of a monitor that isn't in the monitor list
+	otherPrivKey = "MHcCAQEEIDMTSq99YDvC5TfMoY+0jt4ruExuMifqrjOisWBds1yNoAoGCCqGSM49AwEHoUQDQgAE2HQc8jcuoOj/H/4+HQItNBEolurr547rC5i4O61Wf0mxvV9anHz+kIcTy7n9hnStoK+WGkI3fF6k7l2IO3OiyA=="
+)
+
+// Create a monitor whose testLogID key is the monitor key so STHs signed by the monitor verify
+func mustGetMonitor(t *testing.T) *monitor.Monitor {
+	t.Helper()
+	m, err := monitor.NewMonitor(monitorConfigName, monitorListName, logListName, caListName)
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+	logClient, err := mtr.NewLogClient(&entitylist.LogInfo{LogID: testLogID, Key: testMonitorKey, URL: "http://localhost"})
+	if err != nil {
+		t.Fatalf("failed to create LogClient: %v", err)
+	}
+	m.LogIDMap[testLogID] = logClient
+	return m
+}
+
+// Create an STH CTObject for testLogID signed by the monitor
+func mustCreateSTH(t *testing.T, m *monitor.Monitor, timestamp uint64) *mtr.CTObject {
+	t.Helper()
+	treeHead := ct.TreeHeadSignature{
+		Version:       ct.V1,
+		SignatureType: ct.TreeHashSignatureType,
+		Timestamp:     timestamp,
+		TreeSize:      timestamp,
+	}
+	sig, err := m.Signer.CreateSignature(tls.SHA256, treeHead)
+	if err != nil {
+		t.Fatalf("failed to sign STH: %v", err)
+	}
+	sthCT, err := mtr.ConstructCTObject(&mtr.SignedTreeHeadData{LogID: testLogID, TreeHeadData: treeHead, Signature: *sig})
+	if err != nil {
+		t.Fatalf("failed to construct STH CTObject: %v", err)
+	}
+	return sthCT
+}
+
+func mustCreateServer(t *testing.T, m *monitor.Monitor) *httptest.Server {
+	t.Helper()
+	serveMux := NewServeMux(m)
+	server := httptest.NewServer(serveMux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postCTObject(t *testing.T, url string, ctObject *mtr.CTObject) *http.Response {
+	t.Helper()
+	return postSignedCTObject(t, url, ctObject, "", nil)
+}
+
+// Post the CTObject signed by signer as the monitor with the given monitorID like a peer monitor gossiping it
+// A nil signer posts the CTObject without signing it
+func postSignedCTObject(t *testing.T, url string, ctObject *mtr.CTObject, monitorID string, signer *signature.Signer) *http.Response {
+	t.Helper()
+	jsonBytes, err := json.Marshal(ctObject)
+	if err != nil {
+		t.Errorf("failed to marshal CTObject: %v", err)
+		return nil
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		t.Errorf("failed to create request to %s: %v", url, err)
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signer != nil {
+		if err := gossip.SignRequest(req, monitorID, signer, ctObject); err != nil {
+			t.Errorf("failed to sign request: %v", err)
+			return nil
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("failed to post CTObject to %s: %v", url, err)
+		return nil
+	}
+	return resp
+}
+
+// Post the CTObject to the monitor's NewInfo endpoint signed by the monitor, which is in its own monitor list
+func postNewInfo(t *testing.T, m *monitor.Monitor, url string, ctObject *mtr.CTObject) *http.Response {
+	t.Helper()
+	return postSignedCTObject(t, url + mtr.NewInfoPath, ctObject, m.MonitorID, m.Signer)
+}
+
+func TestNewInfoRejectsForgedSTH(t *testing.T) {
+	m, err := monitor.NewMonitor(monitorConfigName, monitorListName, logListName, caListName)
+	if err != nil {
+		t.Fatalf("failed to create monitor: %v", err)
+	}
+	server := mustCreateServer(t, m)
+	// Without replacing the log key the monitor signed STH is a forgery
+	sth := mustCreateSTH(t, m, 1)
+	resp := postNewInfo(t, m, server.URL, sth)
+	if resp == nil {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("forged STH got status %d, expected %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if m.GetEntry(sth.Identifier()) != nil {
+		t.Fatalf("forged STH was stored")
+	}
+}
+
+func TestNewInfoRejectsRelabelledSTH(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	if err := m.AddEntry(mustCreateSTH(t, m, 1)); err != nil {
+		t.Fatalf("failed to store STH: %v", err)
+	}
+	// A validly signed STH labelled with the timestamp of the stored STH mustn't pass for a split view
+	sth := mustCreateSTH(t, m, 2)
+	sth.Timestamp = 1
+	resp := postNewInfo(t, m, server.URL, sth)
+	if resp == nil {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("relabelled STH got status %d, expected %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if poms := m.Storage.GetEntries(mtr.ConflictingSTHPOMTypeID, testLogID); len(poms) != 0 {
+		t.Fatalf("relabelled STH stored %d ConflictingSTHPOMs", len(poms))
+	}
+}
+
+func TestNewInfoRejectsConflictingSRD(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	fakeCA := fakeca.New(t)
+	m.CAList.CAOperators = append(m.CAList.CAOperators, &entitylist.CAOperator{Name: "Fake", CAs: []*entitylist.CAInfo{fakeCA.CAInfo()}})
+	fakeCA.Equivocate(3)
+	fakeCA.Revoke(3)
+	srdCT, err := fakeCA.ProduceSRD()
+	if err != nil {
+		t.Fatalf("failed to produce SRD: %v", err)
+	}
+	if err := m.AddEntry(srdCT); err != nil {
+		t.Fatalf("failed to store SRD: %v", err)
+	}
+
+	resp := postNewInfo(t, m, server.URL, fakeCA.ForkedSRD(srdCT.Timestamp))
+	if resp == nil {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("conflicting SRD got status %d, expected %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if poms := m.Storage.GetEntries(mtr.ConflictingSRDPOMTypeID, fakeCA.CAID()); len(poms) != 1 {
+		t.Fatalf("conflicting SRD stored %d ConflictingSRDPOMs, expected 1", len(poms))
+	}
+	if storedCT := m.GetEntry(srdCT.Identifier()); storedCT == nil || !bytes.Equal(storedCT.Digest, srdCT.Digest) {
+		t.Fatalf("conflicting SRD replaced the stored SRD")
+	}
+}
+
+func TestNewInfoRejectsUnauthenticatedPeer(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	sth := mustCreateSTH(t, m, 1)
+	otherSigner, err := signature.NewSigner(otherPrivKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	tests := []struct {
+		name 		string
+		monitorID 	string
+		signer 		*signature.Signer
+	}{
+		{"unsigned", "", nil},
+		{"unlisted monitor", "bm90IGluIHRoZSBtb25pdG9yIGxpc3Q=", m.Signer},
+		{"wrong key", m.MonitorID, otherSigner},
+	}
+	for _, test := range tests {
+		resp := postSignedCTObject(t, server.URL+mtr.NewInfoPath, sth, test.monitorID, test.signer)
+		if resp == nil {
+			t.FailNow()
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("%s NewInfo got status %d, expected %d", test.name, resp.StatusCode, http.StatusUnauthorized)
+		}
+		if m.GetEntry(sth.Identifier()) != nil {
+			t.Fatalf("%s NewInfo STH was stored", test.name)
+		}
+	}
+
+	resp := postNewInfo(t, m, server.URL, sth)
+	if resp == nil {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("NewInfo signed by listed monitor got status %d, expected %d", resp.StatusCode, http.StatusOK)
+	}
+	if m.GetEntry(sth.Identifier()) == nil {
+		t.Fatalf("authenticated NewInfo STH wasn't stored")
+	}
+}
+
+func TestNewInfoRejectsReplayedRequest(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	sth := mustCreateSTH(t, m, 1)
+	jsonBytes, err := json.Marshal(sth)
+	if err != nil {
+		t.Fatalf("failed to marshal CTObject: %v", err)
+	}
+
+	// Signed requests captured long ago or with their timestamp changed aren't accepted
+	tests := []struct {
+		name 		string
+		timestamp 	uint64	// Timestamp the request is re-signed with
+		resign 		bool	// Otherwise only the timestamp header is changed
+	}{
+		{"replayed", uint64(time.Now().Add(-2 * gossip.DefaultMaxRequestAge).UnixNano() / int64(time.Millisecond)), true},
+		{"altered timestamp", 0, false},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("POST", server.URL + mtr.NewInfoPath, bytes.NewBuffer(jsonBytes))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if err := gossip.SignRequest(req, m.MonitorID, m.Signer, sth); err != nil {
+			t.Fatalf("failed to sign request: %v", err)
+		}
+		timestamp := test.timestamp
+		if !test.resign {
+			signedTimestamp, err := strconv.ParseUint(req.Header.Get(gossip.TimestampHeader), 10, 64)
+			if err != nil {
+				t.Fatalf("failed to parse timestamp header: %v", err)
+			}
+			timestamp = signedTimestamp + 1
+		}
+		if test.resign {
+			sig, err := m.Signer.CreateSignature(tls.SHA256, gossip.RequestSignedFields{Timestamp: test.timestamp, CTObject: *sth})
+			if err != nil {
+				t.Fatalf("failed to sign request: %v", err)
+			}
+			b64Sig, err := sig.Base64String()
+			if err != nil {
+				t.Fatalf("failed to encode signature: %v", err)
+			}
+			req.Header.Set(gossip.SignatureHeader, b64Sig)
+		}
+		req.Header.Set(gossip.TimestampHeader, strconv.FormatUint(timestamp, 10))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to post CTObject: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("%s NewInfo got status %d, expected %d", test.name, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	if m.GetEntry(sth.Identifier()) != nil {
+		t.Fatalf("replayed NewInfo STH was stored")
+	}
+}
+
+func TestNewInfoWithoutAuthentication(t *testing.T) {
+	m := mustGetMonitor(t)
+	m.AllowUnauthenticatedNewInfo = true
+	server := mustCreateServer(t, m)
+	sth := mustCreateSTH(t, m, 1)
+	resp := postCTObject(t, server.URL + mtr.NewInfoPath, sth)
+	if resp == nil {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unsigned NewInfo got status %d with unauthenticated NewInfo allowed, expected %d", resp.StatusCode, http.StatusOK)
+	}
+	if m.GetEntry(sth.Identifier()) == nil {
+		t.Fatalf("unsigned NewInfo STH wasn't stored")
+	}
+}
+
+// Run with -race to check that concurrent requests don't race on the monitor storage
+func TestConcurrentAuditAndNewInfo(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	numSTHs := 20
+	sths := make([]*mtr.CTObject, numSTHs)
+	for i := range sths {
+		sths[i] = mustCreateSTH(t, m, uint64(i+1))
+	}
+
+	var wg sync.WaitGroup
+	for _, sth := range sths {
+		wg.Add(2)
+		go func(sth *mtr.CTObject) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if resp := postNewInfo(t, m, server.URL, sth); resp != nil {
+					resp.Body.Close()
+				}
+			}
+		}(sth)
+		go func(sth *mtr.CTObject) {
+			defer wg.Done()
+			// Audits may fail until the STH has been stored
+			for j := 0; j < 5; j++ {
+				if resp := postCTObject(t, server.URL+mtr.AuditPath, sth); resp != nil {
+					resp.Body.Close()
+				}
+			}
+		}(sth)
+	}
+	wg.Wait()
+
+	// Every STH is now stored so every audit must succeed
+	for _, sth := range sths {
+		resp := postCTObject(t, server.URL+mtr.AuditPath, sth)
+		if resp == nil {
+			t.FailNow()
+		}
+		var auditResp mtr.CTObject
+		err := json.NewDecoder(resp.Body).Decode(&auditResp)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("failed to decode audit response: %v", err)
+		}
+		if auditResp.TypeID != mtr.STHAuditOKTypeID {
+			t.Fatalf("audit of stored STH returned %s, expected %s", auditResp.TypeID, mtr.STHAuditOKTypeID)
+		}
+	}
+}
+
+func TestMonitorDomain(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	for domain, expectedStatus := range map[string]int{"*.Example.com": http.StatusOK, "www.*.example.com": http.StatusBadRequest} {
+		jsonBytes, _ := json.Marshal(mtr.MonitorDomainRequest{Domain: domain})
+		resp, err := http.Post(server.URL+mtr.MonitorDomainPath, "application/json", bytes.NewBuffer(jsonBytes))
+		if err != nil {
+			t.Fatalf("failed to post MonitorDomain request: %v", err)
+		}
+		var domainResp mtr.MonitorDomainResponse
+		json.NewDecoder(resp.Body).Decode(&domainResp)
+		resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("MonitorDomain request for %s got status %d, expected %d", domain, resp.StatusCode, expectedStatus)
+		}
+		if expectedStatus == http.StatusOK && domainResp.Domain != "*.example.com" {
+			t.Fatalf("MonitorDomain response domain is %s, expected *.example.com", domainResp.Domain)
+		}
+	}
+	if domains := m.Watchlist.Domains(); len(domains) != 1 {
+		t.Fatalf("watchlist has domains %v, expected only *.example.com", domains)
+	}
+}
+
+// Send an SRDWithRevDataGossip request for the CA to the monitor and return the response status
+func mustRequestSRDGossip(t *testing.T, url string, caID string) int {
+	t.Helper()
+	jsonBytes, err := json.Marshal(mtr.SRDWithRevDataGossipRequest{CAID: caID, PercentRevoked: 10, TotalCerts: 100})
+	if err != nil {
+		t.Fatalf("failed to marshal SRDWithRevDataGossip request: %v", err)
+	}
+	req, err := http.NewRequest("GET", url + mtr.SRDWithRevDataGossipPath, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		t.Fatalf("failed to create SRDWithRevDataGossip request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send SRDWithRevDataGossip request: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSRDWithRevDataGossip(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	fakeCA := fakeca.New(t)
+	m.CAList.CAOperators = append(m.CAList.CAOperators, &entitylist.CAOperator{Name: "Fake", CAs: []*entitylist.CAInfo{fakeCA.CAInfo()}})
+
+	if status := mustRequestSRDGossip(t, server.URL, fakeCA.CAID()); status != http.StatusOK {
+		t.Fatalf("SRDWithRevDataGossip request got status %d, expected %d", status, http.StatusOK)
+	}
+	srdCTs := m.FindEntries(&storage.Query{TypeID: mtr.SRDWithRevDataTypeID, Signer: fakeCA.CAID()})
+	if len(srdCTs) != 1 {
+		t.Fatalf("monitor stored %d SRDWithRevData from CA, expected 1", len(srdCTs))
+	}
+	if caSRDCT := fakeCA.SRD(srdCTs[0].Timestamp); caSRDCT == nil || !bytes.Equal(caSRDCT.Digest, srdCTs[0].Digest) {
+		t.Fatalf("stored SRDWithRevData doesn't match the SRD produced by the CA")
+	}
+
+	// CAs have to be in the CA list
+	if status := mustRequestSRDGossip(t, server.URL, testLogID); status != http.StatusBadRequest {
+		t.Fatalf("SRDWithRevDataGossip request for unknown CA got status %d, expected %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestSRDWithRevDataGossipFromUnreachableCA(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	caServer := httptest.NewServer(http.NotFoundHandler())
+	caServer.Close()
+	caInfo := &entitylist.CAInfo{CAID: "unreachable", CAURL: caServer.URL}
+	m.CAList.CAOperators = append(m.CAList.CAOperators, &entitylist.CAOperator{Name: "Unreachable", CAs: []*entitylist.CAInfo{caInfo}})
+
+	if status := mustRequestSRDGossip(t, server.URL, caInfo.CAID); status != http.StatusBadGateway {
+		t.Fatalf("SRDWithRevDataGossip request for unreachable CA got status %d, expected %d", status, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/storage"
+)
+
+const (
+	// Number of CTObjects in a page of an Objects response when the request doesn't give a limit, and the most it may ask for
+	defaultObjectsLimit = 100
+	maxObjectsLimit = 1000
+)
+
+// Handle a request to list the CTObjects stored within the monitor
+// The query params type-id, signer, subject, start, end, and version select the CTObjects. offset and limit select the page
+func (h *Handler) Objects(rw http.ResponseWriter, req *http.Request){
+	glog.V(1).Infoln("Received Objects Request")
+	if req.Method != "GET" {
+		writeWrongMethodResponse(&rw, "GET")
+		return
+	}
+
+	params := req.URL.Query()
+	query, err := parseObjectsQuery(params)
+	if err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Objects Request: %v", err))
+		return
+	}
+	offset, err := parseUintParam(params, "offset", 0)
+	if err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Objects Request: %v", err))
+		return
+	}
+	limit, err := parseUintParam(params, "limit", defaultObjectsLimit)
+	if err != nil || limit == 0 || limit > maxObjectsLimit {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Objects Request: limit must be between 1 and %d", maxObjectsLimit))
+		return
+	}
+
+	entries := h.m.FindEntries(query)
+	start := len(entries)
+	if offset < uint64(len(entries)) {
+		start = int(offset)
+	}
+	end := start + int(limit)
+	if end > len(entries) {
+		end = len(entries)
+	}
+	objectsResp := mtr.ObjectsResponse{CTObjects: make([]mtr.CTObject, 0, end - start), Total: len(entries)}
+	for _, entry := range entries[start:end] {
+		objectsResp.CTObjects = append(objectsResp.CTObjects, *entry)
+	}
+	if end < len(entries) {
+		objectsResp.HasMore = true
+		objectsResp.NextOffset = end
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(rw)
+	if err := encoder.Encode(objectsResp); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Couldn't encode Objects Response to return: %v", err))
+		return
+	}
+}
+
+// Handle a request to fetch the CTObject stored within the monitor with the identifier given by the query params
+// type-id, timestamp, and signer or subject are required. version defaults to 1.0
+func (h *Handler) Object(rw http.ResponseWriter, req *http.Request){
+	glog.V(1).Infoln("Received Object Request")
+	if req.Method != "GET" {
+		writeWrongMethodResponse(&rw, "GET")
+		return
+	}
+
+	params := req.URL.Query()
+	ctObject := mtr.CTObject{
+		TypeID: params.Get("type-id"),
+		Version: mtr.VersionData{Major: 1},
+		Signer: params.Get("signer"),
+		Subject: params.Get("subject"),
+	}
+	if ctObject.TypeID == "" || (ctObject.Signer == "" && ctObject.Subject == "") || params.Get("timestamp") == "" {
+		writeErrorResponse(&rw, http.StatusBadRequest, "Invalid Object Request: type-id, timestamp, and signer or subject are required")
+		return
+	}
+	timestamp, err := parseUintParam(params, "timestamp", 0)
+	if err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Object Request: %v", err))
+		return
+	}
+	ctObject.Timestamp = timestamp
+	if version := params.Get("version"); version != "" {
+		if ctObject.Version, err = mtr.ParseVersion(version); err != nil {
+			writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Object Request: %v", err))
+			return
+		}
+	}
+
+	storedCT := h.m.GetEntry(ctObject.Identifier())
+	if storedCT == nil {
+		writeErrorResponse(&rw, http.StatusNotFound, fmt.Sprintf("No %s CTObject stored at timestamp %d", ctObject.TypeID, ctObject.Timestamp))
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(rw)
+	if err := encoder.Encode(*storedCT); err != nil {
+		writeErrorResponse(&rw, http.StatusInternalServerError, fmt.Sprintf("Couldn't encode Object Response to return: %v", err))
+		return
+	}
+}
+
+// Get the storage Query selected by the query params of an Objects request
+func parseObjectsQuery(params url.Values) (*storage.Query, error) {
+	query := &storage.Query{
+		TypeID: params.Get("type-id"),
+		Signer: params.Get("signer"),
+		Subject: params.Get("subject"),
+	}
+	var err error
+	if query.StartTimestamp, err = parseUintParam(params, "start", 0); err != nil {
+		return nil, err
+	}
+	if query.EndTimestamp, err = parseUintParam(params, "end", 0); err != nil {
+		return nil, err
+	}
+	if query.EndTimestamp != 0 && query.EndTimestamp < query.StartTimestamp {
+		return nil, fmt.Errorf("end (%d) is before start (%d)", query.EndTimestamp, query.StartTimestamp)
+	}
+	if version := params.Get("version"); version != "" {
+		v, err := mtr.ParseVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		query.Version = v.String()
+	}
+	return query, nil
+}
+
+// Get the unsigned integer value of the query param, or defaultValue if it isn't given
+func parseUintParam(params url.Values, name string, defaultValue uint64) (uint64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s param value (%s) isn't an unsigned integer", name, value)
+	}
+	return n, nil
+}
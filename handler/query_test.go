@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+func mustGetObjects(t *testing.T, serverURL string, params url.Values) *mtr.ObjectsResponse {
+	t.Helper()
+	resp, err := http.Get(serverURL + mtr.ObjectsPath + "?" + params.Encode())
+	if err != nil {
+		t.Fatalf("failed to get objects: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Objects request with %v got status %d", params, resp.StatusCode)
+	}
+	var objectsResp mtr.ObjectsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&objectsResp); err != nil {
+		t.Fatalf("failed to decode Objects response: %v", err)
+	}
+	return &objectsResp
+}
+
+func TestObjects(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	for i := uint64(1); i <= 5; i++ {
+		if err := m.AddEntry(mustCreateSTH(t, m, i)); err != nil {
+			t.Fatalf("failed to add STH: %v", err)
+		}
+	}
+	alert, err := mtr.CreateAlert(m.Signer, m.MonitorID, testLogID, 3)
+	if err != nil {
+		t.Fatalf("failed to create Alert: %v", err)
+	}
+	m.AddEntry(alert)
+
+	objectsResp := mustGetObjects(t, server.URL, url.Values{"type-id": {mtr.STHTypeID}, "signer": {testLogID}, "start": {"2"}, "end": {"4"}})
+	if objectsResp.Total != 3 || len(objectsResp.CTObjects) != 3 || objectsResp.HasMore {
+		t.Fatalf("got %d of %d STHs between 2 and 4, expected all 3", len(objectsResp.CTObjects), objectsResp.Total)
+	}
+	for i, ctObject := range objectsResp.CTObjects {
+		if ctObject.TypeID != mtr.STHTypeID || ctObject.Timestamp != uint64(i + 2) {
+			t.Fatalf("STH %d is %s at %d, expected STH at %d", i, ctObject.TypeID, ctObject.Timestamp, i + 2)
+		}
+	}
+
+	// Page through every stored CTObject
+	var timestamps []uint64
+	params := url.Values{"limit": {"4"}}
+	for {
+		objectsResp = mustGetObjects(t, server.URL, params)
+		for _, ctObject := range objectsResp.CTObjects {
+			timestamps = append(timestamps, ctObject.Timestamp)
+		}
+		if !objectsResp.HasMore {
+			break
+		}
+		params.Set("offset", fmt.Sprint(objectsResp.NextOffset))
+	}
+	if fmt.Sprint(timestamps) != "[1 2 3 3 4 5]" {
+		t.Fatalf("paged through CTObjects at timestamps %v, expected [1 2 3 3 4 5]", timestamps)
+	}
+
+	for _, params := range []url.Values{{"start": {"x"}}, {"start": {"3"}, "end": {"2"}}, {"version": {"1"}}, {"limit": {"0"}}} {
+		resp, err := http.Get(server.URL + mtr.ObjectsPath + "?" + params.Encode())
+		if err != nil {
+			t.Fatalf("failed to get objects: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Objects request with %v got status %d, expected %d", params, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestObject(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+	sth := mustCreateSTH(t, m, 1)
+	m.AddEntry(sth)
+
+	tests := []struct {
+		params 			url.Values
+		expectedStatus 	int
+	}{
+		{url.Values{"type-id": {mtr.STHTypeID}, "signer": {testLogID}, "timestamp": {"1"}}, http.StatusOK},
+		{url.Values{"type-id": {mtr.STHTypeID}, "signer": {testLogID}, "timestamp": {"1"}, "version": {"1.0"}}, http.StatusOK},
+		{url.Values{"type-id": {mtr.STHTypeID}, "signer": {testLogID}, "timestamp": {"2"}}, http.StatusNotFound},
+		{url.Values{"type-id": {mtr.STHTypeID}, "timestamp": {"1"}}, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		resp, err := http.Get(server.URL + mtr.ObjectPath + "?" + test.params.Encode())
+		if err != nil {
+			t.Fatalf("failed to get object: %v", err)
+		}
+		var ctObject mtr.CTObject
+		json.NewDecoder(resp.Body).Decode(&ctObject)
+		resp.Body.Close()
+		if resp.StatusCode != test.expectedStatus {
+			t.Fatalf("Object request with %v got status %d, expected %d", test.params, resp.StatusCode, test.expectedStatus)
+		}
+		if test.expectedStatus == http.StatusOK && ctObject.Identifier() != sth.Identifier() {
+			t.Fatalf("Object request with %v got %v, expected the stored STH", test.params, ctObject.Identifier())
+		}
+	}
+}
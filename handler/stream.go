@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/monitor"
+)
+
+// Time between comments sent to idle streams so proxies and clients don't time out the connection
+const streamKeepAliveInterval = 15 * time.Second
+
+// Handle a request to stream the CTObjects newly stored by the monitor as Server-Sent Events
+// The type-id query param, given more than once or comma separated, limits the stream to those TypeIDs
+// Clients resume after the last event they received by sending its id in the Last-Event-ID header or the cursor query param
+func (h *Handler) Stream(rw http.ResponseWriter, req *http.Request){
+	glog.V(1).Infoln("Received Stream Request")
+	if req.Method != "GET" {
+		writeWrongMethodResponse(&rw, "GET")
+		return
+	}
+	if h.m.Feed == nil {
+		writeErrorResponse(&rw, http.StatusServiceUnavailable, "Streaming not enabled on this monitor")
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		writeErrorResponse(&rw, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	var typeIDs []string
+	for _, param := range req.URL.Query()["type-id"] {
+		for _, typeID := range strings.Split(param, ",") {
+			if typeID != "" {
+				typeIDs = append(typeIDs, typeID)
+			}
+		}
+	}
+	cursor := req.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = req.URL.Query().Get("cursor")
+	}
+	replay, sub, err := h.m.Feed.Subscribe(cursor, typeIDs)
+	if errors.Is(err, monitor.ErrCursorExpired) {
+		writeErrorResponse(&rw, http.StatusGone, fmt.Sprintf("Invalid Stream Request: %v. Fetch missed CTObjects from %s", err, mtr.ObjectsPath))
+		return
+	}
+	if err != nil {
+		writeErrorResponse(&rw, http.StatusBadRequest, fmt.Sprintf("Invalid Stream Request: %v", err))
+		return
+	}
+	defer h.m.Feed.Unsubscribe(sub)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+	for _, event := range replay {
+		if err := writeStreamEvent(rw, event); err != nil {
+			glog.Warningf("failed to write stream event: %v", err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// The client fell behind and resumes from its cursor when it reconnects
+				glog.Warningf("dropped stream subscriber that fell behind")
+				return
+			}
+			if err := writeStreamEvent(rw, event); err != nil {
+				glog.Warningf("failed to write stream event: %v", err)
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(rw, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// Write the event as a Server-Sent Event named by the TypeID of its CTObject with the JSON encoded CTObject as data
+func writeStreamEvent(rw http.ResponseWriter, event monitor.FeedEvent) error {
+	data, err := json.Marshal(event.CTObject)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s CTObject: %w", event.CTObject.TypeID, err)
+	}
+	_, err = fmt.Fprintf(rw, "id: %s\nevent: %s\ndata: %s\n\n", event.Cursor, event.CTObject.TypeID, data)
+	return err
+}
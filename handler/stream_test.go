@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+// Event read from a Server-Sent Events stream
+type streamEvent struct {
+	id 			string
+	name 		string
+	ctObject 	mtr.CTObject
+}
+
+// Open a stream from the monitor, resuming after the event with the given id unless it is empty
+func mustOpenStream(t *testing.T, url string, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("failed to create stream request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func mustReadStreamEvent(t *testing.T, reader *bufio.Reader) *streamEvent {
+	t.Helper()
+	var event streamEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if event.id != "" {
+				return &event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.ctObject); err != nil {
+				t.Fatalf("failed to decode stream event data: %v", err)
+			}
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	m := mustGetMonitor(t)
+	server := mustCreateServer(t, m)
+
+	resp, reader := mustOpenStream(t, server.URL + mtr.StreamPath + "?type-id=" + mtr.AlertTypeID + "," + mtr.ConflictingSTHPOMTypeID, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream responded %d with content type %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if err := m.AddEntry(mustCreateSTH(t, m, 1)); err != nil {
+		t.Fatalf("failed to add STH: %v", err)
+	}
+	alert, err := mtr.CreateAlert(m.Signer, m.MonitorID, testLogID, 2)
+	if err != nil {
+		t.Fatalf("failed to create Alert: %v", err)
+	}
+	m.AddEntry(alert)
+	event := mustReadStreamEvent(t, reader)
+	if event.name != mtr.AlertTypeID || event.ctObject.Identifier() != alert.Identifier() {
+		t.Fatalf("stream sent %s event, expected the Alert", event.name)
+	}
+
+	// Resuming after the Alert only sends the CTObjects stored since
+	alert2, _ := mtr.CreateAlert(m.Signer, m.MonitorID, testLogID, 3)
+	m.AddEntry(alert2)
+	_, reader = mustOpenStream(t, server.URL + mtr.StreamPath, event.id)
+	event = mustReadStreamEvent(t, reader)
+	if event.ctObject.Identifier() != alert2.Identifier() {
+		t.Fatalf("resumed stream sent %s event at %d, expected the Alert at 3", event.name, event.ctObject.Timestamp)
+	}
+
+	resp, _ = mustOpenStream(t, server.URL + mtr.StreamPath, "0-1")
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("stream resumed from expired cursor got status %d, expected %d", resp.StatusCode, http.StatusGone)
+	}
+}
+
+func TestStreamWithoutFeed(t *testing.T) {
+	m := mustGetMonitor(t)
+	m.Feed = nil
+	server := mustCreateServer(t, m)
+	resp, _ := mustOpenStream(t, server.URL + mtr.StreamPath, "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("stream without a Feed responded %d, expected %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
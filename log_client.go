@@ -2,6 +2,7 @@ package mtr
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -63,6 +64,7 @@ func (c *LogClient) GetSTH(ctx context.Context) (*CTObject, error) {
 }
 
 // GetSTH retrieves the current STH from the log and produces a SignedTreeHeadData object
+// The STH signature is verified against the log's public key found in the log list
 // Returns a populated SignedTreeHead which is converted into SignedTreeHeaData, or a 
 //non-nil error (which may be of type RspError if a raw http.Response is available).
 func (c *LogClient) getSTH(ctx context.Context) (*SignedTreeHeadData, error) {
@@ -82,6 +84,11 @@ func (c *LogClient) getSTH(ctx context.Context) (*SignedTreeHeadData, error) {
 	treeHeadSignature := c.constructTreeHeadSignatureFromSTH(sth)
 	logID := c.LogInfo.LogID
 	STHData := &SignedTreeHeadData{logID, treeHeadSignature, sth.TreeHeadSignature}
+
+	// Never hand out an STH that the log did not actually sign
+	if err := VerifySTHSignature(STHData, c.LogInfo.Key); err != nil {
+		return nil, err
+	}
 	return STHData, nil
 }
 
@@ -115,6 +122,37 @@ func (c *LogClient) GetSTHWithConsistencyProof(ctx context.Context, first, secon
 	return sthWithPOCCT, nil
 }
 
+// Get STH and the ConsistencyProof from the first tree size to the tree size of the new STH
+// If the tree hasn't grown past first there is nothing to prove, so an STH CTObject is returned instead of an STH_POC CTObject
+func (c *LogClient) GetSTHWithConsistencyProofFrom(ctx context.Context, first uint64) (*CTObject, error){
+	sth, err := c.getSTH(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to first get STH when getting STHWithPoC from Logger %s: %w", c.LogInfo.LogID, err)
+	}
+	if sth.TreeHeadData.TreeSize <= first {
+		sthCT, err := ConstructCTObject(sth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct STH CTObject for Logger %s: %w", c.LogInfo.LogID, err)
+		}
+		return sthCT, nil
+	}
+	poc, err := c.getConsistencyProof(ctx, first, sth.TreeHeadData.TreeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to first get PoC when getting STHWithPoC from Logger %s: %w", c.LogInfo.LogID, err)
+	}
+	sthWithPoc := &SignedTreeHeadWithConsistencyProof{*sth, *poc}
+	sthWithPOCCT, err := ConstructCTObject(sthWithPoc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct STHWithPoC CTObject for Logger %s: %w", c.LogInfo.LogID, err)
+	}
+	return sthWithPOCCT, nil
+}
+
+// Get the ConsistencyProof between two tree sizes of the log
+func (c *LogClient) GetConsistencyProof(ctx context.Context, first, second uint64) (*ConsistencyProofData, error) {
+	return c.getConsistencyProof(ctx, first, second)
+}
+
 // Retrieves the consistency proof between two tree_sizes of the tree
 func (c *LogClient) getConsistencyProof(ctx context.Context, first, second uint64) (*ConsistencyProofData, error) {
 	base10 := 10
@@ -149,4 +187,46 @@ func (c *LogClient) GetEntryAndProof(ctx context.Context, index, treeSize uint64
 	logID := c.LogInfo.LogID
 	inclusionProof := &InclusionProofData{logID, treeSize, index, resp.AuditPath}
 	return inclusionProof, resp.LeafInput,  nil
-}
\ No newline at end of file
+}
+
+// Return the inclusion proof, holding the leaf index, of the leaf with the given leafHash within the tree of size treeSize
+func (c *LogClient) GetProofByHash(ctx context.Context, leafHash []byte, treeSize uint64) (*InclusionProofData, error) {
+	base10 := 10
+	params := map[string]string{
+		"hash":      base64.StdEncoding.EncodeToString(leafHash),
+		"tree_size": strconv.FormatUint(treeSize, base10),
+	}
+	var resp ct.GetProofByHashResponse
+	if _, _, err := c.GetAndParse(ctx, ct.GetProofByHashPath, params, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get ProofByHash from Logger %s: %w", c.LogInfo.LogID, err)
+	}
+
+	// Construct ctv2 InclusionProofData
+	logID := c.LogInfo.LogID
+	inclusionProof := &InclusionProofData{logID, treeSize, uint64(resp.LeafIndex), resp.AuditPath}
+	return inclusionProof, nil
+}
+
+// Return the raw entries of the log from index start to index end inclusive
+// Logs may return fewer entries than requested, so callers must check how many entries were returned
+func (c *LogClient) GetEntries(ctx context.Context, start, end uint64) ([]ct.LeafEntry, error) {
+	if start > end {
+		return nil, fmt.Errorf("invalid get-entries range from %d to %d", start, end)
+	}
+	base10 := 10
+	params := map[string]string{
+		"start": strconv.FormatUint(start, base10),
+		"end":   strconv.FormatUint(end, base10),
+	}
+	var resp ct.GetEntriesResponse
+	if _, _, err := c.GetAndParse(ctx, ct.GetEntriesPath, params, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get entries %d to %d from Logger %s: %w", start, end, c.LogInfo.LogID, err)
+	}
+	if len(resp.Entries) == 0 {
+		return nil, fmt.Errorf("Logger %s returned no entries from %d to %d", c.LogInfo.LogID, start, end)
+	}
+	if uint64(len(resp.Entries)) > end - start + 1 {
+		return resp.Entries[:end - start + 1], nil
+	}
+	return resp.Entries, nil
+}
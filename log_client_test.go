@@ -4,26 +4,34 @@ import (
 	"testing"
 	"context"
 
-	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/testutil/fakelog"
 )
 
 const (
-	logListPath = "entitylist/log_list.json"
-
-	testLogID = "9lyUL9F3MCIUVBgIMJRWjuNNExkzv98MLyALzE7xZOM="
+	// Entries in the fake log the LogClient tests run against
+	testTreeSize = 1000
 )
 
-func mustCreateLogClient(t *testing.T) (*LogClient, context.Context, error) {
+// Start a fake log holding testTreeSize entries
+func mustCreateFakeLog(t *testing.T) *fakelog.Log {
 	t.Helper()
-	logList, err := entitylist.NewLogList(logListPath)
+	log := fakelog.New(t)
+	log.AddEntries(testTreeSize)
+	return log
+}
+
+func mustCreateLogClientForLog(t *testing.T, log *fakelog.Log) *LogClient {
+	t.Helper()
+	logClient, err := NewLogClient(log.LogInfo())
 	if err != nil {
-		t.Fatalf("failed to create loglist at path (%s): %v", logListPath, err)
-	}
-	logInfo := logList.FindLogByLogID(testLogID)
-	if logInfo == nil {
-		t.Fatalf("testLogID %s not found in loglist", testLogID)
+		t.Fatalf("failed to create LogClient for fake log: %v", err)
 	}
-	logClient, err := NewLogClient(logInfo)
+	return logClient
+}
+
+func mustCreateLogClient(t *testing.T) (*LogClient, context.Context, error) {
+	t.Helper()
+	logClient, err := NewLogClient(mustCreateFakeLog(t).LogInfo())
 	ctx := context.Background()
 	return logClient, ctx, err
 }
@@ -56,6 +64,35 @@ func TestGetSTHReturnType(t *testing.T) {
 	}
 }
 
+func TestGetSTHMatchesLog(t *testing.T) {
+	log := mustCreateFakeLog(t)
+	sthCT, err := mustCreateLogClientForLog(t, log).GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get STH: %v", err)
+	}
+	sth, err := sthCT.DeconstructSTH()
+	if err != nil {
+		t.Fatalf("failed to deconstruct STH: %v", err)
+	}
+	if sth.LogID != log.LogID() || sthCT.Signer != log.LogID() {
+		t.Fatalf("STH has LogID %s, expected %s", sth.LogID, log.LogID())
+	}
+	if sth.TreeHeadData.TreeSize != testTreeSize || string(sth.TreeHeadData.SHA256RootHash[:]) != string(log.RootHash(testTreeSize)) {
+		t.Fatalf("STH with tree size %d doesn't match the tree of the log", sth.TreeHeadData.TreeSize)
+	}
+	if err := VerifySTHSignature(sth, log.Key()); err != nil {
+		t.Fatalf("STH signature doesn't verify: %v", err)
+	}
+}
+
+func TestGetSTHRejectsBadSignature(t *testing.T) {
+	log := mustCreateFakeLog(t)
+	log.SetBadSignature(true)
+	if _, err := mustCreateLogClientForLog(t, log).GetSTH(context.Background()); err == nil {
+		t.Fatalf("got STH signed with a key other than the log's key")
+	}
+}
+
 func mustGetSTHWithConsistencyProof(t *testing.T, firstTreeSize, secondTreeSize uint64) (*CTObject, error) {
 	t.Helper()
 	logClient, ctx, _ := mustCreateLogClient(t)
@@ -81,6 +118,44 @@ func TestGetSTHWithConsistencyProofReturnType(t *testing.T) {
 	}
 }
 
+// Get the STH of a log at 100 entries, grow it to testTreeSize entries and optionally fork it, then check the
+// ConsistencyProof to the new STH against the old STH
+func verifyConsistencyAfterGrowth(t *testing.T, fork bool) error {
+	t.Helper()
+	log := fakelog.New(t)
+	log.AddEntries(100)
+	logClient := mustCreateLogClientForLog(t, log)
+	ctx := context.Background()
+	sthCT, err := logClient.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("failed to get STH: %v", err)
+	}
+	log.AddEntries(testTreeSize - 100)
+	if fork {
+		log.Fork(50)
+	}
+	sthpocCT, err := logClient.GetSTHWithConsistencyProofFrom(ctx, 100)
+	if err != nil {
+		t.Fatalf("failed to get STHWithPoC: %v", err)
+	}
+	sth1, _ := sthCT.DeconstructSTH()
+	sth2, _ := sthpocCT.DeconstructSTH()
+	poc, _ := sthpocCT.DeconstructPOC()
+	return VerifySTHConsistency(sth1, sth2, poc)
+}
+
+func TestGetSTHWithConsistencyProofVerifies(t *testing.T) {
+	if err := verifyConsistencyAfterGrowth(t, false); err != nil {
+		t.Fatalf("ConsistencyProof of honest log doesn't verify: %v", err)
+	}
+}
+
+func TestGetSTHWithConsistencyProofFromForkedLog(t *testing.T) {
+	if err := verifyConsistencyAfterGrowth(t, true); err == nil {
+		t.Fatalf("ConsistencyProof of forked log verified")
+	}
+}
+
 func mustGetEntryAndProof(t *testing.T, entryIndex, treeSize uint64) (*InclusionProofData, []byte, error) {
 	t.Helper()
 	logClient, ctx, _ := mustCreateLogClient(t)
@@ -104,4 +179,43 @@ func TestGetEntryAndProofReturnType(t *testing.T) {
 	if _, ok := poi.(*InclusionProofData); !ok {
 		t.Fatalf("incorrect data type received from GetSTH: %T", poi)
 	}
-}
\ No newline at end of file
+}
+
+func TestGetEntryAndProofVerifies(t *testing.T) {
+	log := mustCreateFakeLog(t)
+	logClient := mustCreateLogClientForLog(t, log)
+	ctx := context.Background()
+	sthCT, err := logClient.GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("failed to get STH: %v", err)
+	}
+	sth, _ := sthCT.DeconstructSTH()
+	for _, index := range []uint64{0, 4, 511, 512, testTreeSize - 1} {
+		poi, leafInput, err := logClient.GetEntryAndProof(ctx, index, testTreeSize)
+		if err != nil {
+			t.Fatalf("failed to get entry %d and proof: %v", index, err)
+		}
+		if err := VerifySTHInclusion(sth, HashLeaf(leafInput), poi); err != nil {
+			t.Fatalf("InclusionProof of entry %d doesn't verify: %v", index, err)
+		}
+		poi, err = logClient.GetProofByHash(ctx, HashLeaf(leafInput), testTreeSize)
+		if err != nil || poi.LeadIndex != index {
+			t.Fatalf("failed to get proof of entry %d by hash: %v", index, err)
+		}
+	}
+}
+
+func TestGetEntries(t *testing.T) {
+	log := mustCreateFakeLog(t)
+	log.MaxGetEntries = 64
+	entries, err := mustCreateLogClientForLog(t, log).GetEntries(context.Background(), 10, 200)
+	if err != nil {
+		t.Fatalf("failed to get entries: %v", err)
+	}
+	if len(entries) != 64 {
+		t.Fatalf("got %d entries from log serving at most 64", len(entries))
+	}
+	if string(HashLeaf(entries[0].LeafInput)) != string(log.LeafHash(10)) {
+		t.Fatalf("first entry isn't entry 10 of the log")
+	}
+}
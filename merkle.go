@@ -0,0 +1,230 @@
+package mtr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// Domain separation prefixes for Merkle tree hashing as defined in RFC 6962 section 2.1
+const (
+	leafHashPrefix = 0x00
+	nodeHashPrefix = 0x01
+)
+
+// HashLeaf returns the RFC 6962 Merkle tree hash of a leaf
+func HashLeaf(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafHashPrefix})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// HashChildren returns the RFC 6962 Merkle tree hash of an interior node given its two children
+func HashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodeHashPrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// VerifyConsistencyProof checks that the given proof shows the tree of size2 with root2 is an
+// append-only extension of the tree of size1 with root1. Returns nil if the proof is valid
+func VerifyConsistencyProof(size1, size2 uint64, root1, root2 []byte, proof [][]byte) error {
+	switch {
+	case size1 > size2:
+		return fmt.Errorf("first tree size (%d) is larger than second tree size (%d)", size1, size2)
+	case size1 == size2:
+		if len(proof) != 0 {
+			return fmt.Errorf("consistency proof between equal tree sizes must be empty")
+		}
+		if !bytes.Equal(root1, root2) {
+			return fmt.Errorf("root hashes of equal tree sizes (%d) do not match", size1)
+		}
+		return nil
+	case size1 == 0:
+		if len(proof) != 0 {
+			return fmt.Errorf("consistency proof from an empty tree must be empty")
+		}
+		return nil
+	case len(proof) == 0:
+		return fmt.Errorf("empty consistency proof between tree sizes %d and %d", size1, size2)
+	}
+
+	// If size1 is a power of two the first tree's root is the starting node and is omitted from the proof
+	if size1&(size1-1) == 0 {
+		proof = append([][]byte{root1}, proof...)
+	}
+
+	fn := size1 - 1
+	sn := size2 - 1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr := proof[0]
+	sr := proof[0]
+	for _, node := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("consistency proof between tree sizes %d and %d is too long", size1, size2)
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = HashChildren(node, fr)
+			sr = HashChildren(node, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = HashChildren(sr, node)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("consistency proof between tree sizes %d and %d is too short", size1, size2)
+	}
+	if !bytes.Equal(fr, root1) {
+		return fmt.Errorf("consistency proof does not match root hash of tree size %d", size1)
+	}
+	if !bytes.Equal(sr, root2) {
+		return fmt.Errorf("consistency proof does not match root hash of tree size %d", size2)
+	}
+	return nil
+}
+
+// VerifyInclusionProof checks that the given proof shows the leaf with leafHash is at leafIndex
+// within the tree of size treeSize with the given root. Returns nil if the proof is valid
+func VerifyInclusionProof(leafIndex, treeSize uint64, leafHash, root []byte, proof [][]byte) error {
+	if leafIndex >= treeSize {
+		return fmt.Errorf("leaf index (%d) is outside of tree size (%d)", leafIndex, treeSize)
+	}
+
+	fn := leafIndex
+	sn := treeSize - 1
+	r := leafHash
+	for _, node := range proof {
+		if sn == 0 {
+			return fmt.Errorf("inclusion proof for leaf index %d in tree size %d is too long", leafIndex, treeSize)
+		}
+		if fn&1 == 1 || fn == sn {
+			r = HashChildren(node, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = HashChildren(r, node)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("inclusion proof for leaf index %d in tree size %d is too short", leafIndex, treeSize)
+	}
+	if !bytes.Equal(r, root) {
+		return fmt.Errorf("inclusion proof does not match root hash of tree size %d", treeSize)
+	}
+	return nil
+}
+
+// LeafHashForCertificate computes the Merkle tree leaf hash of a DER encoded X509 certificate
+// timestamp is the timestamp of the SCT the log issued for the certificate
+func LeafHashForCertificate(cert []byte, timestamp uint64) ([]byte, error) {
+	leaf := ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: cert}, timestamp)
+	leafHash, err := ct.LeafHashForLeaf(leaf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash certificate leaf: %w", err)
+	}
+	return leafHash[:], nil
+}
+
+// LeafHashForPrecertificate computes the Merkle tree leaf hash of the precertificate entry a log added for a certificate
+// cert is either the DER encoded precertificate or the final certificate with the SCT embedded, and issuer the DER encoded
+// certificate of the CA that issued it. timestamp is the timestamp of the SCT the log issued for the precertificate
+func LeafHashForPrecertificate(cert []byte, issuer []byte, timestamp uint64) ([]byte, error) {
+	parsedCert, err := x509.ParseCertificate(cert)
+	if x509.IsFatal(err) {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	parsedIssuer, err := x509.ParseCertificate(issuer)
+	if x509.IsFatal(err) {
+		return nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
+	}
+	chain := []*x509.Certificate{parsedCert, parsedIssuer}
+	var leaf *ct.MerkleTreeLeaf
+	if parsedCert.IsPrecertificate() {
+		leaf, err = ct.MerkleTreeLeafFromChain(chain, ct.PrecertLogEntryType, timestamp)
+	} else {
+		leaf, err = ct.MerkleTreeLeafForEmbeddedSCT(chain, timestamp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to build precertificate leaf: %w", err)
+	}
+	leafHash, err := ct.LeafHashForLeaf(leaf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash precertificate leaf: %w", err)
+	}
+	return leafHash[:], nil
+}
+
+// Check whether the DER encoded certificate is logged as a precertificate entry, because it is either a precertificate or has embedded SCTs
+func IsPrecertificateEntry(cert []byte) (bool, error) {
+	parsedCert, err := x509.ParseCertificate(cert)
+	if x509.IsFatal(err) {
+		return false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return parsedCert.IsPrecertificate() || len(parsedCert.SCTList.SCTList) > 0, nil
+}
+
+// CompactRange is an incrementally built Merkle tree over the leaves from index 0 up to Size
+// Only the roots of the perfect subtrees that make up the tree are kept, so appending a leaf and
+// computing the root take O(log Size) time and space. Hashes are ordered from the largest subtree to the smallest
+type CompactRange struct {
+	Size 	uint64
+	Hashes 	[][]byte
+}
+
+// Append the leaf hash of the next leaf of the tree
+func (c *CompactRange) Append(leafHash []byte) {
+	c.Hashes = append(c.Hashes, leafHash)
+
+	// Each trailing one bit of the old size is a subtree the same size as the new one, so merge them
+	for size := c.Size; size&1 == 1; size >>= 1 {
+		n := len(c.Hashes)
+		c.Hashes = append(c.Hashes[:n-2], HashChildren(c.Hashes[n-2], c.Hashes[n-1]))
+	}
+	c.Size++
+}
+
+// Append the raw data of the next leaf of the tree, such as the leaf_input of a get-entries response
+func (c *CompactRange) AppendLeaf(leaf []byte) {
+	c.Append(HashLeaf(leaf))
+}
+
+// Root returns the RFC 6962 Merkle tree hash of the leaves appended so far
+func (c *CompactRange) Root() []byte {
+	if len(c.Hashes) == 0 {
+		emptyRoot := sha256.Sum256(nil)
+		return emptyRoot[:]
+	}
+	root := c.Hashes[len(c.Hashes)-1]
+	for i := len(c.Hashes) - 2; i >= 0; i-- {
+		root = HashChildren(c.Hashes[i], root)
+	}
+	return root
+}
+
+// Copy returns a CompactRange that can be appended to without changing c
+func (c *CompactRange) Copy() *CompactRange {
+	hashes := make([][]byte, len(c.Hashes))
+	copy(hashes, c.Hashes)
+	return &CompactRange{c.Size, hashes}
+}
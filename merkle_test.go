@@ -0,0 +1,273 @@
+package mtr
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+var (
+	// Leaves and roots taken from the RFC 6962 test vectors used by the reference implementations
+	testMerkleLeaves = [][]byte{
+		{},
+		{0x00},
+		{0x10},
+		{0x20, 0x21},
+		{0x30, 0x31},
+		{0x40, 0x41, 0x42, 0x43},
+		{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+		{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+	}
+	testMerkleRoots = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+// Largest power of two strictly less than n
+func largestPowerOfTwoBelow(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// Reference MTH from RFC 6962 section 2.1
+func referenceRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return HashLeaf(leaves[0])
+	}
+	k := largestPowerOfTwoBelow(uint64(len(leaves)))
+	return HashChildren(referenceRoot(leaves[:k]), referenceRoot(leaves[k:]))
+}
+
+// Reference SUBPROOF from RFC 6962 section 2.1.2
+func referenceSubproof(m uint64, leaves [][]byte, complete bool) [][]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{referenceRoot(leaves)}
+	}
+	k := largestPowerOfTwoBelow(n)
+	if m <= k {
+		return append(referenceSubproof(m, leaves[:k], complete), referenceRoot(leaves[k:]))
+	}
+	return append(referenceSubproof(m-k, leaves[k:], false), referenceRoot(leaves[:k]))
+}
+
+// Reference PATH from RFC 6962 section 2.1.1
+func referencePath(m uint64, leaves [][]byte) [][]byte {
+	n := uint64(len(leaves))
+	if n == 1 {
+		return nil
+	}
+	k := largestPowerOfTwoBelow(n)
+	if m < k {
+		return append(referencePath(m, leaves[:k]), referenceRoot(leaves[k:]))
+	}
+	return append(referencePath(m-k, leaves[k:]), referenceRoot(leaves[:k]))
+}
+
+func TestReferenceRootMatchesTestVectors(t *testing.T) {
+	for i, expected := range testMerkleRoots {
+		root := hex.EncodeToString(referenceRoot(testMerkleLeaves[:i+1]))
+		if root != expected {
+			t.Errorf("root of tree size %d is %s, expected %s", i+1, root, expected)
+		}
+	}
+}
+
+func TestVerifyConsistencyProof(t *testing.T) {
+	for size2 := uint64(1); size2 <= uint64(len(testMerkleLeaves)); size2++ {
+		for size1 := uint64(1); size1 <= size2; size1++ {
+			root1 := referenceRoot(testMerkleLeaves[:size1])
+			root2 := referenceRoot(testMerkleLeaves[:size2])
+			proof := referenceSubproof(size1, testMerkleLeaves[:size2], true)
+			if err := VerifyConsistencyProof(size1, size2, root1, root2, proof); err != nil {
+				t.Errorf("failed to verify valid consistency proof between sizes %d and %d: %v", size1, size2, err)
+			}
+		}
+	}
+}
+
+func TestVerifyConsistencyProofInvalid(t *testing.T) {
+	size1, size2 := uint64(3), uint64(7)
+	root1 := referenceRoot(testMerkleLeaves[:size1])
+	root2 := referenceRoot(testMerkleLeaves[:size2])
+	proof := referenceSubproof(size1, testMerkleLeaves[:size2], true)
+
+	if err := VerifyConsistencyProof(size1, size2, root2, root2, proof); err == nil {
+		t.Errorf("verified consistency proof with wrong first root")
+	}
+	if err := VerifyConsistencyProof(size1, size2, root1, root1, proof); err == nil {
+		t.Errorf("verified consistency proof with wrong second root")
+	}
+	if err := VerifyConsistencyProof(size1, size2, root1, root2, proof[:len(proof)-1]); err == nil {
+		t.Errorf("verified truncated consistency proof")
+	}
+	if err := VerifyConsistencyProof(size1, size2, root1, root2, append(proof, root1)); err == nil {
+		t.Errorf("verified consistency proof with extra node")
+	}
+	badProof := make([][]byte, len(proof))
+	copy(badProof, proof)
+	badProof[0] = bytes.Repeat([]byte{0xff}, len(proof[0]))
+	if err := VerifyConsistencyProof(size1, size2, root1, root2, badProof); err == nil {
+		t.Errorf("verified consistency proof with modified node")
+	}
+	if err := VerifyConsistencyProof(size2, size1, root2, root1, proof); err == nil {
+		t.Errorf("verified consistency proof with decreasing tree size")
+	}
+}
+
+func TestVerifyInclusionProof(t *testing.T) {
+	for size := uint64(1); size <= uint64(len(testMerkleLeaves)); size++ {
+		root := referenceRoot(testMerkleLeaves[:size])
+		for index := uint64(0); index < size; index++ {
+			leafHash := HashLeaf(testMerkleLeaves[index])
+			proof := referencePath(index, testMerkleLeaves[:size])
+			if err := VerifyInclusionProof(index, size, leafHash, root, proof); err != nil {
+				t.Errorf("failed to verify valid inclusion proof for index %d in size %d: %v", index, size, err)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionProofInvalid(t *testing.T) {
+	index, size := uint64(2), uint64(7)
+	root := referenceRoot(testMerkleLeaves[:size])
+	leafHash := HashLeaf(testMerkleLeaves[index])
+	proof := referencePath(index, testMerkleLeaves[:size])
+
+	if err := VerifyInclusionProof(index, size, HashLeaf(testMerkleLeaves[index+1]), root, proof); err == nil {
+		t.Errorf("verified inclusion proof for the wrong leaf")
+	}
+	if err := VerifyInclusionProof(index+1, size, leafHash, root, proof); err == nil {
+		t.Errorf("verified inclusion proof at the wrong index")
+	}
+	if err := VerifyInclusionProof(index, size, leafHash, root, proof[:len(proof)-1]); err == nil {
+		t.Errorf("verified truncated inclusion proof")
+	}
+	if err := VerifyInclusionProof(size, size, leafHash, root, proof); err == nil {
+		t.Errorf("verified inclusion proof for index outside of tree")
+	}
+}
+
+func TestCompactRangeRoot(t *testing.T) {
+	var compactRange CompactRange
+	if root := hex.EncodeToString(compactRange.Root()); root != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("root of empty tree is %s, expected hash of the empty string", root)
+	}
+	for i, expected := range testMerkleRoots {
+		compactRange.AppendLeaf(testMerkleLeaves[i])
+		if root := hex.EncodeToString(compactRange.Root()); root != expected {
+			t.Fatalf("root of tree size %d is %s, expected %s", i+1, root, expected)
+		}
+	}
+}
+
+func TestCompactRangeMatchesReferenceRoot(t *testing.T) {
+	var leaves [][]byte
+	var compactRange CompactRange
+	for i := 0; i < 70; i++ {
+		leaves = append(leaves, []byte{byte(i)})
+		compactRange.AppendLeaf(leaves[i])
+		if !bytes.Equal(compactRange.Root(), referenceRoot(leaves)) {
+			t.Fatalf("root of tree size %d doesn't match reference root", i+1)
+		}
+	}
+
+	// Appending to a copy leaves the original untouched
+	root := compactRange.Root()
+	compactRange.Copy().AppendLeaf([]byte("extra"))
+	if compactRange.Size != 70 || !bytes.Equal(compactRange.Root(), root) {
+		t.Fatalf("appending to a copy changed the original CompactRange")
+	}
+}
+
+// Create a DER encoded certificate for example.com signed by the issuer, or self-signed if issuer is nil
+func mustCreateCertificate(t *testing.T, template *x509.Certificate, issuer *x509.Certificate, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	if issuer == nil {
+		issuer = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func TestLeafHashForPrecertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	notBefore := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	issuerTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: "Test CA"},
+		NotBefore: notBefore,
+		NotAfter: notBefore.AddDate(1, 0, 0),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}
+	issuerDER := mustCreateCertificate(t, issuerTemplate, nil, key)
+	issuer, err := x509.ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatalf("failed to parse issuer: %v", err)
+	}
+
+	// The precertificate and the final certificate with its SCT embedded are the same certificate to the log
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{CommonName: "example.com"},
+		DNSNames: []string{"example.com"},
+		NotBefore: notBefore,
+		NotAfter: notBefore.AddDate(0, 3, 0),
+	}
+	precertTemplate := *template
+	precertTemplate.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+	precert := mustCreateCertificate(t, &precertTemplate, issuer, key)
+	certTemplate := *template
+	certTemplate.SCTList = x509.SignedCertificateTimestampList{SCTList: []x509.SerializedSCT{{Val: []byte("sct")}}}
+	cert := mustCreateCertificate(t, &certTemplate, issuer, key)
+
+	precertLeafHash, err := LeafHashForPrecertificate(precert, issuerDER, 1000)
+	if err != nil {
+		t.Fatalf("failed to compute leaf hash of precertificate: %v", err)
+	}
+	certLeafHash, err := LeafHashForPrecertificate(cert, issuerDER, 1000)
+	if err != nil {
+		t.Fatalf("failed to compute leaf hash of certificate with embedded SCT: %v", err)
+	}
+	if !bytes.Equal(precertLeafHash, certLeafHash) {
+		t.Fatalf("leaf hash of certificate with embedded SCT (%x) doesn't match leaf hash of its precertificate (%x)", certLeafHash, precertLeafHash)
+	}
+	for _, der := range [][]byte{precert, cert} {
+		if isPrecert, err := IsPrecertificateEntry(der); err != nil || !isPrecert {
+			t.Fatalf("certificate isn't logged as a precertificate entry: %v", err)
+		}
+	}
+	if isPrecert, err := IsPrecertificateEntry(issuerDER); err != nil || isPrecert {
+		t.Fatalf("certificate without SCTs is logged as a precertificate entry: %v", err)
+	}
+}
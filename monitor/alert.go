@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+// Create, store, and gossip an Alert signed by the monitor stating that the log didn't serve an STH for the MMD ending at mmdEnd
+func (m *Monitor) AlertNonRespondingLog(logID string, mmdEnd time.Time) (*mtr.CTObject, error) {
+	glog.Warningf("Logger %s didn't serve an STH for the MMD ending at %v", logID, mmdEnd)
+	timestamp := uint64(mmdEnd.UnixNano() / int64(time.Millisecond))
+	alertCT, err := mtr.CreateAlert(m.Signer, m.MonitorID, logID, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Alert for nonresponding Logger %s: %w", logID, err)
+	}
+	if err := m.AddEntry(alertCT); err != nil {
+		return nil, fmt.Errorf("failed to store Alert for nonresponding Logger %s: %w", logID, err)
+	}
+	m.Gossip(alertCT)
+
+	// The monitor's own Alert counts towards the threshold
+	if _, err := m.ProcessAlert(alertCT); err != nil {
+		return nil, err
+	}
+	return alertCT, nil
+}
+
+// Check whether enough monitors have sent Alerts about the log and MMD of the given stored Alert
+// Once the threshold is reached a NonRespondingLogPOM is created, stored, and gossiped
+// Returns the PoM or nil if the threshold hasn't been reached or the PoM was already created
+func (m *Monitor) ProcessAlert(alertCT *mtr.CTObject) (*mtr.CTObject, error) {
+	m.alertLock.Lock()
+	defer m.alertLock.Unlock()
+	pomID := mtr.ObjectIdentifier{First: mtr.NonRespondingLogPOMTypeID, Second: alertCT.Subject, Third: alertCT.Timestamp, Fourth: alertCT.Version.String()}
+	if m.GetEntry(pomID) != nil {
+		return nil, nil
+	}
+	alertCTs := m.getAlertEntries(alertCT)
+	if len(alertCTs) < m.alertThreshold() {
+		glog.V(1).Infof("%d of %d Alerts needed for NonRespondingLogPOM of Logger %s", len(alertCTs), m.alertThreshold(), alertCT.Subject)
+		return nil, nil
+	}
+
+	pom, err := mtr.CreateNonRespondingLogPOM(alertCTs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NonRespondingLogPOM for Logger %s: %w", alertCT.Subject, err)
+	}
+	glog.Warningf("%d monitors agree Logger %s is nonresponding. Gossiping NonRespondingLogPOM", len(alertCTs), alertCT.Subject)
+	if err := m.AddEntry(pom); err != nil {
+		return nil, fmt.Errorf("failed to store NonRespondingLogPOM: %w", err)
+	}
+	m.Gossip(pom)
+	return pom, nil
+}
+
+// Get the stored Alerts from every monitor in the monitor list about the log and MMD of the given Alert
+func (m *Monitor) getAlertEntries(alertCT *mtr.CTObject) []*mtr.CTObject {
+	var alertCTs []*mtr.CTObject
+	id := alertCT.Identifier()
+	for _, monitorInfo := range m.MonitorList.Monitors() {
+		id.Second = monitorInfo.MonitorID
+		if stored := m.GetEntry(id); stored != nil {
+			alertCTs = append(alertCTs, stored)
+		}
+	}
+	return alertCTs
+}
+
+// Get the number of Alerts needed to create a NonRespondingLogPOM
+func (m *Monitor) alertThreshold() int {
+	if m.AlertThreshold > 0 {
+		return m.AlertThreshold
+	}
+	return len(m.MonitorList.Monitors()) / 2 + 1
+}
+
+// Verify the Alert within the Alert CTObject against the key of the monitor found in the monitor list
+func (m *Monitor) verifyAlert(ctObject *mtr.CTObject) error {
+	alert, err := ctObject.DeconstructAlert()
+	if err != nil {
+		return fmt.Errorf("failed to verify Alert: %w", err)
+	}
+	if alert.TBS.Signer != ctObject.Signer || alert.TBS.Subject != ctObject.Subject || alert.TBS.Timestamp != ctObject.Timestamp {
+		return fmt.Errorf("Alert fields don't match CTObject fields")
+	}
+	return m.verifyAlertSignature(alert)
+}
+
+// Verify the signature of the Alert using the key of its signer found in the monitor list
+func (m *Monitor) verifyAlertSignature(alert *mtr.Alert) error {
+	monitorInfo := m.MonitorList.FindMonitorByMonitorID(alert.TBS.Signer)
+	if monitorInfo == nil {
+		return fmt.Errorf("MonitorID (%s) not found in monitor list", alert.TBS.Signer)
+	}
+	return mtr.VerifyAlertSignature(alert, monitorInfo.MonitorKey)
+}
+
+// Verify that every Alert within the NonRespondingLogPOM is about the same log and MMD and signed by a different monitor
+func (m *Monitor) verifyNonRespondingLogPOM(ctObject *mtr.CTObject) error {
+	pom, err := ctObject.DeconstructNonRespondingLogPOM()
+	if err != nil {
+		return fmt.Errorf("failed to verify NonRespondingLogPOM: %w", err)
+	}
+	if len(pom.AlertList) < m.alertThreshold() {
+		return fmt.Errorf("NonRespondingLogPOM has %d Alerts, need %d", len(pom.AlertList), m.alertThreshold())
+	}
+	signers := make(map[string]bool)
+	for i := range pom.AlertList {
+		alert := &pom.AlertList[i]
+		if alert.TBS.Subject != ctObject.Subject || alert.TBS.Timestamp != ctObject.Timestamp {
+			return fmt.Errorf("NonRespondingLogPOM Alert from monitor %s isn't about Logger %s at %d", alert.TBS.Signer, ctObject.Subject, ctObject.Timestamp)
+		}
+		if signers[alert.TBS.Signer] {
+			return fmt.Errorf("NonRespondingLogPOM has multiple Alerts from monitor %s", alert.TBS.Signer)
+		}
+		signers[alert.TBS.Signer] = true
+		if err := m.verifyAlertSignature(alert); err != nil {
+			return fmt.Errorf("failed to verify NonRespondingLogPOM: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package monitor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/signature"
+)
+
+type testPeer struct {
+	monitorID string
+	signer *signature.Signer
+}
+
+// Replace the monitor list with the monitor itself and numPeers newly generated peer monitors
+func mustUsePeers(t *testing.T, m *Monitor, numPeers int) []testPeer {
+	t.Helper()
+	self := m.MonitorList.FindMonitorByMonitorID(m.MonitorID)
+	operator := &entitylist.MonitorOperator{Name: "test", Monitors: []*entitylist.MonitorInfo{self}}
+	var peers []testPeer
+	for i := 0; i < numPeers; i++ {
+		privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate peer key: %v", err)
+		}
+		derPrivKey, err := x509.MarshalECPrivateKey(privKey)
+		if err != nil {
+			t.Fatalf("failed to marshal peer private key: %v", err)
+		}
+		derPubKey, err := x509.MarshalPKIXPublicKey(&privKey.PublicKey)
+		if err != nil {
+			t.Fatalf("failed to marshal peer public key: %v", err)
+		}
+		signer, err := signature.NewSigner(base64.StdEncoding.EncodeToString(derPrivKey))
+		if err != nil {
+			t.Fatalf("failed to create peer signer: %v", err)
+		}
+		monitorIDHash := sha256.Sum256(derPubKey)
+		monitorID := base64.StdEncoding.EncodeToString(monitorIDHash[:])
+		operator.Monitors = append(operator.Monitors, &entitylist.MonitorInfo{MonitorID: monitorID, MonitorKey: base64.StdEncoding.EncodeToString(derPubKey)})
+		peers = append(peers, testPeer{monitorID, signer})
+	}
+	m.MonitorList = &entitylist.MonitorList{MonitorOperators: []*entitylist.MonitorOperator{operator}}
+	return peers
+}
+
+func mustGetAlertingMonitor(t *testing.T, numPeers int) (*Monitor, []testPeer) {
+	t.Helper()
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return monitor, mustUsePeers(t, monitor, numPeers)
+}
+
+func mustCreatePeerAlert(t *testing.T, peer testPeer, timestamp uint64) *mtr.CTObject {
+	t.Helper()
+	alertCT, err := mtr.CreateAlert(peer.signer, peer.monitorID, testLogID, timestamp)
+	if err != nil {
+		t.Fatalf("failed to create peer Alert: %v", err)
+	}
+	return alertCT
+}
+
+func TestAlertNonRespondingLogCreatesPOMAtThreshold(t *testing.T) {
+	monitor, peers := mustGetAlertingMonitor(t, 2)
+	mmdEnd := time.Unix(1000, 0)
+	alertCT, err := monitor.AlertNonRespondingLog(testLogID, mmdEnd)
+	if err != nil {
+		t.Fatalf("failed to alert nonresponding log: %v", err)
+	}
+	if err := monitor.VerifyCTObject(alertCT); err != nil {
+		t.Fatalf("monitor Alert failed verification: %v", err)
+	}
+	pomID := mtr.ObjectIdentifier{First: mtr.NonRespondingLogPOMTypeID, Second: testLogID, Third: alertCT.Timestamp, Fourth: alertCT.Version.String()}
+	if monitor.GetEntry(pomID) != nil {
+		t.Fatalf("PoM created from a single Alert with 3 monitors")
+	}
+
+	// A second Alert from a peer reaches the majority of 3 monitors
+	peerAlertCT := mustCreatePeerAlert(t, peers[0], alertCT.Timestamp)
+	if err := monitor.VerifyCTObject(peerAlertCT); err != nil {
+		t.Fatalf("peer Alert failed verification: %v", err)
+	}
+	monitor.AddEntry(peerAlertCT)
+	pom, err := monitor.ProcessAlert(peerAlertCT)
+	if err != nil {
+		t.Fatalf("failed to process peer Alert: %v", err)
+	}
+	if pom == nil || monitor.GetEntry(pomID) == nil {
+		t.Fatalf("PoM not created after reaching Alert threshold")
+	}
+	if err := monitor.VerifyCTObject(pom); err != nil {
+		t.Fatalf("NonRespondingLogPOM failed verification: %v", err)
+	}
+
+	// The PoM is only created once
+	lateAlertCT := mustCreatePeerAlert(t, peers[1], alertCT.Timestamp)
+	monitor.AddEntry(lateAlertCT)
+	if pom, _ := monitor.ProcessAlert(lateAlertCT); pom != nil {
+		t.Fatalf("PoM created a second time")
+	}
+}
+
+func TestVerifyCTObjectRejectsForgedAlert(t *testing.T) {
+	monitor, peers := mustGetAlertingMonitor(t, 1)
+
+	// Alert signed by the peer claiming to be from the monitor
+	forgedAlertCT, err := mtr.CreateAlert(peers[0].signer, monitor.MonitorID, testLogID, 1000)
+	if err != nil {
+		t.Fatalf("failed to create Alert: %v", err)
+	}
+	if err := monitor.VerifyCTObject(forgedAlertCT); err == nil {
+		t.Fatalf("forged Alert passed verification")
+	}
+
+	// Alert from a monitor that isn't in the monitor list
+	unknownAlertCT, err := mtr.CreateAlert(peers[0].signer, "unknownmonitor", testLogID, 1000)
+	if err != nil {
+		t.Fatalf("failed to create Alert: %v", err)
+	}
+	if err := monitor.VerifyCTObject(unknownAlertCT); err == nil {
+		t.Fatalf("Alert from unknown monitor passed verification")
+	}
+}
+
+func TestVerifyCTObjectRejectsPOMBelowThreshold(t *testing.T) {
+	monitor, peers := mustGetAlertingMonitor(t, 2)
+	pom, err := mtr.CreateNonRespondingLogPOM([]*mtr.CTObject{mustCreatePeerAlert(t, peers[0], 1000)})
+	if err != nil {
+		t.Fatalf("failed to create NonRespondingLogPOM: %v", err)
+	}
+	if err := monitor.VerifyCTObject(pom); err == nil {
+		t.Fatalf("NonRespondingLogPOM with 1 of 3 Alerts passed verification")
+	}
+}
+
+func TestSchedulerAlertsNonRespondingLog(t *testing.T) {
+	monitor, _ := mustGetAlertingMonitor(t, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	logClient, err := mtr.NewLogClient(&entitylist.LogInfo{LogID: testLogID, Key: testMonitorKey, URL: server.URL, MMD: 1})
+	if err != nil {
+		t.Fatalf("failed to create LogClient: %v", err)
+	}
+	monitor.LogIDMap = map[string]*mtr.LogClient{testLogID: logClient}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := NewScheduler(monitor)
+	scheduler.retryInterval = 100 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The only monitor in the list is the monitor itself, so its Alert alone creates the PoM
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(monitor.Storage.GetEntries(mtr.NonRespondingLogPOMTypeID, testLogID)) > 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("no NonRespondingLogPOM created for log that never served an STH")
+}
@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+// Add the domain to the monitor's watchlist and return the matching log entries found so far
+func (m *Monitor) MonitorDomain(domain string) (*mtr.MonitorDomainResponse, error) {
+	domain, err := m.Watchlist.AddDomain(domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to monitor domain: %w", err)
+	}
+	glog.Infof("monitoring domain %s", domain)
+	return &mtr.MonitorDomainResponse{Domain: domain, Matches: m.Watchlist.GetMatches(domain)}, nil
+}
+
+// Record the certificates within consecutive entries of the log starting at leaf index start that name a watched domain
+func (m *Monitor) matchWatchedDomains(logID string, start uint64, entries []ct.LeafEntry) error {
+	if len(m.Watchlist.Domains()) == 0 {
+		return nil
+	}
+	var matches []mtr.DomainMatch
+	for i := range entries {
+		matches = append(matches, m.matchEntry(logID, start + uint64(i), &entries[i])...)
+	}
+	if err := m.Watchlist.AddMatches(matches); err != nil {
+		return fmt.Errorf("failed to record domain matches: %w", err)
+	}
+	return nil
+}
+
+// Get the matches of the certificate or precertificate within the log entry against the watched domains
+func (m *Monitor) matchEntry(logID string, index uint64, leafEntry *ct.LeafEntry) []mtr.DomainMatch {
+	entry, err := ct.LogEntryFromLeaf(int64(index), leafEntry)
+	if x509.IsFatal(err) {
+		glog.Warningf("failed to parse entry %d from Logger %s: %v", index, logID, err)
+		return nil
+	}
+	var cert *x509.Certificate
+	isPrecert := false
+	if entry.Precert != nil {
+		cert = entry.Precert.TBSCertificate
+		isPrecert = true
+	} else {
+		cert = entry.X509Cert
+	}
+	if cert == nil {
+		return nil
+	}
+
+	names := certificateNames(cert)
+	var matches []mtr.DomainMatch
+	for _, domain := range m.Watchlist.MatchNames(names) {
+		matches = append(matches, mtr.DomainMatch{
+			Domain: domain,
+			LogID: logID,
+			LeafIndex: index,
+			Timestamp: entry.Leaf.TimestampedEntry.Timestamp,
+			IsPrecert: isPrecert,
+			Names: names,
+			SerialNumber: cert.SerialNumber.Text(16),
+		})
+	}
+	return matches
+}
+
+// Get the subject CommonName and DNS SANs of the certificate
+func certificateNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return append(names, cert.DNSNames...)
+}
@@ -0,0 +1,130 @@
+package monitor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/testutil/fakelog"
+)
+
+// Create a DER encoded self-signed certificate for the given names
+func mustCreateCertificate(t *testing.T, serial int64, commonName string, dnsNames []string) []byte {
+	t.Helper()
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate certificate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privKey.PublicKey, privKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return der
+}
+
+// Create a get-entries LeafEntry holding the certificate, or its TBSCertificate when isPrecert is set
+func mustCreateLeafEntry(t *testing.T, der []byte, isPrecert bool, timestamp uint64) ct.LeafEntry {
+	t.Helper()
+	var leaf *ct.MerkleTreeLeaf
+	var extraData []byte
+	var err error
+	if isPrecert {
+		cert, parseErr := x509.ParseCertificate(der)
+		if parseErr != nil {
+			t.Fatalf("failed to parse certificate: %v", parseErr)
+		}
+		leaf = &ct.MerkleTreeLeaf{
+			Version:  ct.V1,
+			LeafType: ct.TimestampedEntryLeafType,
+			TimestampedEntry: &ct.TimestampedEntry{
+				Timestamp:    timestamp,
+				EntryType:    ct.PrecertLogEntryType,
+				PrecertEntry: &ct.PreCert{TBSCertificate: cert.RawTBSCertificate},
+			},
+		}
+		extraData, err = tls.Marshal(ct.PrecertChainEntry{PreCertificate: ct.ASN1Cert{Data: der}})
+	} else {
+		leaf = ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: der}, timestamp)
+		extraData, err = tls.Marshal(ct.CertificateChain{})
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal entry extra data: %v", err)
+	}
+	leafInput, err := tls.Marshal(*leaf)
+	if err != nil {
+		t.Fatalf("failed to marshal MerkleTreeLeaf: %v", err)
+	}
+	return ct.LeafEntry{LeafInput: leafInput, ExtraData: extraData}
+}
+
+// Point testLogID at a fake log holding the given entries that serves at most maxEntries at a time
+// The log key is replaced by the monitor key so STHs created by the test verify
+func mustUseEntriesServer(t *testing.T, m *Monitor, entries []ct.LeafEntry, maxEntries int) {
+	t.Helper()
+	log := fakelog.New(t)
+	log.MaxGetEntries = maxEntries
+	log.AddLeafEntries(entries...)
+	logClient, err := mtr.NewLogClient(&entitylist.LogInfo{LogID: testLogID, Key: testMonitorKey, URL: log.URL()})
+	if err != nil {
+		t.Fatalf("failed to create LogClient: %v", err)
+	}
+	m.LogIDMap[testLogID] = logClient
+}
+
+func TestTailerMatchesWatchedDomains(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	entries := []ct.LeafEntry{
+		mustCreateLeafEntry(t, mustCreateCertificate(t, 1, "other.org", nil), false, 1000),
+		mustCreateLeafEntry(t, mustCreateCertificate(t, 2, "", []string{"www.example.com"}), false, 1001),
+		mustCreateLeafEntry(t, mustCreateCertificate(t, 3, "example.net", []string{"a.b.example.com"}), true, 1002),
+		mustCreateLeafEntry(t, mustCreateCertificate(t, 4, "example.com", nil), false, 1003),
+	}
+	mustUseEntriesServer(t, monitor, entries, 2)
+	if _, err := monitor.MonitorDomain("*.example.com"); err != nil {
+		t.Fatalf("failed to monitor domain: %v", err)
+	}
+
+	if err := NewTailer(monitor).TailLog(context.Background(), testLogID, uint64(len(entries))); err != nil {
+		t.Fatalf("failed to tail entries: %v", err)
+	}
+	matches := monitor.Watchlist.GetMatches("*.example.com")
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, expected 2: %v", len(matches), matches)
+	}
+	if matches[0].LeafIndex != 1 || matches[0].IsPrecert || matches[0].Timestamp != 1001 {
+		t.Fatalf("first match (%v) should be the certificate at leaf index 1", matches[0])
+	}
+	if matches[1].LeafIndex != 2 || !matches[1].IsPrecert || matches[1].LogID != testLogID || matches[1].SerialNumber != "3" {
+		t.Fatalf("second match (%v) should be the precertificate at leaf index 2", matches[1])
+	}
+
+	// Matches are recorded and returned to later requests for the domain
+	domainResp, err := monitor.MonitorDomain("*.EXAMPLE.com")
+	if err != nil {
+		t.Fatalf("failed to monitor domain: %v", err)
+	}
+	if domainResp.Domain != "*.example.com" || len(domainResp.Matches) != 2 {
+		t.Fatalf("MonitorDomain response (%v) doesn't hold the 2 recorded matches", domainResp)
+	}
+}
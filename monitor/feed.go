@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+const (
+	// Recently stored CTObjects kept for subscribers to resume from when the monitor config doesn't give a capacity
+	defaultFeedCapacity = 1024
+
+	// Events buffered for a subscriber before it is dropped for falling behind
+	subscriptionBuffer = 256
+)
+
+// Error returned by Subscribe when the events after the cursor are no longer kept by the Feed
+// Either the monitor restarted since the cursor was given out or too many CTObjects were stored since
+var ErrCursorExpired = errors.New("cursor expired")
+
+// FeedEvent is a CTObject newly stored by the monitor along with the cursor to resume the Feed after it
+type FeedEvent struct {
+	Cursor 		string
+	CTObject 	*mtr.CTObject
+}
+
+// Feed publishes every CTObject newly stored by the monitor to its subscribers
+// It keeps the latest events so subscribers can resume from the cursor of the last event they received
+// Cursors hold the epoch of the Feed so cursors from before a restart are recognized as expired
+type Feed struct {
+	mu sync.Mutex
+	epoch string
+	capacity int
+	events []FeedEvent	// Latest events in the order they were published
+	firstSeq uint64	// Sequence number of events[0]
+	subscribers map[*Subscription]bool
+}
+
+// Subscription receives the events of a Feed whose CTObjects have one of its TypeIDs
+// Events is closed once the Subscription is closed or falls too far behind the Feed
+type Subscription struct {
+	Events <-chan FeedEvent
+	events chan FeedEvent
+	typeIDs map[string]bool	// Empty receives every TypeID
+}
+
+// Create a Feed that keeps the given number of latest events. A capacity of 0 keeps the default number
+func NewFeed(capacity int) *Feed {
+	if capacity <= 0 {
+		capacity = defaultFeedCapacity
+	}
+	return &Feed{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 10),
+		capacity: capacity,
+		firstSeq: 1,
+		subscribers: make(map[*Subscription]bool),
+	}
+}
+
+// Publish the CTObject to every subscriber of its TypeID
+// Subscribers that can't keep up are dropped so they resume from their cursor instead of blocking the monitor
+func (f *Feed) Publish(ctObject *mtr.CTObject) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	event := FeedEvent{Cursor: f.cursor(f.firstSeq + uint64(len(f.events))), CTObject: ctObject}
+	f.events = append(f.events, event)
+	if len(f.events) > f.capacity {
+		f.events = f.events[1:]
+		f.firstSeq++
+	}
+	for sub := range f.subscribers {
+		if !sub.matches(ctObject) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			f.unsubscribe(sub)
+		}
+	}
+}
+
+// Subscribe to the CTObjects with the given TypeIDs. No TypeIDs subscribes to every CTObject
+// Returns the kept events after the cursor, which the Subscription won't receive again. An empty cursor returns no events
+func (f *Feed) Subscribe(cursor string, typeIDs []string) ([]FeedEvent, *Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub := &Subscription{events: make(chan FeedEvent, subscriptionBuffer), typeIDs: make(map[string]bool)}
+	sub.Events = sub.events
+	for _, typeID := range typeIDs {
+		sub.typeIDs[typeID] = true
+	}
+
+	var replay []FeedEvent
+	if cursor != "" {
+		seq, err := f.parseCursor(cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		nextSeq := f.firstSeq + uint64(len(f.events))
+		if seq >= nextSeq || seq + 1 < f.firstSeq {
+			return nil, nil, fmt.Errorf("events after cursor %s aren't kept: %w", cursor, ErrCursorExpired)
+		}
+		for _, event := range f.events[seq + 1 - f.firstSeq:] {
+			if sub.matches(event.CTObject) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	f.subscribers[sub] = true
+	return replay, sub, nil
+}
+
+// Stop the Subscription from receiving events
+func (f *Feed) Unsubscribe(sub *Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unsubscribe(sub)
+}
+
+// Remove the subscriber and close its events. Must be called with the lock held
+func (f *Feed) unsubscribe(sub *Subscription) {
+	if f.subscribers[sub] {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Get the cursor of the event with the given sequence number
+func (f *Feed) cursor(seq uint64) string {
+	return f.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// Get the sequence number of the event with the given cursor
+func (f *Feed) parseCursor(cursor string) (uint64, error) {
+	i := strings.LastIndex(cursor, "-")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	seq, err := strconv.ParseUint(cursor[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %s", cursor)
+	}
+	if cursor[:i] != f.epoch {
+		return 0, fmt.Errorf("cursor %s is from before the monitor restarted: %w", cursor, ErrCursorExpired)
+	}
+	return seq, nil
+}
+
+// Check whether the Subscription receives the CTObject
+func (s *Subscription) matches(ctObject *mtr.CTObject) bool {
+	return len(s.typeIDs) == 0 || s.typeIDs[ctObject.TypeID]
+}
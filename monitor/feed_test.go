@@ -0,0 +1,108 @@
+package monitor
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+func createFeedCTObject(typeID string, timestamp uint64) *mtr.CTObject {
+	return &mtr.CTObject{TypeID: typeID, Version: mtr.VersionData{Major: 1}, Timestamp: timestamp, Signer: testLogID}
+}
+
+func TestFeedResumesFromCursor(t *testing.T) {
+	feed := NewFeed(3)
+	_, sub, err := feed.Subscribe("", []string{mtr.ConflictingSTHPOMTypeID})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	feed.Publish(createFeedCTObject(mtr.STHTypeID, 1))
+	feed.Publish(createFeedCTObject(mtr.ConflictingSTHPOMTypeID, 2))
+	event := <-sub.Events
+	if event.CTObject.TypeID != mtr.ConflictingSTHPOMTypeID || len(sub.Events) != 0 {
+		t.Fatalf("subscription to PoMs received %s CTObject", event.CTObject.TypeID)
+	}
+
+	// Resuming from the PoM replays the CTObjects published after it
+	feed.Publish(createFeedCTObject(mtr.STHTypeID, 3))
+	feed.Publish(createFeedCTObject(mtr.AlertTypeID, 4))
+	replay, _, err := feed.Subscribe(event.Cursor, nil)
+	if err != nil {
+		t.Fatalf("failed to resume from cursor: %v", err)
+	}
+	if len(replay) != 2 || replay[0].CTObject.Timestamp != 3 || replay[1].CTObject.Timestamp != 4 {
+		t.Fatalf("resumed feed replayed %v, expected the CTObjects at 3 and 4", replay)
+	}
+
+	// Only the latest 3 CTObjects are kept
+	feed.Publish(createFeedCTObject(mtr.STHTypeID, 5))
+	feed.Publish(createFeedCTObject(mtr.STHTypeID, 6))
+	if _, _, err := feed.Subscribe(event.Cursor, nil); !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("resumed from cursor of CTObject no longer kept: %v", err)
+	}
+	if _, _, err := NewFeed(3).Subscribe(replay[1].Cursor, nil); !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("resumed from cursor of another Feed: %v", err)
+	}
+}
+
+func TestFeedDropsSlowSubscriber(t *testing.T) {
+	feed := NewFeed(0)
+	_, sub, err := feed.Subscribe("", nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	for i := 0; i <= subscriptionBuffer; i++ {
+		feed.Publish(createFeedCTObject(mtr.STHTypeID, uint64(i)))
+	}
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Fatalf("slow subscriber received %d CTObjects before being dropped, expected %d", received, subscriptionBuffer)
+	}
+}
+
+func TestAddEntryPublishesNewCTObjects(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, sub, err := monitor.Feed.Subscribe("", nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	alert := createFeedCTObject(mtr.AlertTypeID, 1)
+	monitor.AddEntry(alert)
+	monitor.AddEntry(alert)
+	if len(sub.Events) != 1 {
+		t.Fatalf("storing the same CTObject twice published %d events, expected 1", len(sub.Events))
+	}
+}
+
+// Run with -race to check that storing the same CTObject at once publishes it once
+func TestConcurrentAddEntryPublishesOnce(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, sub, err := monitor.Feed.Subscribe("", nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	alert := createFeedCTObject(mtr.AlertTypeID, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			monitor.AddEntry(alert)
+		}()
+	}
+	wg.Wait()
+	if len(sub.Events) != 1 {
+		t.Fatalf("storing the same CTObject at once published %d events, expected 1", len(sub.Events))
+	}
+}
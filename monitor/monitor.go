@@ -2,59 +2,461 @@ package monitor
 
 import (
 	"fmt"
+	"context"
 	"bytes"
-	"strings"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/certificate-transparency-go/tls"
 	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/caclient"
 	"github.com/n-ct/ct-monitor/entitylist"
-	"github.com/n-ct/ct-monitor/utils"
+	"github.com/n-ct/ct-monitor/gossip"
 	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/storage"
+	"github.com/n-ct/ct-monitor/watchlist"
+)
+
+// Time a log has to serve the ConsistencyProof that confirms an InconsistentSTHPOM received from another monitor
+const defaultLogRequestTimeout = 30 * time.Second
+
+// Error returned by VerifySTHWithPOC when the ConsistencyProof of an STH_POC fails to verify but the log doesn't confirm the inconsistency
+// The proof may have been corrupted after the log served it, so the STH_POC is rejected without a PoM
+var ErrInvalidConsistencyProof = errors.New("invalid ConsistencyProof")
 
-	ctca "github.com/n-ct/ct-certificate-authority"
+// Errors returned by AuditInclusion that separate a request that can't be audited and a leaf the log doesn't prove is included
+// from failures of the monitor or the log
+var (
+	ErrInvalidInclusionAuditRequest = errors.New("invalid InclusionAudit request")
+	ErrNotIncluded = errors.New("leaf not included")
 )
 
 type Monitor struct {
 	LogIDMap map[string] *mtr.LogClient
+	LogList *entitylist.LogList
+	CAList *entitylist.CAList
+	CAIDMap map[string] *caclient.CAClient	// CAs whose SRDs the monitor fetches
 	MonitorList	*entitylist.MonitorList
 	GossiperURL string 
 	ListenAddress string 
-	CTObjectMap map[string]map[string]map[uint64]map[string] *mtr.CTObject
+	Storage storage.Storage
 	Signer *signature.Signer
+	Gossiper gossip.Gossiper	// Delivers gossiped CTObjects to the GossiperURL or directly to the peer monitors
+	MonitorID string	// EntityID of the Monitor found in the monitor list
+	AlertThreshold int	// Number of Alerts from different monitors needed for a NonRespondingLogPOM. 0 uses a majority of the monitor list
+	alertLock sync.Mutex	// Serializes checking the Alert threshold so a PoM is only gossiped once
+	Watchlist *watchlist.Watchlist
+	Checkpoints *storage.Checkpoints	// Number of entries of each log processed by the Tailer
+	TailFromLatestSTH bool	// Start tailing logs without a checkpoint at the tree size of their latest STH instead of their first entry
+	AllowUnauthenticatedNewInfo bool	// Accept NewInfo requests that aren't signed by a monitor in the monitor list. See gossip.SignRequest
+	RevocationVectors *storage.RevocationVectors	// CRV of each CA reconstructed from its CRVDeltas
+	revocationLock sync.Mutex	// Serializes applying CRVDeltas so each CA's deltas are applied in order
+	sthLock sync.Mutex	// Serializes storing STHs so conflicting STHs arriving at once are detected
+	srdLock sync.Mutex	// Serializes storing SRDs so conflicting SRDs arriving at once are detected
+	entryLock sync.Mutex	// Serializes storing CTObjects so each newly stored CTObject is published to the Feed once
+	Feed *Feed	// Publishes newly stored CTObjects to streaming clients
 }
 
 // Create a new Monitor using the createMonitor function found in monitor_setup.go
-func NewMonitor(monitorConfigName string, monitorListName string, logListName string) (*Monitor, error){
-	return createMonitor(monitorConfigName, monitorListName, logListName)
+func NewMonitor(monitorConfigName string, monitorListName string, logListName string, caListName string) (*Monitor, error){
+	return createMonitor(monitorConfigName, monitorListName, logListName, caListName)
 }
 
-// Make a post request to corresponding GossiperURL with the given ctObject
+// Queue the ctObject to be gossiped to the peers of the monitor through its gossiper
 func (m *Monitor) Gossip(ctObject *mtr.CTObject) error {
-	jsonBytes, err := json.Marshal(ctObject)	// Just use serialize method somewhere else
+	if err := m.Gossiper.Gossip(ctObject); err != nil {
+		glog.Errorf("failed to gossip %s CTObject: %v", ctObject.TypeID, err)
+		return err
+	}
+	return nil
+}
+
+// Verify the signatures found within the given CTObject before it is stored, audited, or gossiped
+func (m *Monitor) VerifyCTObject(ctObject *mtr.CTObject) error {
+	var err error
+	switch ctObject.TypeID {
+	case mtr.STHTypeID, mtr.STHPOCTypeID:
+		err = m.verifySTH(ctObject)
+	case mtr.AlertTypeID:
+		err = m.verifyAlert(ctObject)
+	case mtr.NonRespondingLogPOMTypeID:
+		err = m.verifyNonRespondingLogPOM(ctObject)
+	case mtr.InconsistentSTHPOMTypeID:
+		err = m.verifyInconsistentSTHPOM(ctObject)
+	case mtr.MismatchedRootPOMTypeID:
+		// The CompactRange isn't signed by the log, so only the monitor that hashed the entries the log served can trust it
+		err = fmt.Errorf("MismatchedRootPOMs from other monitors can't be verified")
+	case mtr.ConflictingSTHPOMTypeID:
+		err = m.verifyConflictingSTHPOM(ctObject)
+	case mtr.NonMonotonicSTHPOMTypeID:
+		err = m.verifyNonMonotonicSTHPOM(ctObject)
+	case mtr.SRDTypeID, mtr.SRDWithRevDataTypeID:
+		err = m.verifySRD(ctObject)
+	case mtr.InconsistentCRVPOMTypeID:
+		err = m.verifyInconsistentCRVPOM(ctObject)
+	}
+	if err != nil {
+		glog.Warningf("rejected %s CTObject from signer %s: %v", ctObject.TypeID, ctObject.Signer, err)
+		return err
+	}
+	return nil
+}
+
+// Verify the STH within STH and STH_POC CTObjects against the key of the log found in the log list
+func (m *Monitor) verifySTH(ctObject *mtr.CTObject) error {
+	sth, err := ctObject.DeconstructSTH()
+	if err != nil {
+		return fmt.Errorf("failed to verify STH: %w", err)
+	}
+	if sth.LogID != ctObject.Signer {
+		return fmt.Errorf("STH LogID (%s) doesn't match CTObject signer (%s)", sth.LogID, ctObject.Signer)
+	}
+	if ctObject.Timestamp != sth.TreeHeadData.Timestamp {
+		return fmt.Errorf("STH timestamp (%d) doesn't match CTObject timestamp (%d)", sth.TreeHeadData.Timestamp, ctObject.Timestamp)
+	}
+	if ctObject.Subject != "" {
+		return fmt.Errorf("STH CTObject has subject (%s)", ctObject.Subject)
+	}
+	if err := verifyDigest(ctObject, sth.Signature.Algorithm.Hash); err != nil {
+		return fmt.Errorf("failed to verify STH: %w", err)
+	}
+	logKey, err := m.getLogKey(sth.LogID)
+	if err != nil {
+		return fmt.Errorf("failed to verify STH: %w", err)
+	}
+	return mtr.VerifySTHSignature(sth, logKey)
+}
+
+// Verify the digest of the CTObject is the hash of its blob
+func verifyDigest(ctObject *mtr.CTObject, hashAlgo tls.HashAlgorithm) error {
+	digest, _, err := signature.GenerateHash(hashAlgo, ctObject.Blob)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, ctObject.Digest) {
+		return fmt.Errorf("%s CTObject digest doesn't match its blob", ctObject.TypeID)
+	}
+	return nil
+}
+
+// Get the public key of the log with the given logID
+func (m *Monitor) getLogKey(logID string) (string, error) {
+	if logClient, ok := m.LogIDMap[logID]; ok {
+		return logClient.LogInfo.Key, nil
+	}
+	logInfo := m.LogList.FindLogByLogID(logID)
+	if logInfo == nil {
+		return "", fmt.Errorf("LogID (%s) not found in log list", logID)
+	}
+	return logInfo.Key, nil
+}
+
+// Verify the SRD within SRD and SRD_REVDATA CTObjects against the key of the issuing CA found in the CA list
+func (m *Monitor) verifySRD(ctObject *mtr.CTObject) error {
+	srd, err := ctObject.DeconstructSRD()
+	if err != nil {
+		return fmt.Errorf("failed to verify SRD: %w", err)
+	}
+	if srd.EntityID != ctObject.Signer {
+		return fmt.Errorf("SRD EntityID (%s) doesn't match CTObject signer (%s)", srd.EntityID, ctObject.Signer)
+	}
+	if ctObject.Timestamp != srd.RevDigest.Timestamp {
+		return fmt.Errorf("SRD timestamp (%d) doesn't match CTObject timestamp (%d)", srd.RevDigest.Timestamp, ctObject.Timestamp)
+	}
+	if err := verifyDigest(ctObject, srd.Signature.Algorithm.Hash); err != nil {
+		return fmt.Errorf("failed to verify SRD: %w", err)
+	}
+	if ctObject.TypeID == mtr.SRDWithRevDataTypeID {
+		revData, err := ctObject.DeconstructRevData()
+		if err != nil {
+			return fmt.Errorf("failed to verify SRD: %w", err)
+		}
+		if revData.EntityID != srd.EntityID || revData.Timestamp != srd.RevDigest.Timestamp {
+			return fmt.Errorf("RevData of CA %s at timestamp %d doesn't belong to SRD of CA %s at timestamp %d", revData.EntityID, revData.Timestamp, srd.EntityID, srd.RevDigest.Timestamp)
+		}
+	}
+	caKey, err := m.getCAKey(srd.EntityID)
+	if err != nil {
+		return fmt.Errorf("failed to verify SRD: %w", err)
+	}
+	return mtr.VerifySRDSignature(srd, caKey)
+}
+
+// Get the public key of the CA with the given caID
+func (m *Monitor) getCAKey(caID string) (string, error) {
+	caInfo := m.CAList.FindCAByCAID(caID)
+	if caInfo == nil {
+		return "", fmt.Errorf("CAID (%s) not found in CA list", caID)
+	}
+	return caInfo.CAKey, nil
+}
+
+// Verify the ConsistencyProof within the STH_POC CTObject against the STHs the monitor has seen at both tree sizes
+// A proof that fails to verify is fetched again from the log, since only the STHs are signed by the log
+// Returns an InconsistentSTHPOM CTObject holding the log's proof if that also fails to verify and nil if the proof is valid
+// An error is returned when the proof can't be checked because the monitor hasn't seen one of the STHs,
+// and an error wrapping ErrInvalidConsistencyProof when the proof fails to verify but the log's doesn't
+func (m *Monitor) VerifySTHWithPOC(ctx context.Context, ctObject *mtr.CTObject) (*mtr.CTObject, error) {
+	if ctObject.TypeID != mtr.STHPOCTypeID {
+		return nil, fmt.Errorf("can't verify ConsistencyProof of %s CTObject", ctObject.TypeID)
+	}
+	sth, err := ctObject.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify STHWithPOC: %w", err)
+	}
+	poc, err := ctObject.DeconstructPOC()
 	if err != nil {
-		return fmt.Errorf("failed to marshal %s ctobject when gossiping: %v", ctObject.TypeID, err)
+		return nil, fmt.Errorf("failed to verify STHWithPOC: %w", err)
+	}
+	if poc.LogID != sth.LogID {
+		return nil, fmt.Errorf("ConsistencyProof LogID (%s) doesn't match STH LogID (%s)", poc.LogID, sth.LogID)
 	}
-	gossipURL := utils.CreateRequestURL(m.GossiperURL, "/ct/v1/gossip")
-	glog.Infof("\ngossip CTObject using Gossiper at address: %s", gossipURL)
 
-	// Create request
-	req, err := http.NewRequest("POST", gossipURL, bytes.NewBuffer(jsonBytes)) 
-	req.Header.Set("X-Custom-Header", "myvalue");
-	req.Header.Set("Content-Type", "application/json");
+	// Find the STHs at both ends of the ConsistencyProof
+	sth1CT, err := m.GetSTHEntryByTreeSize(poc.LogID, poc.TreeSize1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify STHWithPOC: %w", err)
+	}
+	if sth1CT == nil {
+		return nil, fmt.Errorf("no STH of tree size %d from Logger %s in monitor to verify ConsistencyProof", poc.TreeSize1, poc.LogID)
+	}
+	sth2CT := ctObject
+	if sth.TreeHeadData.TreeSize != poc.TreeSize2 {
+		sth2CT, err = m.GetSTHEntryByTreeSize(poc.LogID, poc.TreeSize2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify STHWithPOC: %w", err)
+		}
+		if sth2CT == nil {
+			return nil, fmt.Errorf("no STH of tree size %d from Logger %s in monitor to verify ConsistencyProof", poc.TreeSize2, poc.LogID)
+		}
+	}
+	sth1, err := sth1CT.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify STHWithPOC: %w", err)
+	}
+	sth2, err := sth2CT.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify STHWithPOC: %w", err)
+	}
 
-	// Send request
-	client := &http.Client{};
-	resp, err := client.Do(req);
+	// Create PoM if neither the proof nor the log's own proof link the two root hashes
+	if err := mtr.VerifySTHConsistency(sth1, sth2, poc); err != nil {
+		logPOC, confirmErr := m.confirmInconsistentSTHs(ctx, sth1, sth2)
+		if confirmErr != nil {
+			return nil, fmt.Errorf("%w from Logger %s: %v: %v", ErrInvalidConsistencyProof, poc.LogID, err, confirmErr)
+		}
+		glog.Warningf("inconsistent STHs from Logger %s: %v", poc.LogID, err)
+		pom, err := mtr.CreateInconsistentSTHPOM(sth1CT, sth2CT, logPOC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PoM for inconsistent STHs: %w", err)
+		}
+		return pom, nil
+	}
+	return nil, nil
+}
+
+// Fetch the ConsistencyProof between the tree sizes of the two STHs from their log
+// Returns the proof if it fails to link the root hashes of the STHs, and an error if the log can't be reached or its proof verifies
+func (m *Monitor) confirmInconsistentSTHs(ctx context.Context, sth1 *mtr.SignedTreeHeadData, sth2 *mtr.SignedTreeHeadData) (*mtr.ConsistencyProofData, error) {
+	logClient, err := m.getLogClient(sth1.LogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm inconsistent STHs: %w", err)
+	}
+	logPOC, err := logClient.GetConsistencyProof(ctx, sth1.TreeHeadData.TreeSize, sth2.TreeHeadData.TreeSize)
 	if err != nil {
-		panic(err);
+		return nil, fmt.Errorf("failed to confirm inconsistent STHs: %w", err)
 	}
+	if err := mtr.VerifySTHConsistency(sth1, sth2, logPOC); err == nil {
+		return nil, fmt.Errorf("Logger %s serves a valid ConsistencyProof between tree sizes %d and %d", sth1.LogID, logPOC.TreeSize1, logPOC.TreeSize2)
+	}
+	return logPOC, nil
+}
 
-	defer resp.Body.Close();
+// Verify the STHs within the InconsistentSTHPOM are both signed by the log and that neither its ConsistencyProof nor the log's links them
+// The ConsistencyProof isn't signed by the log, so the log is asked for its own proof before the PoM is accepted
+func (m *Monitor) verifyInconsistentSTHPOM(ctObject *mtr.CTObject) error {
+	pom, err := ctObject.DeconstructInconsistentSTHPOM()
+	if err != nil {
+		return fmt.Errorf("failed to verify InconsistentSTHPOM: %w", err)
+	}
+	if pom.STH1.LogID != pom.STH2.LogID || pom.STH1.LogID != pom.ConsistencyProof.LogID || pom.STH1.LogID != ctObject.Subject {
+		return fmt.Errorf("InconsistentSTHPOM STHs and ConsistencyProof aren't all from Logger %s", ctObject.Subject)
+	}
+	if pom.STH1.TreeHeadData.TreeSize > pom.STH2.TreeHeadData.TreeSize {
+		return fmt.Errorf("InconsistentSTHPOM STH1 tree size (%d) is larger than STH2 tree size (%d)", pom.STH1.TreeHeadData.TreeSize, pom.STH2.TreeHeadData.TreeSize)
+	}
+	if pom.ConsistencyProof.TreeSize1 != pom.STH1.TreeHeadData.TreeSize || pom.ConsistencyProof.TreeSize2 != pom.STH2.TreeHeadData.TreeSize {
+		return fmt.Errorf("InconsistentSTHPOM ConsistencyProof tree sizes don't match its STHs")
+	}
+	logKey, err := m.getLogKey(pom.STH1.LogID)
+	if err != nil {
+		return fmt.Errorf("failed to verify InconsistentSTHPOM: %w", err)
+	}
+	for _, sth := range []*mtr.SignedTreeHeadData{&pom.STH1, &pom.STH2} {
+		if err := mtr.VerifySTHSignature(sth, logKey); err != nil {
+			return fmt.Errorf("failed to verify InconsistentSTHPOM: %w", err)
+		}
+	}
+	if mtr.VerifySTHConsistency(&pom.STH1, &pom.STH2, &pom.ConsistencyProof) == nil {
+		return fmt.Errorf("InconsistentSTHPOM ConsistencyProof verifies")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultLogRequestTimeout)
+	defer cancel()
+	if _, err := m.confirmInconsistentSTHs(ctx, &pom.STH1, &pom.STH2); err != nil {
+		return fmt.Errorf("failed to verify InconsistentSTHPOM: %w", err)
+	}
 	return nil
 }
 
+// Check the root of the CompactRange of the entries the log served against the root hash of the STH of the same tree size
+// Returns a MismatchedRootPOM CTObject if the root hashes differ and nil if they match
+func (m *Monitor) VerifyEntriesRoot(sthCT *mtr.CTObject, compactRange *mtr.CompactRange) (*mtr.CTObject, error) {
+	sth, err := sthCT.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify entries root: %w", err)
+	}
+	if sth.TreeHeadData.TreeSize != compactRange.Size {
+		return nil, fmt.Errorf("STH tree size (%d) doesn't match number of entries (%d)", sth.TreeHeadData.TreeSize, compactRange.Size)
+	}
+	if bytes.Equal(compactRange.Root(), sth.TreeHeadData.SHA256RootHash[:]) {
+		return nil, nil
+	}
+	glog.Warningf("entries of Logger %s don't match root hash of STH of tree size %d", sth.LogID, compactRange.Size)
+	pom, err := mtr.CreateMismatchedRootPOM(sthCT, compactRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PoM for mismatched root: %w", err)
+	}
+	return pom, nil
+}
+
+// Audit the inclusion of a certificate or leaf hash within the tree of the given STH using an inclusion proof from the log
+// Returns an InclusionAuditOK CTObject signed by the monitor if the inclusion proof verifies
+// An error wrapping ErrInvalidInclusionAuditRequest is returned if the request can't be audited, and one wrapping ErrNotIncluded
+// if the log has no inclusion proof for the leaf or its proof doesn't lead to the root hash of the STH
+func (m *Monitor) AuditInclusion(ctx context.Context, auditReq *mtr.InclusionAuditRequest) (*mtr.CTObject, error) {
+	sthCT := &auditReq.STH
+	if sthCT.TypeID != mtr.STHTypeID && sthCT.TypeID != mtr.STHPOCTypeID {
+		return nil, fmt.Errorf("%w: can't audit inclusion against %s CTObject", ErrInvalidInclusionAuditRequest, sthCT.TypeID)
+	}
+	if err := m.VerifyCTObject(sthCT); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInclusionAuditRequest, err)
+	}
+	sth, err := sthCT.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInclusionAuditRequest, err)
+	}
+
+	// Get the leaf hash either directly or from the certificate
+	leafHash := auditReq.LeafHash
+	if len(leafHash) == 0 {
+		leafHash, err = leafHashForAuditRequest(auditReq)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInclusionAuditRequest, err)
+		}
+	}
+
+	// Fetch and verify the inclusion proof from the log. Logs answer with a client error for leaves they don't hold
+	logClient, err := m.getLogClient(sth.LogID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInclusionAuditRequest, err)
+	}
+	poi, err := logClient.GetProofByHash(ctx, leafHash, sth.TreeHeadData.TreeSize)
+	var rspErr mtr.RspError
+	if errors.As(err, &rspErr) && (rspErr.StatusCode == http.StatusBadRequest || rspErr.StatusCode == http.StatusNotFound) {
+		return nil, fmt.Errorf("%w in tree of size %d of Logger %s: %v", ErrNotIncluded, sth.TreeHeadData.TreeSize, sth.LogID, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to audit inclusion: %w", err)
+	}
+	if err := mtr.VerifySTHInclusion(sth, leafHash, poi); err != nil {
+		glog.Warningf("inclusion audit failed for Logger %s: %v", sth.LogID, err)
+		return nil, fmt.Errorf("%w in tree of size %d of Logger %s: %v", ErrNotIncluded, sth.TreeHeadData.TreeSize, sth.LogID, err)
+	}
+
+	auditResp, err := mtr.CreateInclusionAuditOK(m.Signer, sthCT, leafHash, poi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create InclusionAuditOK during audit: %w", err)
+	}
+	return auditResp, nil
+}
+
+// Compute the leaf hash of the certificate within the InclusionAuditRequest
+// Certificates logged as precertificate entries can only be hashed along with the certificate of their issuer
+func leafHashForAuditRequest(auditReq *mtr.InclusionAuditRequest) ([]byte, error) {
+	if len(auditReq.Certificate) == 0 {
+		return nil, fmt.Errorf("inclusion audit request needs either a LeafHash or a Certificate")
+	}
+	if len(auditReq.IssuerCertificate) > 0 {
+		return mtr.LeafHashForPrecertificate(auditReq.Certificate, auditReq.IssuerCertificate, auditReq.SCTTimestamp)
+	}
+	isPrecert, err := mtr.IsPrecertificateEntry(auditReq.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	if isPrecert {
+		return nil, fmt.Errorf("certificate is logged as a precertificate entry, which needs the IssuerCertificate")
+	}
+	return mtr.LeafHashForCertificate(auditReq.Certificate, auditReq.SCTTimestamp)
+}
+
+// Get the LogClient of the log with the given logID. Logs not monitored by the monitor are looked up in the log list
+func (m *Monitor) getLogClient(logID string) (*mtr.LogClient, error) {
+	if logClient, ok := m.LogIDMap[logID]; ok {
+		return logClient, nil
+	}
+	logInfo := m.LogList.FindLogByLogID(logID)
+	if logInfo == nil {
+		return nil, fmt.Errorf("LogID (%s) not found in log list", logID)
+	}
+	return mtr.NewLogClient(logInfo)
+}
+
+// Fetch the latest STH from the log along with a ConsistencyProof from the latest STH the monitor has stored for the log
+// The fetched object is verified, stored, and gossiped. If the ConsistencyProof is broken the PoM is stored and gossiped instead
+func (m *Monitor) FetchSTH(ctx context.Context, logID string) (*mtr.CTObject, error) {
+	logClient, ok := m.LogIDMap[logID]
+	if !ok {
+		return nil, fmt.Errorf("LogID (%s) not found in Monitor's LogIDMap", logID)
+	}
+	var ctObject *mtr.CTObject
+	var err error
+	prevSTHCT := m.GetLatestSTHEntry(logID)
+	if prevSTHCT == nil {
+		ctObject, err = logClient.GetSTH(ctx)
+	} else {
+		var prevSTH *mtr.SignedTreeHeadData
+		prevSTH, err = prevSTHCT.DeconstructSTH()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch STH: %w", err)
+		}
+		ctObject, err = logClient.GetSTHWithConsistencyProofFrom(ctx, prevSTH.TreeHeadData.TreeSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch STH: %w", err)
+	}
+
+	if ctObject.TypeID == mtr.STHPOCTypeID {
+		pom, err := m.VerifySTHWithPOC(ctx, ctObject)
+		if errors.Is(err, ErrInvalidConsistencyProof) {
+			return nil, fmt.Errorf("failed to fetch STH: %w", err)
+		}
+		if err != nil {
+			glog.Warningf("unable to verify ConsistencyProof of STHWithPOC from Logger %s: %v", logID, err)
+		}
+		if pom != nil {
+			ctObject = pom
+		}
+	}
+	if err := m.AddEntry(ctObject); err != nil {
+		return nil, fmt.Errorf("failed to fetch STH: %w", err)
+	}
+	m.Gossip(ctObject)
+	return ctObject, nil
+}
+
 // Given STHCTObject, get stored corresponding STH and audit
 func (m *Monitor) Audit(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
 	var err error
@@ -75,8 +477,12 @@ func (m *Monitor) auditSTH(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
 		return nil, fmt.Errorf("no corresponding STH in monitor to audit: %w", err)
 	}
 
-	// Compare the Digests of the two STHs
-	if !bytes.Equal(storedSTH.Digest, ctObject.Digest){
+	// Logs may sign the same tree head more than once, so only a different tree head conflicts
+	sameTreeHead, err := equalTreeHeads(storedSTH, ctObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare STHs during audit: %w", err)
+	}
+	if !sameTreeHead {
 		auditResp, err = mtr.CreateConflictingSTHPOM(storedSTH, ctObject) 
 		if err != nil {
 			return nil, fmt.Errorf("failed to create PoM during audit: %w", err)
@@ -93,14 +499,20 @@ func (m *Monitor) auditSTH(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
 // Given SRDCTObject, get stored corresponding SRD and audit
 func (m *Monitor) auditSRD(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
 	var auditResp *mtr.CTObject
-	// TODO IMPLMEENT THIS 
+	if err := m.verifySRD(ctObject); err != nil {
+		return nil, fmt.Errorf("refusing to audit unverified SRD: %w", err)
+	}
 	storedSRD, err := m.GetCorrespondingSRDEntry(ctObject)
 	if err != nil {
 		return nil, fmt.Errorf("no corresponding SRD in monitor to audit: %w", err)
 	}
 
-	// Compare the Digests of the two STHs
-	if !bytes.Equal(storedSRD.Digest, ctObject.Digest){
+	// CAs may sign the same RevocationDigest more than once and the RevData isn't signed, so only a different RevocationDigest conflicts
+	sameRevDigest, err := equalRevocationDigests(storedSRD, ctObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare SRDs during audit: %w", err)
+	}
+	if !sameRevDigest {
 		auditResp, err = mtr.CreateConflictingSRDPOM(storedSRD, ctObject) 
 		if err != nil {
 			return nil, fmt.Errorf("failed to create PoM during audit: %w", err)
@@ -114,10 +526,20 @@ func (m *Monitor) auditSRD(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
 	return auditResp, nil
 }
 
+// Release the resources held by the Monitor's storage
+func (m *Monitor) Close() error {
+	return m.Storage.Close()
+}
+
 // TODO Add support for alert ctObjects
 // TODO Think about this logic a little more
 func (m *Monitor) GetEntry(identifier mtr.ObjectIdentifier) *mtr.CTObject {
-	return m.CTObjectMap[identifier.First][identifier.Second][identifier.Third][identifier.Fourth]
+	return m.Storage.GetEntry(identifier)
+}
+
+// Get every CTObject stored within the monitor matching the query, ordered by timestamp
+func (m *Monitor) FindEntries(query *storage.Query) []*mtr.CTObject {
+	return m.Storage.FindEntries(query)
 }
 
 // Get STH with the given STHCTObject identifer stored within the monitor
@@ -128,6 +550,9 @@ func (m *Monitor) GetCorrespondingSTHEntry(ctObject *mtr.CTObject) (*mtr.CTObjec
 	if sth == nil {
 		id.First = mtr.STHPOCTypeID
 		pocSTH := m.GetEntry(id)
+		if pocSTH == nil {
+			return nil, fmt.Errorf("no STH or STH_POC from Logger %s at timestamp %d", id.Second, id.Third)
+		}
 		baseSTH, err := pocSTH.DeconstructSTH()
 		if err != nil {
 			return nil, fmt.Errorf("failed to getSTH from monitor map: %w", err)
@@ -139,62 +564,111 @@ func (m *Monitor) GetCorrespondingSTHEntry(ctObject *mtr.CTObject) (*mtr.CTObjec
 	}
 	return sth, nil
 }
+// Get an STH or STH_POC CTObject stored within the monitor for the given log with the given tree size
+// Returns nil if the monitor hasn't seen an STH of that tree size
+func (m *Monitor) GetSTHEntryByTreeSize(logID string, treeSize uint64) (*mtr.CTObject, error) {
+	for _, typeID := range []string{mtr.STHTypeID, mtr.STHPOCTypeID} {
+		for _, ctObject := range m.Storage.GetEntries(typeID, logID) {
+			sth, err := ctObject.DeconstructSTH()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get STH by tree size from monitor storage: %w", err)
+			}
+			if sth.TreeHeadData.TreeSize == treeSize {
+				return ctObject, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Get every STH or STH_POC CTObject stored within the monitor for the given log keyed by tree size
+func (m *Monitor) getSTHEntriesByTreeSize(logID string) (map[uint64]*mtr.CTObject, error) {
+	sthCTs := make(map[uint64]*mtr.CTObject)
+	for _, typeID := range []string{mtr.STHTypeID, mtr.STHPOCTypeID} {
+		for _, ctObject := range m.Storage.GetEntries(typeID, logID) {
+			sth, err := ctObject.DeconstructSTH()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get STHs by tree size from monitor storage: %w", err)
+			}
+			sthCTs[sth.TreeHeadData.TreeSize] = ctObject
+		}
+	}
+	return sthCTs, nil
+}
+
+// Get the STH or STH_POC CTObject with the latest timestamp stored within the monitor for the given log
+// Returns nil if the monitor hasn't seen an STH from the log
+func (m *Monitor) GetLatestSTHEntry(logID string) *mtr.CTObject {
+	var latest *mtr.CTObject
+	for _, typeID := range []string{mtr.STHTypeID, mtr.STHPOCTypeID} {
+		entries := m.Storage.GetEntries(typeID, logID)
+		if len(entries) == 0 {
+			continue
+		}
+		last := entries[len(entries) - 1]
+		if latest == nil || last.Timestamp > latest.Timestamp {
+			latest = last
+		}
+	}
+	return latest
+}
+
 // Get SRD with the given SRDWithRevDataCTObject identifer stored within the monitor
 func (m *Monitor) GetCorrespondingSRDEntry(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
 	id := ctObject.Identifier()
 	srd := m.GetEntry(id)
+	if srd == nil {
+		return nil, fmt.Errorf("no SRD from %s at timestamp %d", id.Second, id.Third)
+	}
 	return srd, nil
 }
 
-//addEntry adds a new entry to the selected map using the data identifier as keys
+//addEntry adds a new entry to the monitor storage using the data identifier as keys
+// STHs that prove their log misbehaved and SRDs that prove their CA misbehaved aren't stored. See addSTHEntry and addSRDEntry
 // TODO Add error case here and in Identifier within types.go
 func (m *Monitor) AddEntry(ctObject *mtr.CTObject) error {
-	identifier := ctObject.Identifier()
-
-	if _, ok := m.CTObjectMap[identifier.First]; !ok {
-		m.CTObjectMap[identifier.First] = make(map[string]map[uint64]map[string] *mtr.CTObject);
-	}
-	if _, ok := m.CTObjectMap[identifier.First][identifier.Second]; !ok {
-		m.CTObjectMap[identifier.First][identifier.Second] = make(map[uint64]map[string] *mtr.CTObject);
+	if ctObject.TypeID == mtr.STHTypeID || ctObject.TypeID == mtr.STHPOCTypeID {
+		return m.addSTHEntry(ctObject)
 	}
-	if _, ok := m.CTObjectMap[identifier.First][identifier.Second][identifier.Third]; !ok {
-		m.CTObjectMap[identifier.First][identifier.Second][identifier.Third] = make(map[string] *mtr.CTObject);
+	if ctObject.TypeID == mtr.SRDWithRevDataTypeID {
+		return m.addSRDEntry(ctObject)
 	}
-	m.CTObjectMap[identifier.First][identifier.Second][identifier.Third][identifier.Fourth] = ctObject;
-	return nil
+	return m.addEntry(ctObject)
 }
 
-// Send request to a specific Logger to get an SRDWithRevData
-func (m *Monitor) GetSRDWithRevData(logURL string, srdGosReq *mtr.SRDWithRevDataGossipRequest) (*mtr.CTObject, error) {
-	slashSplit := strings.Split(logURL, "/")
-	colonSplit := strings.Split(slashSplit[2], ":")
-	reqURL := slashSplit[0] + "//" + colonSplit[0] + ":6966"
-	reqFullURL := utils.CreateRequestURL(reqURL, "/ct/v1/revoke-and-produce-srd")
-	glog.Infof("\nget srdWithRevData from log at address: %s", reqURL)
-
-	// Create request struct
-	revAndProdSRDReq := ctca.RevokeAndProduceSRDRequest{
-		PercentRevoked: srdGosReq.PercentRevoked, 
-		TotalCerts: srdGosReq.TotalCerts,
+// Add the CTObject to the monitor storage without checking it against stored entries
+// CTObjects that weren't already stored are published to the Feed
+func (m *Monitor) addEntry(ctObject *mtr.CTObject) error {
+	m.entryLock.Lock()
+	defer m.entryLock.Unlock()
+	prev := m.Storage.GetEntry(ctObject.Identifier())
+	if err := m.Storage.AddEntry(ctObject); err != nil {
+		return fmt.Errorf("failed to add %s CTObject to monitor storage: %w", ctObject.TypeID, err)
 	}
+	if m.Feed != nil && (prev == nil || !bytes.Equal(prev.Digest, ctObject.Digest)) {
+		m.Feed.Publish(ctObject)
+	}
+	return nil
+}
 
-	// Create request
-	jsonBytes, err := json.Marshal(revAndProdSRDReq)	// Just use serialize method somewhere else
-	req, err := http.NewRequest("GET", reqFullURL, bytes.NewBuffer(jsonBytes)) 
-	req.Header.Set("Content-Type", "application/json");
-
-	// Send request
-	client := &http.Client{};
-	resp, err := client.Do(req);
+// Ask the CA of the SRDWithRevDataGossipRequest to revoke certificates and produce an SRDWithRevData
+// The CA is found in the CA list by its CAID and must respond before ctx is done
+func (m *Monitor) GetSRDWithRevData(ctx context.Context, srdGosReq *mtr.SRDWithRevDataGossipRequest) (*mtr.CTObject, error) {
+	caClient, err := m.getCAClient(srdGosReq.CAID)
 	if err != nil {
-		panic(err);
+		return nil, fmt.Errorf("failed to get SRDWithRevData: %w", err)
 	}
-	defer resp.Body.Close();
+	return caClient.RevokeAndProduceSRD(ctx, srdGosReq.PercentRevoked, srdGosReq.TotalCerts)
+}
 
-	// Decode the newly received SRDWithRevData
-	var srdCTObj mtr.CTObject
-	if err := json.NewDecoder(resp.Body).Decode(&srdCTObj); err != nil {
-		glog.Errorln("failed to decode srd ct object")
+// Get the CAClient of the CA with the given caID. CAs not monitored by the monitor are looked up in the CA list
+func (m *Monitor) getCAClient(caID string) (*caclient.CAClient, error) {
+	if caClient, ok := m.CAIDMap[caID]; ok {
+		return caClient, nil
+	}
+	caInfo := m.CAList.FindCAByCAID(caID)
+	if caInfo == nil {
+		return nil, fmt.Errorf("CAID (%s) not found in CA list", caID)
 	}
-	return &srdCTObj, nil
+	return caclient.NewCAClient(caInfo)
 }
\ No newline at end of file
@@ -5,19 +5,36 @@ import (
 	"strings"
 	"encoding/json"
 
+	"github.com/golang/glog"
 	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/caclient"
 	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/gossip"
 	"github.com/n-ct/ct-monitor/utils"
 	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/storage"
+	"github.com/n-ct/ct-monitor/watchlist"
 )
 
 // Create Monitor 
-func createMonitor(monitorConfigName string, monitorListName string, logListName string) (*Monitor, error){
+func createMonitor(monitorConfigName string, monitorListName string, logListName string, caListName string) (*Monitor, error){
 	monitorConfig, err := parseMonitorConfig(monitorConfigName)
 	if nil != err {
 		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
 	}
-	logIDMap, err := createLogIDMap(monitorConfig, logListName)
+	logList, err := entitylist.NewLogList(logListName)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	caList, err := entitylist.NewCAList(caListName)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	logIDMap, err := createLogIDMap(monitorConfig, logList)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	caIDMap, err := createCAIDMap(monitorConfig, caList)
 	if nil != err {
 		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
 	}
@@ -29,16 +46,76 @@ func createMonitor(monitorConfigName string, monitorListName string, logListName
 	if nil != err {
 		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
 	}
-	ctObjectMap := make(map[string]map[string]map[uint64]map[string] *mtr.CTObject)
-	monitor := &Monitor{logIDMap, monitorList, *gossiperURL, *monitorURL, ctObjectMap, signer}
+	ctObjectStorage, err := createStorage(monitorConfig)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+
+	// Release the storage file when the rest of the monitor fails to set up
+	defer func() {
+		if err != nil {
+			ctObjectStorage.Close()
+		}
+	}()
+	domainWatchlist, err := watchlist.NewWatchlist(monitorConfig.WatchlistPath)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	checkpoints, err := storage.NewCheckpoints(monitorConfig.CheckpointPath)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	revocationVectors, err := storage.NewRevocationVectors(monitorConfig.CRVPath)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	gossiper, err := createGossiper(monitorConfig, monitorList, *gossiperURL, signer)
+	if nil != err {
+		return nil, fmt.Errorf("failed to setup new monitor: %w", err)
+	}
+	if monitorConfig.AllowUnauthenticatedNewInfo {
+		glog.Warningf("allow_unauthenticated_new_info is set: the new-info endpoint accepts CTObjects from anyone, not only the monitors in the monitor list")
+	}
+	monitor := &Monitor{
+		LogIDMap: logIDMap,
+		LogList: logList,
+		CAList: caList,
+		CAIDMap: caIDMap,
+		MonitorList: monitorList,
+		GossiperURL: *gossiperURL,
+		ListenAddress: *monitorURL,
+		Storage: ctObjectStorage,
+		Signer: signer,
+		Gossiper: gossiper,
+		MonitorID: monitorConfig.MonitorID,
+		AlertThreshold: monitorConfig.AlertThreshold,
+		Watchlist: domainWatchlist,
+		Checkpoints: checkpoints,
+		TailFromLatestSTH: monitorConfig.TailFromLatestSTH,
+		AllowUnauthenticatedNewInfo: monitorConfig.AllowUnauthenticatedNewInfo,
+		RevocationVectors: revocationVectors,
+		Feed: NewFeed(monitorConfig.FeedCapacity),
+	}
 	return monitor, nil
 }
 
 // Stores the contents of monitor_config.json
 type MonitorConfig struct {
 	LogIDs []string `json:"log_ids"`
+	CAIDs []string `json:"ca_ids"`	// CAs whose SRDs the Scheduler fetches every MMD of the CA
 	MonitorID string `json:"monitor_id"`
 	StrPrivKey string `json:"priv_key"`
+	StoragePath string `json:"storage_path"`	// File that stored CTObjects persist to. Empty keeps them only in memory
+	AlertThreshold int `json:"alert_threshold"`	// Alerts needed to create a NonRespondingLogPOM. 0 uses a majority of the monitor list
+	WatchlistPath string `json:"watchlist_path"`	// File that watched domains and their matches persist to. Empty keeps them only in memory
+	CheckpointPath string `json:"checkpoint_path"`	// File that the position of the Tailer in each log persists to. Empty keeps it only in memory
+	TailFromLatestSTH bool `json:"tail_from_latest_sth"`	// Skip the entries logs held before the monitor first tailed them. Otherwise every entry is fetched from index 0
+	CRVPath string `json:"crv_path"`	// File that the CRV reconstructed for each CA persists to. Empty keeps them only in memory
+	GossipQueuePath string `json:"gossip_queue_path"`	// File that CTObjects waiting to be gossiped persist to. Empty keeps them only in memory
+	AllowUnauthenticatedNewInfo bool `json:"allow_unauthenticated_new_info"`	// Accept NewInfo requests without the signature headers of a monitor in the monitor list, letting anyone feed the monitor CTObjects
+	DirectGossip bool `json:"direct_gossip"`	// Gossip directly to the other monitors in the monitor list instead of through the gossiper
+	GossipConcurrency int `json:"gossip_concurrency"`	// Most requests to peer monitors in flight at once with DirectGossip. Every CTObject still goes to every peer. 0 doesn't limit them
+	FeedCapacity int `json:"feed_capacity"`	// Newly stored CTObjects kept for streaming clients to resume from. 0 keeps the default number
 }
 
 // Parse monitorConfig json file 
@@ -56,16 +133,15 @@ func parseMonitorConfig(monitorConfigName string) (*MonitorConfig, error) {
 }
 
 // Create a map of logger LogIDs to their corresponding LogClients
-func createLogIDMap(monitorConfig *MonitorConfig, logListName string) (map[string] *mtr.LogClient, error) {
+func createLogIDMap(monitorConfig *MonitorConfig, logList *entitylist.LogList) (map[string] *mtr.LogClient, error) {
 	logIDMap := make(map[string] *mtr.LogClient)
-	logList, err := entitylist.NewLogList(logListName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create loglist for logIDMap: %w", err)
-	}
 
 	// Iterate through all the LogIDs within monitorConfig and add them to map along with their created logclients
 	for _, logID := range monitorConfig.LogIDs {
 		log := logList.FindLogByLogID(logID)
+		if log == nil {
+			return nil, fmt.Errorf("LogID (%v) not found in log list", logID)
+		}
 		logClient, err := mtr.NewLogClient(log)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create logClient for logIDMap: %w", err)
@@ -75,6 +151,51 @@ func createLogIDMap(monitorConfig *MonitorConfig, logListName string) (map[strin
 	return logIDMap, nil 
 }
 
+// Create a map of CA CAIDs to their corresponding CAClients
+func createCAIDMap(monitorConfig *MonitorConfig, caList *entitylist.CAList) (map[string] *caclient.CAClient, error) {
+	caIDMap := make(map[string] *caclient.CAClient)
+	for _, caID := range monitorConfig.CAIDs {
+		ca := caList.FindCAByCAID(caID)
+		if ca == nil {
+			return nil, fmt.Errorf("CAID (%v) not found in CA list", caID)
+		}
+		caClient, err := caclient.NewCAClient(ca)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create caClient for caIDMap: %w", err)
+		}
+		caIDMap[caID] = caClient
+	}
+	return caIDMap, nil
+}
+
+// Create the storage for the Monitor's CTObjects
+func createStorage(monitorConfig *MonitorConfig) (storage.Storage, error) {
+	if monitorConfig.StoragePath == "" {
+		return storage.NewMemoryStorage(), nil
+	}
+	fileStorage, err := storage.NewFileStorage(monitorConfig.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage in monitor: %w", err)
+	}
+	return fileStorage, nil
+}
+
+// Create the Gossiper for the Monitor, which either goes through the monitor's gossiper or directly to the other monitors
+func createGossiper(monitorConfig *MonitorConfig, monitorList *entitylist.MonitorList, gossiperURL string, signer *signature.Signer) (gossip.Gossiper, error) {
+	if monitorConfig.DirectGossip {
+		peerGossiper, err := gossip.NewPeerGossiper(monitorList, monitorConfig.MonitorID, signer, monitorConfig.GossipQueuePath, monitorConfig.GossipConcurrency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create peer gossiper in monitor: %w", err)
+		}
+		return peerGossiper, nil
+	}
+	queue, err := gossip.NewQueue(monitorConfig.GossipQueuePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gossip queue in monitor: %w", err)
+	}
+	return gossip.NewClient(gossiperURL, monitorConfig.MonitorID, signer, queue), nil
+}
+
 // Create signer for the Monitor
 func createSigner(monitorConfig *MonitorConfig) (*signature.Signer, error) {
 	strPrivKey := monitorConfig.StrPrivKey
@@ -2,17 +2,32 @@ package monitor
 
 import (
 	"testing"
+	"context"
+	"encoding/json"
+	"errors"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/testutil/fakelog"
+	"github.com/n-ct/ct-monitor/signature"
 )
 
 var (
 	monitorConfigName = "monitor_config.json"
 	monitorListName = "../entitylist/monitor_list.json"
 	logListName = "../entitylist/log_list.json"
+	caListName = "../entitylist/ca_list.json"
+
+	testLogID = "9lyUL9F3MCIUVBgIMJRWjuNNExkzv98MLyALzE7xZOM="
+	testCAID = "LeYXK29QzQV9RxvgMw+hnOeyZV85A6a5quOLltev9H0="
+	testMonitorKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEmFk6QT48Ts4oxSkBPM4mQ/mnWICKVmZUP6urQVBH0vhDzJVYHc2ShvF2KjWzorVu2C+tY6lIU+61iiPLsGvZXw=="
 )
 
 func mustGetMonitor(t *testing.T) (*Monitor, error) {
 	t.Helper()
-	return NewMonitor(monitorConfigName, monitorListName, logListName)
+	return NewMonitor(monitorConfigName, monitorListName, logListName, caListName)
 }
 
 func TestNewMonitor(t *testing.T) {
@@ -22,6 +37,249 @@ func TestNewMonitor(t *testing.T) {
 	}
 }
 
+func TestVerifyCTObjectRejectsForgedSTH(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// STHs created by the test are signed by the monitor key instead of the log key
+	forgedSTH := mustCreateSTH(t, monitor, 10, 1000, nil)
+	if err := monitor.VerifyCTObject(forgedSTH); err == nil {
+		t.Fatalf("forged STH passed verification")
+	}
+
+	forgedSTH.Signer = "unknownlog"
+	if err := monitor.VerifyCTObject(forgedSTH); err == nil {
+		t.Fatalf("STH with mismatched signer passed verification")
+	}
+}
+
+// Create an STH CTObject for testLogID with the given tree size and root hash
+func mustCreateSTH(t *testing.T, m *Monitor, treeSize uint64, timestamp uint64, rootHash []byte) *mtr.CTObject {
+	t.Helper()
+	treeHead := ct.TreeHeadSignature{
+		Version:       ct.V1,
+		SignatureType: ct.TreeHashSignatureType,
+		Timestamp:     timestamp,
+		TreeSize:      treeSize,
+	}
+	copy(treeHead.SHA256RootHash[:], rootHash)
+	sig, err := m.Signer.CreateSignature(tls.SHA256, treeHead)
+	if err != nil {
+		t.Fatalf("failed to sign STH: %v", err)
+	}
+	sthCT, err := mtr.ConstructCTObject(&mtr.SignedTreeHeadData{LogID: testLogID, TreeHeadData: treeHead, Signature: *sig})
+	if err != nil {
+		t.Fatalf("failed to construct STH CTObject: %v", err)
+	}
+	return sthCT
+}
+
+// Create an SRDWithRevData CTObject for testCAID signed by the monitor, whose key is the CA key in the CA list
+func mustCreateSRDWithRevData(t *testing.T, m *Monitor, timestamp uint64, crvHash []byte) *mtr.CTObject {
+	t.Helper()
+	revDigest := mtr.RevocationDigest{Timestamp: timestamp, CRVHash: crvHash, CRVDeltaHash: []byte("delta")}
+	sig, err := m.Signer.CreateSignature(tls.SHA256, revDigest)
+	if err != nil {
+		t.Fatalf("failed to sign RevocationDigest: %v", err)
+	}
+	srdWithRevData := &mtr.SRDWithRevData{
+		RevData: mtr.RevocationData{EntityID: testCAID, RevocationType: "Let's-Revoke", Timestamp: timestamp},
+		SRD: mtr.SignedRevocationDigest{EntityID: testCAID, RevDigest: revDigest, Signature: *sig},
+	}
+	srdCT, err := mtr.ConstructCTObject(srdWithRevData)
+	if err != nil {
+		t.Fatalf("failed to construct SRDWithRevData CTObject: %v", err)
+	}
+	return srdCT
+}
+
+func TestVerifyCTObjectSRD(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	srdCT := mustCreateSRDWithRevData(t, monitor, 1000, []byte("crv"))
+	if err := monitor.VerifyCTObject(srdCT); err != nil {
+		t.Fatalf("failed to verify SRDWithRevData: %v", err)
+	}
+
+	// A signed SRD labelled with another timestamp or digest mustn't pass for the SRD of that timestamp
+	relabelledCT := *srdCT
+	relabelledCT.Timestamp = 2000
+	if err := monitor.VerifyCTObject(&relabelledCT); err == nil {
+		t.Fatalf("SRDWithRevData with relabelled timestamp passed verification")
+	}
+	relabelledCT = *srdCT
+	relabelledCT.Digest = []byte("forged")
+	if err := monitor.VerifyCTObject(&relabelledCT); err == nil {
+		t.Fatalf("SRDWithRevData with relabelled digest passed verification")
+	}
+
+	// An SRD whose digest was changed after signing is a forgery
+	srdWithRevData := &mtr.SRDWithRevData{}
+	json.Unmarshal(srdCT.Blob, srdWithRevData)
+	srdWithRevData.SRD.RevDigest.CRVHash = []byte("forged")
+	forgedCT, err := mtr.ConstructCTObject(srdWithRevData)
+	if err != nil {
+		t.Fatalf("failed to construct SRDWithRevData CTObject: %v", err)
+	}
+	if err := monitor.VerifyCTObject(forgedCT); err == nil {
+		t.Fatalf("forged SRDWithRevData passed verification")
+	}
+	if _, err := monitor.Audit(forgedCT); err == nil {
+		t.Fatalf("forged SRDWithRevData was audited")
+	}
+
+	// SRDs from CAs missing from the CA list can't be verified
+	srdWithRevData.SRD.EntityID = "unknownca"
+	srdWithRevData.RevData.EntityID = "unknownca"
+	unknownCT, err := mtr.ConstructCTObject(srdWithRevData)
+	if err != nil {
+		t.Fatalf("failed to construct SRDWithRevData CTObject: %v", err)
+	}
+	if err := monitor.VerifyCTObject(unknownCT); err == nil {
+		t.Fatalf("SRDWithRevData from unknown CA passed verification")
+	}
+}
+
+// Create an STH_POC CTObject linking tree size 2 to tree size 4 of the leaves a, b, c, d
+func mustCreateSTHWithPOC(t *testing.T, m *Monitor, consistencyPath [][]byte) (*mtr.CTObject, *mtr.CTObject) {
+	t.Helper()
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	left := mtr.HashChildren(mtr.HashLeaf(leaves[0]), mtr.HashLeaf(leaves[1]))
+	right := mtr.HashChildren(mtr.HashLeaf(leaves[2]), mtr.HashLeaf(leaves[3]))
+	sth1 := mustCreateSTH(t, m, 2, 1000, left)
+	sth2 := mustCreateSTH(t, m, 4, 2000, mtr.HashChildren(left, right))
+	if consistencyPath == nil {
+		consistencyPath = [][]byte{right}
+	}
+	baseSTH2, _ := sth2.DeconstructSTH()
+	poc := mtr.ConsistencyProofData{LogID: testLogID, TreeSize1: 2, TreeSize2: 4, ConsistencyPath: consistencyPath}
+	sthPOC, err := mtr.ConstructCTObject(&mtr.SignedTreeHeadWithConsistencyProof{SignedTreeHead: *baseSTH2, ConsistencyProof: poc})
+	if err != nil {
+		t.Fatalf("failed to construct STHWithPOC CTObject: %v", err)
+	}
+	return sth1, sthPOC
+}
+
+func TestVerifySTHWithPOC(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	sth1, sthPOC := mustCreateSTHWithPOC(t, monitor, nil)
+	if _, err := monitor.VerifySTHWithPOC(context.Background(), sthPOC); err == nil {
+		t.Fatalf("verified STHWithPOC without having seen STH of first tree size")
+	}
+
+	monitor.AddEntry(sth1)
+	pom, err := monitor.VerifySTHWithPOC(context.Background(), sthPOC)
+	if err != nil {
+		t.Fatalf("failed to verify STHWithPOC: %v", err)
+	}
+	if pom != nil {
+		t.Fatalf("valid ConsistencyProof produced PoM: %v", pom)
+	}
+}
+
+func TestVerifySTHWithPOCRejectsCorruptedProof(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 2)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+	log.AddEntries(2)
+	sthPOC, err := monitor.LogIDMap[log.LogID()].GetSTHWithConsistencyProofFrom(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("failed to get STHWithPOC: %v", err)
+	}
+
+	// A ConsistencyProof corrupted after the log served it isn't a PoM against the log, since the log serves a valid proof
+	sthWithPOC := &mtr.SignedTreeHeadWithConsistencyProof{}
+	json.Unmarshal(sthPOC.Blob, sthWithPOC)
+	sthWithPOC.ConsistencyProof.ConsistencyPath = [][]byte{mtr.HashLeaf([]byte("forged"))}
+	corruptedCT, err := mtr.ConstructCTObject(sthWithPOC)
+	if err != nil {
+		t.Fatalf("failed to construct STHWithPOC CTObject: %v", err)
+	}
+	pom, err := monitor.VerifySTHWithPOC(context.Background(), corruptedCT)
+	if !errors.Is(err, ErrInvalidConsistencyProof) || pom != nil {
+		t.Fatalf("corrupted ConsistencyProof returned PoM %v and error %v, expected ErrInvalidConsistencyProof", pom, err)
+	}
+
+	// Neither can another monitor claim the log is inconsistent with the corrupted proof
+	sth1CT := monitor.GetLatestSTHEntry(log.LogID())
+	forgedPOM, err := mtr.CreateInconsistentSTHPOM(sth1CT, corruptedCT, &sthWithPOC.ConsistencyProof)
+	if err != nil {
+		t.Fatalf("failed to create InconsistentSTHPOM: %v", err)
+	}
+	if err := monitor.VerifyCTObject(forgedPOM); err == nil {
+		t.Fatalf("InconsistentSTHPOM with a corrupted ConsistencyProof passed verification")
+	}
+}
+
+// Start a fake log holding numEntries entries and add it to the monitor's LogIDMap under its own LogID and key
+func mustUseFakeLog(t *testing.T, m *Monitor, numEntries int) *fakelog.Log {
+	t.Helper()
+	log := fakelog.New(t)
+	log.AddEntries(numEntries)
+	logClient, err := mtr.NewLogClient(log.LogInfo())
+	if err != nil {
+		t.Fatalf("failed to create LogClient: %v", err)
+	}
+	m.LogIDMap[log.LogID()] = logClient
+	return log
+}
+
+func TestAuditInclusion(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 4)
+	ctx := context.Background()
+	sth, err := monitor.LogIDMap[log.LogID()].GetSTH(ctx)
+	if err != nil {
+		t.Fatalf("failed to get STH: %v", err)
+	}
+	auditReq := &mtr.InclusionAuditRequest{STH: *sth, LeafHash: log.LeafHash(2)}
+
+	auditOK, err := monitor.AuditInclusion(ctx, auditReq)
+	if err != nil {
+		t.Fatalf("failed to audit inclusion: %v", err)
+	}
+	if auditOK.TypeID != mtr.InclusionAuditOKTypeID {
+		t.Fatalf("inclusion audit returned %s CTObject", auditOK.TypeID)
+	}
+	deconAuditOK, err := auditOK.DeconstructInclusionAuditOK()
+	if err != nil {
+		t.Fatalf("failed to deconstruct InclusionAuditOK: %v", err)
+	}
+	if err := signature.VerifySignature(testMonitorKey, deconAuditOK.TBS, deconAuditOK.Signature); err != nil {
+		t.Fatalf("InclusionAuditOK signature doesn't verify: %v", err)
+	}
+
+	// Once the tree is forked the audit path of the leaf no longer leads to the root of the STH
+	log.Fork(3)
+	if _, err := monitor.AuditInclusion(ctx, auditReq); !errors.Is(err, ErrNotIncluded) {
+		t.Fatalf("auditing inclusion with invalid inclusion proof returned %v, expected ErrNotIncluded", err)
+	}
+
+	// Leaves the log doesn't hold aren't included
+	auditReq.LeafHash = mtr.HashLeaf([]byte("unlogged"))
+	if _, err := monitor.AuditInclusion(ctx, auditReq); !errors.Is(err, ErrNotIncluded) {
+		t.Fatalf("auditing inclusion of unlogged leaf returned %v, expected ErrNotIncluded", err)
+	}
+
+	// Requests without a leaf can't be audited
+	auditReq.LeafHash = nil
+	if _, err := monitor.AuditInclusion(ctx, auditReq); !errors.Is(err, ErrInvalidInclusionAuditRequest) {
+		t.Fatalf("auditing inclusion without a leaf returned %v, expected ErrInvalidInclusionAuditRequest", err)
+	}
+}
+
 func TestAuditSTH(t *testing.T) {
 	//monitor, _ := mustGetMonitor(t)
 
@@ -0,0 +1,414 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/storage"
+
+	ctca "github.com/n-ct/ct-certificate-authority"
+)
+
+// Error returned by ProcessSRD when the CRVDelta of an SRDWithRevData doesn't hash to the CRVDeltaHash the CA signed
+// The RevData isn't covered by the CA's signature, so the mismatch comes from whoever served or relayed it rather than the CA
+var ErrInvalidRevData = errors.New("CRVDelta doesn't match SRD")
+
+// Error returned by ProcessSRD when the first SRD the monitor sees of a CA doesn't build on the empty CRV
+// CAs don't serve their full CRV, so a CRV is only reconstructed by monitors that see or fetch the CA's genesis SRD.
+// The SRDs of other CAs can still be audited for split views, but not used to answer revocation status requests
+var ErrNoGenesisSRD = errors.New("CRV can only be reconstructed from the CA's genesis SRD")
+
+// Error returned by ProcessSRD when the SRD doesn't directly follow the last SRD the CRV of the CA was reconstructed up to
+var ErrMissingSRD = errors.New("missing SRD to reconstruct CRV")
+
+// Error returned by AddEntry when an SRD conflicts with the stored SRD of the same CA and timestamp
+// The SRD isn't stored. The ConflictingSRDPOM is stored and gossiped instead
+type MisbehavingCAError struct {
+	CAID 	string
+	Reason 	string
+	POM 	*mtr.CTObject
+}
+
+func (e *MisbehavingCAError) Error() string {
+	return fmt.Sprintf("CA %s misbehaved: %s", e.CAID, e.Reason)
+}
+
+// Apply the CRVDelta of the SRDWithRevData CTObject to the CRV the monitor has reconstructed for the CA and check the signed digest
+// Returns an InconsistentCRVPOM CTObject if the CRVHash doesn't match and nil if it matches
+// An error wrapping ErrInvalidRevData is returned when the CRVDelta doesn't match its CRVDeltaHash
+// An error wrapping ErrMissingSRD is returned when the digest can't be checked because the monitor is missing the previous SRD of the CA,
+// or ErrNoGenesisSRD when the monitor has no CRV of the CA and the SRD isn't the genesis SRD of the CA.
+// An SRD conflicting with the stored SRD of the CA and timestamp isn't applied. AddEntry replaces it with a ConflictingSRDPOM
+func (m *Monitor) ProcessSRD(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
+	if ctObject.TypeID != mtr.SRDWithRevDataTypeID {
+		return nil, fmt.Errorf("can't reconstruct CRV from %s CTObject", ctObject.TypeID)
+	}
+	if storedCT := m.GetEntry(ctObject.Identifier()); storedCT != nil {
+		sameRevDigest, err := equalRevocationDigests(storedCT, ctObject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process SRD: %w", err)
+		}
+		if !sameRevDigest {
+			glog.Warningf("not reconstructing CRV of CA %s from SRD conflicting with stored SRD at timestamp %d", ctObject.Signer, ctObject.Timestamp)
+			return nil, nil
+		}
+	}
+	srd, err := ctObject.DeconstructSRD()
+	if err != nil {
+		return nil, fmt.Errorf("failed to process SRD: %w", err)
+	}
+	revData, err := ctObject.DeconstructRevData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to process SRD: %w", err)
+	}
+
+	// Anyone can swap the unsigned CRVDelta, so a mismatch says nothing about the CA
+	hashAlgo := srd.Signature.Algorithm.Hash
+	if err := checkHash(hashAlgo, revData.CRVDelta, srd.RevDigest.CRVDeltaHash); err != nil {
+		return nil, fmt.Errorf("%w of CA %s at timestamp %d: %v", ErrInvalidRevData, srd.EntityID, srd.RevDigest.Timestamp, err)
+	}
+
+	// Deltas of a CA are applied one MMD at a time
+	m.revocationLock.Lock()
+	defer m.revocationLock.Unlock()
+	prev := m.RevocationVectors.Get(srd.EntityID, revData.RevocationType)
+	var prevCRV []byte
+	if prev != nil {
+		prevTimestamp := prev.SRD.RevDigest.Timestamp
+		if srd.RevDigest.Timestamp <= prevTimestamp {
+			glog.V(1).Infof("CRV of CA %s already reconstructed past timestamp %d", srd.EntityID, srd.RevDigest.Timestamp)
+			return nil, nil
+		}
+		if mmd := m.caMMD(srd.EntityID); mmd > 0 && srd.RevDigest.Timestamp != prevTimestamp + mmd {
+			return nil, fmt.Errorf("%w of CA %s between timestamps %d and %d", ErrMissingSRD, srd.EntityID, prevTimestamp, srd.RevDigest.Timestamp)
+		}
+		prevCRV = prev.CRV
+	}
+	crv, crvHash, err := applyCRVDelta(hashAlgo, prevCRV, revData.CRVDelta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process SRD: %w", err)
+	}
+	if !bytes.Equal(crvHash, srd.RevDigest.CRVHash) {
+		if prev == nil {
+			return nil, fmt.Errorf("%w: CRV of CA %s at timestamp %d doesn't build on the empty CRV", ErrNoGenesisSRD, srd.EntityID, srd.RevDigest.Timestamp)
+		}
+		glog.Warningf("CRV of CA %s at timestamp %d doesn't match its SRD", srd.EntityID, srd.RevDigest.Timestamp)
+		return createInconsistentCRVPOM(prev, ctObject)
+	}
+	if err := m.RevocationVectors.Set(srd.EntityID, revData.RevocationType, &storage.RevocationVector{SRD: *srd, CRV: crv}); err != nil {
+		return nil, fmt.Errorf("failed to process SRD: %w", err)
+	}
+	return nil, nil
+}
+
+// Fetch the latest SRD from the CA along with the SRDs of the MMDs since the last SRD of the CA the monitor processed
+// The SRDs are processed in order, stored, and gossiped. If a CRV doesn't match its SRD the PoM is stored and gossiped instead.
+// SRDs of a CA the monitor didn't see from its genesis SRD, or of a CA that doesn't serve the missed SRDs, are stored and gossiped
+// without reconstructing the CRV. An SRD conflicting with the stored SRD of the same timestamp isn't gossiped.
+// The MisbehavingCAError of the stored and gossiped PoM is returned instead
+// Returns the latest SRD, or the PoM
+func (m *Monitor) FetchSRD(ctx context.Context, caID string) (*mtr.CTObject, error) {
+	caClient, ok := m.CAIDMap[caID]
+	if !ok {
+		return nil, fmt.Errorf("CAID (%s) not found in Monitor's CAIDMap", caID)
+	}
+	latestCT, err := caClient.GetLatestSRD(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch SRD: %w", err)
+	}
+	revData, err := latestCT.DeconstructRevData()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch SRD: %w", err)
+	}
+
+	// Without a reconstructed CRV the deltas are needed from the first SRD of the CA on
+	var start uint64
+	prev := m.RevocationVectors.Get(caID, revData.RevocationType)
+	if prev != nil {
+		prevTimestamp := prev.SRD.RevDigest.Timestamp
+		if latestCT.Timestamp <= prevTimestamp {
+			glog.V(1).Infof("CA %s has no SRD after timestamp %d", caID, prevTimestamp)
+			return latestCT, nil
+		}
+		start = prevTimestamp + 1
+	}
+	srdCTs := []*mtr.CTObject{latestCT}
+	if latestCT.Timestamp > start && (prev == nil || latestCT.Timestamp != prev.SRD.RevDigest.Timestamp + m.caMMD(caID)) {
+		// The latest SRD is still stored for audits when the CA doesn't serve the missed SRDs
+		missedCTs, err := caClient.GetRevData(ctx, start, latestCT.Timestamp - 1)
+		if err != nil {
+			glog.Warningf("failed to fetch SRDs of CA %s missed since timestamp %d: %v", caID, start, err)
+		} else {
+			srdCTs = append(missedCTs, latestCT)
+		}
+	}
+	for _, srdCT := range srdCTs {
+		pom, err := m.ProcessSRD(srdCT)
+		if errors.Is(err, ErrNoGenesisSRD) || errors.Is(err, ErrMissingSRD) {
+			glog.Warningf("not reconstructing CRV of CA %s: %v", caID, err)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch SRD: %w", err)
+		}
+		if pom != nil {
+			if err := m.AddEntry(pom); err != nil {
+				return nil, fmt.Errorf("failed to fetch SRD: %w", err)
+			}
+			m.Gossip(pom)
+			return pom, nil
+		}
+		if err := m.AddEntry(srdCT); err != nil {
+			return nil, fmt.Errorf("failed to fetch SRD: %w", err)
+		}
+		m.Gossip(srdCT)
+	}
+	return latestCT, nil
+}
+
+// Store the SRDWithRevData CTObject unless it conflicts with the stored SRD of the same CA and timestamp
+// A conflicting SRD produces a ConflictingSRDPOM which is stored and gossiped, and a MisbehavingCAError is returned
+func (m *Monitor) addSRDEntry(ctObject *mtr.CTObject) error {
+	m.srdLock.Lock()
+	defer m.srdLock.Unlock()
+	misbehaviour, err := m.checkConflictingSRD(ctObject)
+	if err != nil {
+		return fmt.Errorf("failed to check %s CTObject for conflicting SRD: %w", ctObject.TypeID, err)
+	}
+	if misbehaviour == nil {
+		return m.addEntry(ctObject)
+	}
+	if err := m.addEntry(misbehaviour.POM); err != nil {
+		return err
+	}
+	m.Gossip(misbehaviour.POM)
+	return misbehaviour
+}
+
+// Get the misbehaviour with a ConflictingSRDPOM if the SRD within the SRDWithRevData CTObject has a different RevocationDigest
+// than the SRD the monitor stored for the same CA and timestamp. Returns nil if they match or none is stored
+func (m *Monitor) checkConflictingSRD(ctObject *mtr.CTObject) (*MisbehavingCAError, error) {
+	storedCT := m.GetEntry(ctObject.Identifier())
+	if storedCT == nil {
+		return nil, nil
+	}
+	// CAs may sign the same RevocationDigest more than once and the RevData isn't signed, so only a different RevocationDigest conflicts
+	sameRevDigest, err := equalRevocationDigests(storedCT, ctObject)
+	if err != nil {
+		return nil, err
+	}
+	if sameRevDigest {
+		return nil, nil
+	}
+	pom, err := mtr.CreateConflictingSRDPOM(storedCT, ctObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PoM for conflicting SRD: %w", err)
+	}
+	return &MisbehavingCAError{CAID: pom.Subject, Reason: fmt.Sprintf("conflicting SRDs at timestamp %d", pom.Timestamp), POM: pom}, nil
+}
+
+// Answer whether the certificate with the RevocationNumber was revoked by the CA as of the request Timestamp
+// The answer comes from a reconstructed CRV and is returned with the SRD CTObject whose CRVHash matches that CRV
+func (m *Monitor) GetRevocationStatus(statusReq *mtr.RevocationStatusRequest) (*mtr.RevocationStatusResponse, error) {
+	revType := statusReq.RevocationType
+	if revType == "" {
+		revType = mtr.LetsRevokeRevocationType
+	}
+	vector, err := m.getRevocationVector(statusReq.CAID, revType, statusReq.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revocation status: %w", err)
+	}
+	crv, err := ctca.DecompressCRV(vector.CRV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revocation status: %w", err)
+	}
+	revoked := false
+	if statusReq.RevocationNumber < (*crv).Capacity() {
+		revoked, err = (*crv).GetBit(statusReq.RevocationNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get revocation status: %w", err)
+		}
+	}
+	srdCT, err := mtr.ConstructCTObject(&vector.SRD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revocation status: %w", err)
+	}
+	statusResp := &mtr.RevocationStatusResponse{
+		CAID: statusReq.CAID,
+		RevocationNumber: statusReq.RevocationNumber,
+		Revoked: revoked,
+		SRD: *srdCT,
+	}
+	return statusResp, nil
+}
+
+// Get the RevocationVector of the latest SRD of the CA at or before timestamp, where a timestamp of 0 gets the latest one
+// Vectors older than the latest are rebuilt from the SRDWithRevData CTObjects stored in the monitor
+func (m *Monitor) getRevocationVector(caID string, revType string, timestamp uint64) (*storage.RevocationVector, error) {
+	latest := m.RevocationVectors.Get(caID, revType)
+	if latest == nil {
+		return nil, fmt.Errorf("no CRV of CA %s reconstructed for revocation type %s. The monitor must have seen the genesis SRD of the CA", caID, revType)
+	}
+	if timestamp == 0 || timestamp >= latest.SRD.RevDigest.Timestamp {
+		return latest, nil
+	}
+
+	// Replay the stored deltas of the CA from the empty CRV, checking every CRVHash along the way
+	var vector *storage.RevocationVector
+	for _, srdCT := range m.Storage.GetEntries(mtr.SRDWithRevDataTypeID, caID) {
+		if srdCT.Timestamp > timestamp {
+			break
+		}
+		srd, err := srdCT.DeconstructSRD()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild CRV of CA %s: %w", caID, err)
+		}
+		revData, err := srdCT.DeconstructRevData()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild CRV of CA %s: %w", caID, err)
+		}
+		if revData.RevocationType != revType {
+			continue
+		}
+		var prevCRV []byte
+		if vector != nil {
+			prevCRV = vector.CRV
+		}
+		crv, crvHash, err := applyCRVDelta(srd.Signature.Algorithm.Hash, prevCRV, revData.CRVDelta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild CRV of CA %s: %w", caID, err)
+		}
+		if !bytes.Equal(crvHash, srd.RevDigest.CRVHash) {
+			return nil, fmt.Errorf("stored SRDs of CA %s don't rebuild its CRV at timestamp %d", caID, srd.RevDigest.Timestamp)
+		}
+		vector = &storage.RevocationVector{SRD: *srd, CRV: crv}
+	}
+	if vector == nil {
+		return nil, fmt.Errorf("no SRD of CA %s at or before timestamp %d", caID, timestamp)
+	}
+	return vector, nil
+}
+
+// Create an InconsistentCRVPOM CTObject from the previous RevocationVector of the CA
+func createInconsistentCRVPOM(prev *storage.RevocationVector, srdCT *mtr.CTObject) (*mtr.CTObject, error) {
+	pom, err := mtr.CreateInconsistentCRVPOM(&prev.SRD, prev.CRV, srdCT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PoM for inconsistent CRV: %w", err)
+	}
+	return pom, nil
+}
+
+// Verify the SRDs within the InconsistentCRVPOM and that the CRVs within it don't match the digest the CA signed
+// A CRVDelta that doesn't match its CRVDeltaHash is rejected since the RevData isn't signed by the CA
+func (m *Monitor) verifyInconsistentCRVPOM(ctObject *mtr.CTObject) error {
+	pom, err := ctObject.DeconstructInconsistentCRVPOM()
+	if err != nil {
+		return fmt.Errorf("failed to verify InconsistentCRVPOM: %w", err)
+	}
+	srd := &pom.SRDWithRevData.SRD
+	revData := &pom.SRDWithRevData.RevData
+	if srd.EntityID != ctObject.Subject || revData.EntityID != srd.EntityID {
+		return fmt.Errorf("InconsistentCRVPOM SRD of CA %s doesn't match CTObject subject (%s)", srd.EntityID, ctObject.Subject)
+	}
+	caKey, err := m.getCAKey(srd.EntityID)
+	if err != nil {
+		return fmt.Errorf("failed to verify InconsistentCRVPOM: %w", err)
+	}
+	if err := mtr.VerifySRDSignature(srd, caKey); err != nil {
+		return fmt.Errorf("failed to verify InconsistentCRVPOM: %w", err)
+	}
+	hashAlgo := srd.Signature.Algorithm.Hash
+	if err := checkHash(hashAlgo, revData.CRVDelta, srd.RevDigest.CRVDeltaHash); err != nil {
+		return fmt.Errorf("InconsistentCRVPOM CRVDelta isn't the one the CA signed: %v", err)
+	}
+
+	// The signed CRVDelta applied to the CRV of the previous SRD must not give the CRVHash
+	prevSRD := &pom.PrevSRD
+	if prevSRD.EntityID != srd.EntityID {
+		return fmt.Errorf("InconsistentCRVPOM previous SRD is from CA %s instead of CA %s", prevSRD.EntityID, srd.EntityID)
+	}
+	if err := mtr.VerifySRDSignature(prevSRD, caKey); err != nil {
+		return fmt.Errorf("failed to verify InconsistentCRVPOM: %w", err)
+	}
+	prevTimestamp := prevSRD.RevDigest.Timestamp
+	if mmd := m.caMMD(srd.EntityID); srd.RevDigest.Timestamp <= prevTimestamp || (mmd > 0 && srd.RevDigest.Timestamp != prevTimestamp + mmd) {
+		return fmt.Errorf("InconsistentCRVPOM previous SRD at timestamp %d doesn't directly precede SRD at timestamp %d", prevTimestamp, srd.RevDigest.Timestamp)
+	}
+	if err := checkHash(prevSRD.Signature.Algorithm.Hash, pom.PrevCRV, prevSRD.RevDigest.CRVHash); err != nil {
+		return fmt.Errorf("InconsistentCRVPOM previous CRV doesn't match previous SRD: %w", err)
+	}
+	_, crvHash, err := applyCRVDelta(hashAlgo, pom.PrevCRV, revData.CRVDelta)
+	if err != nil {
+		return fmt.Errorf("failed to verify InconsistentCRVPOM: %w", err)
+	}
+	if bytes.Equal(crvHash, srd.RevDigest.CRVHash) {
+		return fmt.Errorf("InconsistentCRVPOM CRVs match the SRD")
+	}
+	return nil
+}
+
+// Get the MMD of the CA with the given caID. Returns 0 if the CA isn't in the CA list
+func (m *Monitor) caMMD(caID string) uint64 {
+	caInfo := m.CAList.FindCAByCAID(caID)
+	if caInfo == nil {
+		return 0
+	}
+	return caInfo.MMD
+}
+
+// Apply the compressed CRVDelta to the compressed CRV, where a nil CRV is the empty CRV a CA starts from
+// Returns the compressed resulting CRV and its hash
+func applyCRVDelta(hashAlgo tls.HashAlgorithm, compCRV []byte, compDelta []byte) ([]byte, []byte, error) {
+	crv := ctca.CreateCRV([]uint64{}, 0)
+	var err error
+	if compCRV != nil {
+		crv, err = ctca.DecompressCRV(compCRV)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress CRV: %w", err)
+		}
+	}
+	delta, err := ctca.DecompressCRV(compDelta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress CRVDelta: %w", err)
+	}
+	newCRV, err := ctca.CompressCRV(ctca.ApplyCRVDeltaToCRV(crv, delta))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compress CRV: %w", err)
+	}
+	crvHash, _, err := signature.GenerateHash(hashAlgo, newCRV)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash CRV: %w", err)
+	}
+	return newCRV, crvHash, nil
+}
+
+// Check that data hashes to expectedHash
+func checkHash(hashAlgo tls.HashAlgorithm, data []byte, expectedHash []byte) error {
+	dataHash, _, err := signature.GenerateHash(hashAlgo, data)
+	if err != nil {
+		return fmt.Errorf("failed to generate hash: %w", err)
+	}
+	if !bytes.Equal(dataHash, expectedHash) {
+		return fmt.Errorf("hash %x doesn't match expected hash %x", dataHash, expectedHash)
+	}
+	return nil
+}
+
+// Check whether the SRDs within two SRD CTObjects have the same RevocationDigest, regardless of their signatures and RevData
+func equalRevocationDigests(srdCT1 *mtr.CTObject, srdCT2 *mtr.CTObject) (bool, error) {
+	srd1, err := srdCT1.DeconstructSRD()
+	if err != nil {
+		return false, err
+	}
+	srd2, err := srdCT2.DeconstructSRD()
+	if err != nil {
+		return false, err
+	}
+	return srd1.EntityID == srd2.EntityID && srd1.RevDigest.Timestamp == srd2.RevDigest.Timestamp &&
+		bytes.Equal(srd1.RevDigest.CRVHash, srd2.RevDigest.CRVHash) && bytes.Equal(srd1.RevDigest.CRVDeltaHash, srd2.RevDigest.CRVDeltaHash), nil
+}
@@ -0,0 +1,500 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/Workiva/go-datastructures/bitarray"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/caclient"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/storage"
+	"github.com/n-ct/ct-monitor/testutil/fakeca"
+
+	ctca "github.com/n-ct/ct-certificate-authority"
+	"github.com/n-ct/ct-certificate-authority/ca"
+)
+
+// Create an SRDWithRevData CTObject for testCAID signed by the monitor with the given CRV and compressed CRVDelta
+func mustCreateCASRD(t *testing.T, m *Monitor, crv, delta *bitarray.BitArray, timestamp uint64) *mtr.CTObject {
+	t.Helper()
+	srdWithRevData, err := ca.CreateSRDWithRevData(crv, delta, timestamp, testCAID, tls.SHA256, m.Signer)
+	if err != nil {
+		t.Fatalf("failed to create SRDWithRevData: %v", err)
+	}
+	srdCT, err := mtr.ConstructCTObject(srdWithRevData)
+	if err != nil {
+		t.Fatalf("failed to construct SRDWithRevData CTObject: %v", err)
+	}
+	return srdCT
+}
+
+// Process and store the first two SRDs of testCAID, revoking 1 and 5 and then 3, and return the CRV after both
+func mustProcessFirstSRDs(t *testing.T, m *Monitor) *bitarray.BitArray {
+	t.Helper()
+	crv := ctca.CreateCRV([]uint64{}, 0)
+	for i, revoked := range [][]uint64{{1, 5}, {3}} {
+		delta := ctca.GetCRVDelta(revoked)
+		crv = ctca.ApplyCRVDeltaToCRV(crv, delta)
+		srdCT := mustCreateCASRD(t, m, crv, delta, 1000 + uint64(i) * 2)
+		pom, err := m.ProcessSRD(srdCT)
+		if err != nil {
+			t.Fatalf("failed to process SRD %d: %v", i, err)
+		}
+		if pom != nil {
+			t.Fatalf("consistent SRD %d produced PoM: %v", i, pom)
+		}
+		m.AddEntry(srdCT)
+	}
+	return crv
+}
+
+func TestProcessSRDReconstructsCRV(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	crvPath := filepath.Join(t.TempDir(), "crvs")
+	monitor.RevocationVectors, err = storage.NewRevocationVectors(crvPath)
+	if err != nil {
+		t.Fatalf("failed to create revocation vectors: %v", err)
+	}
+	expectedCRV := mustProcessFirstSRDs(t, monitor)
+
+	// The reconstructed CRV is kept across restarts
+	monitor.RevocationVectors, err = storage.NewRevocationVectors(crvPath)
+	if err != nil {
+		t.Fatalf("failed to reload revocation vectors: %v", err)
+	}
+	vector := monitor.RevocationVectors.Get(testCAID, mtr.LetsRevokeRevocationType)
+	if vector == nil || vector.SRD.RevDigest.Timestamp != 1002 {
+		t.Fatalf("reconstructed CRV (%v) isn't from the SRD at timestamp 1002", vector)
+	}
+	crv, err := ctca.DecompressCRV(vector.CRV)
+	if err != nil {
+		t.Fatalf("failed to decompress reconstructed CRV: %v", err)
+	}
+	if !ctca.Equals(crv, expectedCRV) {
+		t.Fatalf("reconstructed CRV doesn't hold revocations 1, 3 and 5")
+	}
+
+	// SRDs after a missing SRD can't be checked
+	delta := ctca.GetCRVDelta([]uint64{7})
+	if _, err := monitor.ProcessSRD(mustCreateCASRD(t, monitor, ctca.ApplyCRVDeltaToCRV(crv, delta), delta, 1006)); err == nil {
+		t.Fatalf("processed SRD after a missing SRD")
+	}
+}
+
+func TestProcessSRDRequiresGenesisSRD(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// The monitor first sees the CA after its genesis SRD revoked 1 and 5
+	delta := ctca.GetCRVDelta([]uint64{3})
+	crv := ctca.ApplyCRVDeltaToCRV(ctca.CreateCRV([]uint64{1, 5}, 0), delta)
+	if pom, err := monitor.ProcessSRD(mustCreateCASRD(t, monitor, crv, delta, 1002)); !errors.Is(err, ErrNoGenesisSRD) || pom != nil {
+		t.Fatalf("processing SRD after the genesis SRD returned PoM (%v) and error (%v), expected %v", pom, err, ErrNoGenesisSRD)
+	}
+	if vector := monitor.RevocationVectors.Get(testCAID, mtr.LetsRevokeRevocationType); vector != nil {
+		t.Fatalf("CRV reconstructed up to timestamp %d without the genesis SRD", vector.SRD.RevDigest.Timestamp)
+	}
+	if _, err := monitor.GetRevocationStatus(&mtr.RevocationStatusRequest{CAID: testCAID, RevocationNumber: 3}); err == nil {
+		t.Fatalf("got revocation status without the genesis SRD of the CA")
+	}
+}
+
+func TestProcessSRDCreatesPOM(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	crv := mustProcessFirstSRDs(t, monitor)
+
+	// The CA signs a CRV that drops revocation 3
+	delta := ctca.GetCRVDelta([]uint64{7})
+	equivocatedCRV := ctca.ApplyCRVDeltaToCRV(ctca.CreateCRV([]uint64{1, 5}, 0), delta)
+	pom, err := monitor.ProcessSRD(mustCreateCASRD(t, monitor, equivocatedCRV, delta, 1004))
+	if err != nil {
+		t.Fatalf("failed to process SRD: %v", err)
+	}
+	if pom == nil || pom.TypeID != mtr.InconsistentCRVPOMTypeID {
+		t.Fatalf("inconsistent CRVHash didn't produce %s PoM: %v", mtr.InconsistentCRVPOMTypeID, pom)
+	}
+	if err := monitor.VerifyCTObject(pom); err != nil {
+		t.Fatalf("InconsistentCRVPOM failed verification: %v", err)
+	}
+
+	// The CRVDelta within the RevData doesn't match the signed CRVDeltaHash
+	srdCT := mustCreateCASRD(t, monitor, ctca.ApplyCRVDeltaToCRV(crv, delta), delta, 1004)
+	var srdWithRevData mtr.SRDWithRevData
+	json.Unmarshal(srdCT.Blob, &srdWithRevData)
+	srdWithRevData.RevData.CRVDelta, err = ctca.CompressCRV(ctca.GetCRVDelta([]uint64{8}))
+	if err != nil {
+		t.Fatalf("failed to compress CRVDelta: %v", err)
+	}
+	srdCT, err = mtr.ConstructCTObject(&srdWithRevData)
+	if err != nil {
+		t.Fatalf("failed to construct SRDWithRevData CTObject: %v", err)
+	}
+	if pom, err := monitor.ProcessSRD(srdCT); !errors.Is(err, ErrInvalidRevData) || pom != nil {
+		t.Fatalf("processing SRD with a swapped CRVDelta returned PoM (%v) and error (%v), expected %v", pom, err, ErrInvalidRevData)
+	}
+
+	// A PoM built from the swapped CRVDelta doesn't incriminate the CA
+	prev := monitor.RevocationVectors.Get(testCAID, mtr.LetsRevokeRevocationType)
+	forgedPOM, err := mtr.CreateInconsistentCRVPOM(&prev.SRD, prev.CRV, srdCT)
+	if err != nil {
+		t.Fatalf("failed to create InconsistentCRVPOM: %v", err)
+	}
+	if err := monitor.VerifyCTObject(forgedPOM); err == nil {
+		t.Fatalf("InconsistentCRVPOM with a CRVDelta the CA didn't sign passed verification")
+	}
+	if vector := monitor.RevocationVectors.Get(testCAID, mtr.LetsRevokeRevocationType); vector.SRD.RevDigest.Timestamp != 1002 {
+		t.Fatalf("inconsistent SRD at timestamp %d was applied to reconstructed CRV", vector.SRD.RevDigest.Timestamp)
+	}
+}
+
+func TestGetRevocationStatus(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	mustProcessFirstSRDs(t, monitor)
+
+	tests := []struct {
+		revocationNumber 	uint64
+		timestamp 			uint64
+		revoked 			bool
+		srdTimestamp 		uint64
+	}{
+		{3, 0, true, 1002},
+		{2, 0, false, 1002},
+		{1 << 20, 5000, false, 1002},
+		{5, 1001, true, 1000},
+		{3, 1001, false, 1000},
+	}
+	for _, test := range tests {
+		statusReq := &mtr.RevocationStatusRequest{CAID: testCAID, RevocationNumber: test.revocationNumber, Timestamp: test.timestamp}
+		statusResp, err := monitor.GetRevocationStatus(statusReq)
+		if err != nil {
+			t.Fatalf("failed to get revocation status of %d at %d: %v", test.revocationNumber, test.timestamp, err)
+		}
+		if statusResp.Revoked != test.revoked || statusResp.SRD.Timestamp != test.srdTimestamp {
+			t.Fatalf("revocation status of %d at %d is %v from SRD at %d, expected %v from SRD at %d", test.revocationNumber, test.timestamp, statusResp.Revoked, statusResp.SRD.Timestamp, test.revoked, test.srdTimestamp)
+		}
+		if err := monitor.VerifyCTObject(&statusResp.SRD); err != nil {
+			t.Fatalf("SRD of revocation status failed verification: %v", err)
+		}
+	}
+
+	if _, err := monitor.GetRevocationStatus(&mtr.RevocationStatusRequest{CAID: testCAID, RevocationNumber: 1, Timestamp: 999}); err == nil {
+		t.Fatalf("got revocation status from before the first SRD of the CA")
+	}
+}
+
+// Start a fake CA and add it to the monitor's CA list
+func mustUseFakeCA(t *testing.T, m *Monitor) *fakeca.CA {
+	t.Helper()
+	fakeCA := fakeca.New(t)
+	m.CAList.CAOperators = append(m.CAList.CAOperators, &entitylist.CAOperator{Name: "Fake", CAs: []*entitylist.CAInfo{fakeCA.CAInfo()}})
+	return fakeCA
+}
+
+// Produce the next SRD of the CA, which the monitor must verify, process and store
+func mustProcessCASRD(t *testing.T, m *Monitor, fakeCA *fakeca.CA) *mtr.CTObject {
+	t.Helper()
+	srdCT, err := fakeCA.ProduceSRD()
+	if err != nil {
+		t.Fatalf("failed to produce SRD: %v", err)
+	}
+	if err := m.VerifyCTObject(srdCT); err != nil {
+		t.Fatalf("SRD failed verification: %v", err)
+	}
+	if pom, err := m.ProcessSRD(srdCT); err != nil || pom != nil {
+		t.Fatalf("processing SRD returned PoM (%v) and error (%v)", pom, err)
+	}
+	if err := m.AddEntry(srdCT); err != nil {
+		t.Fatalf("failed to store SRD: %v", err)
+	}
+	return srdCT
+}
+
+func TestAuditSRDFromHonestCA(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustUseFakeCA(t, monitor)
+	fakeCA.Revoke(1, 5)
+	mustProcessCASRD(t, monitor, fakeCA)
+	fakeCA.Revoke(3)
+	srdCT := mustProcessCASRD(t, monitor, fakeCA)
+
+	// Another party auditing the SRD it got from the CA gets an SRDAuditOK signed by the monitor
+	auditOK, err := monitor.Audit(fakeCA.SRD(srdCT.Timestamp))
+	if err != nil {
+		t.Fatalf("failed to audit SRD: %v", err)
+	}
+	if auditOK.TypeID != mtr.SRDAuditOKTypeID {
+		t.Fatalf("audit of honest SRD returned %s CTObject", auditOK.TypeID)
+	}
+	deconAuditOK, err := auditOK.DeconstructSRDAuditOK()
+	if err != nil {
+		t.Fatalf("failed to deconstruct SRDAuditOK: %v", err)
+	}
+	if err := signature.VerifySignature(testMonitorKey, deconAuditOK.SRD, deconAuditOK.Signature); err != nil {
+		t.Fatalf("SRDAuditOK signature doesn't verify: %v", err)
+	}
+
+	statusResp, err := monitor.GetRevocationStatus(&mtr.RevocationStatusRequest{CAID: fakeCA.CAID(), RevocationNumber: 3})
+	if err != nil || !statusResp.Revoked {
+		t.Fatalf("revocation 3 of the CA isn't in the reconstructed CRV: %v", err)
+	}
+}
+
+func TestAuditSRDFromEquivocatingCA(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustUseFakeCA(t, monitor)
+	fakeCA.Revoke(1, 5)
+	mustProcessCASRD(t, monitor, fakeCA)
+
+	// The CA hides revocation 3 from everyone but the monitor
+	fakeCA.Equivocate(3)
+	fakeCA.Revoke(3, 7)
+	srdCT := mustProcessCASRD(t, monitor, fakeCA)
+	forkedCT := fakeCA.ForkedSRD(srdCT.Timestamp)
+	if err := monitor.VerifyCTObject(forkedCT); err != nil {
+		t.Fatalf("forked SRD failed verification: %v", err)
+	}
+
+	pom, err := monitor.Audit(forkedCT)
+	if err != nil {
+		t.Fatalf("failed to audit forked SRD: %v", err)
+	}
+	if pom.TypeID != mtr.ConflictingSRDPOMTypeID || pom.Subject != fakeCA.CAID() || pom.Timestamp != srdCT.Timestamp {
+		t.Fatalf("audit of forked SRD returned %s CTObject about %s at %d", pom.TypeID, pom.Subject, pom.Timestamp)
+	}
+	deconPOM, err := pom.DeconstructConflictingSRDPOM()
+	if err != nil {
+		t.Fatalf("failed to deconstruct ConflictingSRDPOM: %v", err)
+	}
+	for _, srd := range []mtr.SignedRevocationDigest{deconPOM.SRD1, deconPOM.SRD2} {
+		if err := mtr.VerifySRDSignature(&srd, fakeCA.Key()); err != nil {
+			t.Fatalf("SRD within ConflictingSRDPOM doesn't verify: %v", err)
+		}
+	}
+	if bytes.Equal(deconPOM.SRD1.RevDigest.CRVHash, deconPOM.SRD2.RevDigest.CRVHash) {
+		t.Fatalf("SRDs within ConflictingSRDPOM have the same CRVHash")
+	}
+
+	// The forked CRV stays consistent on its own, so only comparing SRDs shows the equivocation
+	forkedMonitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	forkedMonitor.CAList = monitor.CAList
+	for _, forkedSRDCT := range []*mtr.CTObject{fakeCA.SRD(srdCT.Timestamp - 1), forkedCT} {
+		if pom, err := forkedMonitor.ProcessSRD(forkedSRDCT); err != nil || pom != nil {
+			t.Fatalf("processing forked SRD at %d returned PoM (%v) and error (%v)", forkedSRDCT.Timestamp, pom, err)
+		}
+	}
+	statusResp, err := forkedMonitor.GetRevocationStatus(&mtr.RevocationStatusRequest{CAID: fakeCA.CAID(), RevocationNumber: 3})
+	if err != nil || statusResp.Revoked {
+		t.Fatalf("revocation 3 hidden by the CA is in the forked CRV: %v", err)
+	}
+}
+
+func TestAddEntryRejectsConflictingSRD(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustUseFakeCA(t, monitor)
+	fakeCA.Revoke(1, 5)
+	mustProcessCASRD(t, monitor, fakeCA)
+	fakeCA.Equivocate(3)
+	fakeCA.Revoke(3)
+	srdCT := mustProcessCASRD(t, monitor, fakeCA)
+
+	// A second validly signed SRD for the same timestamp proves the CA misbehaved
+	forkedCT := fakeCA.ForkedSRD(srdCT.Timestamp)
+	if pom, err := monitor.ProcessSRD(forkedCT); err != nil || pom != nil {
+		t.Fatalf("processing forked SRD returned PoM (%v) and error (%v)", pom, err)
+	}
+	err = monitor.AddEntry(forkedCT)
+	var misbehavingCAErr *MisbehavingCAError
+	if !errors.As(err, &misbehavingCAErr) {
+		t.Fatalf("conflicting SRD didn't return MisbehavingCAError: %v", err)
+	}
+	pom := misbehavingCAErr.POM
+	if pom.TypeID != mtr.ConflictingSRDPOMTypeID || pom.Subject != fakeCA.CAID() || pom.Timestamp != srdCT.Timestamp {
+		t.Fatalf("conflicting SRD returned %s CTObject about %s at %d", pom.TypeID, pom.Subject, pom.Timestamp)
+	}
+	if monitor.GetEntry(pom.Identifier()) == nil {
+		t.Fatalf("ConflictingSRDPOM wasn't stored")
+	}
+	if storedCT := monitor.GetEntry(srdCT.Identifier()); storedCT == nil || !bytes.Equal(storedCT.Digest, srdCT.Digest) {
+		t.Fatalf("conflicting SRD replaced the stored SRD")
+	}
+	statusResp, err := monitor.GetRevocationStatus(&mtr.RevocationStatusRequest{CAID: fakeCA.CAID(), RevocationNumber: 3})
+	if err != nil || !statusResp.Revoked {
+		t.Fatalf("conflicting SRD replaced the reconstructed CRV: %v", err)
+	}
+}
+
+// Start a fake CA and add it to both the monitor's CA list and its CAIDMap
+func mustMonitorFakeCA(t *testing.T, m *Monitor) *fakeca.CA {
+	t.Helper()
+	fakeCA := mustUseFakeCA(t, m)
+	caClient, err := caclient.NewCAClient(fakeCA.CAInfo())
+	if err != nil {
+		t.Fatalf("failed to create CAClient: %v", err)
+	}
+	m.CAIDMap[fakeCA.CAID()] = caClient
+	return fakeCA
+}
+
+// Produce the next SRD of the CA after revoking the given revocation numbers
+func mustProduceSRD(t *testing.T, fakeCA *fakeca.CA, revocationNumbers ...uint64) *mtr.CTObject {
+	t.Helper()
+	fakeCA.Revoke(revocationNumbers...)
+	srdCT, err := fakeCA.ProduceSRD()
+	if err != nil {
+		t.Fatalf("failed to produce SRD: %v", err)
+	}
+	return srdCT
+}
+
+// Fetch the latest SRD of the CA, after which numStored SRDs of the CA must be stored and its CRV reconstructed up to crvCT
+// A nil crvCT means no CRV of the CA must be reconstructed
+func mustFetchSRD(t *testing.T, m *Monitor, fakeCA *fakeca.CA, latestCT *mtr.CTObject, numStored int, crvCT *mtr.CTObject) {
+	t.Helper()
+	fetched, err := m.FetchSRD(context.Background(), fakeCA.CAID())
+	if err != nil {
+		t.Fatalf("failed to fetch SRD: %v", err)
+	}
+	if !bytes.Equal(fetched.Digest, latestCT.Digest) {
+		t.Fatalf("fetched %s CTObject at timestamp %d, expected latest SRD at timestamp %d", fetched.TypeID, fetched.Timestamp, latestCT.Timestamp)
+	}
+	if stored := m.Storage.GetEntries(mtr.SRDWithRevDataTypeID, fakeCA.CAID()); len(stored) != numStored {
+		t.Fatalf("stored %d SRDs of the CA, expected %d", len(stored), numStored)
+	}
+	vector := m.RevocationVectors.Get(fakeCA.CAID(), mtr.LetsRevokeRevocationType)
+	if crvCT == nil && vector != nil {
+		t.Fatalf("CRV of the CA reconstructed up to timestamp %d, expected none", vector.SRD.RevDigest.Timestamp)
+	}
+	if crvCT != nil && (vector == nil || vector.SRD.RevDigest.Timestamp != crvCT.Timestamp) {
+		t.Fatalf("CRV of the CA isn't reconstructed up to timestamp %d", crvCT.Timestamp)
+	}
+}
+
+func TestFetchSRD(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustMonitorFakeCA(t, monitor)
+
+	// The CRV is reconstructed from the genesis SRD of the CA
+	latestCT := mustProduceSRD(t, fakeCA, 1, 5)
+	mustFetchSRD(t, monitor, fakeCA, latestCT, 1, latestCT)
+
+	// Until the CA produces another SRD there is nothing new
+	mustFetchSRD(t, monitor, fakeCA, latestCT, 1, latestCT)
+
+	// The next SRD builds directly on the reconstructed CRV
+	latestCT = mustProduceSRD(t, fakeCA, 3)
+	crvCT := latestCT
+	mustFetchSRD(t, monitor, fakeCA, latestCT, 2, crvCT)
+
+	// SRDs of the MMDs missed since are fetched before the latest one
+	mustProduceSRD(t, fakeCA, 7)
+	mustProduceSRD(t, fakeCA)
+	latestCT = mustProduceSRD(t, fakeCA, 9)
+	mustFetchSRD(t, monitor, fakeCA, latestCT, 5, latestCT)
+	for _, revocationNumber := range []uint64{1, 3, 5, 7, 9} {
+		statusResp, err := monitor.GetRevocationStatus(&mtr.RevocationStatusRequest{CAID: fakeCA.CAID(), RevocationNumber: revocationNumber})
+		if err != nil || !statusResp.Revoked {
+			t.Fatalf("revocation %d of the CA isn't in the reconstructed CRV: %v", revocationNumber, err)
+		}
+	}
+
+	// A monitor that first fetches from the CA after its genesis SRD fetches every SRD of the CA
+	lateMonitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	lateMonitor.CAList = monitor.CAList
+	lateMonitor.CAIDMap[fakeCA.CAID()] = monitor.CAIDMap[fakeCA.CAID()]
+	mustFetchSRD(t, lateMonitor, fakeCA, latestCT, 5, latestCT)
+}
+
+func TestFetchSRDFromCAWithoutPastSRDs(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustUseFakeCA(t, monitor)
+
+	// The CA only serves its latest SRD
+	caURL, err := url.Parse(fakeCA.URL())
+	if err != nil {
+		t.Fatalf("failed to parse CA URL: %v", err)
+	}
+	serveMux := http.NewServeMux()
+	serveMux.Handle(ctca.GetRevocationStatusPath, httputil.NewSingleHostReverseProxy(caURL))
+	server := httptest.NewServer(serveMux)
+	defer server.Close()
+	caInfo := fakeCA.CAInfo()
+	caInfo.CAURL = server.URL
+	caClient, err := caclient.NewCAClient(caInfo)
+	if err != nil {
+		t.Fatalf("failed to create CAClient: %v", err)
+	}
+	monitor.CAIDMap[fakeCA.CAID()] = caClient
+
+	crvCT := mustProduceSRD(t, fakeCA, 1, 5)
+	mustFetchSRD(t, monitor, fakeCA, crvCT, 1, crvCT)
+
+	// After a missed SRD the latest SRD is stored without reconstructing the CRV
+	mustProduceSRD(t, fakeCA, 7)
+	latestCT := mustProduceSRD(t, fakeCA, 9)
+	mustFetchSRD(t, monitor, fakeCA, latestCT, 2, crvCT)
+
+	// A monitor that first fetches from the CA after its genesis SRD only stores the latest SRD
+	lateMonitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	lateMonitor.CAList = monitor.CAList
+	lateMonitor.CAIDMap[fakeCA.CAID()] = caClient
+	mustFetchSRD(t, lateMonitor, fakeCA, latestCT, 1, nil)
+}
+
+func TestFetchSRDRequiresMonitoredCA(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustUseFakeCA(t, monitor)
+	mustProduceSRD(t, fakeCA, 1)
+	if _, err := monitor.FetchSRD(context.Background(), fakeCA.CAID()); err == nil {
+		t.Fatalf("fetched SRD of CA not in the monitor's CAIDMap")
+	}
+}
@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/n-ct/ct-monitor/entitylist"
+)
+
+const (
+	// MMD used for logs and CAs that don't specify one in their list
+	defaultMMD = 24 * time.Hour
+
+	// Time between attempts to fetch an STH from a log that failed to serve one
+	defaultFetchRetryInterval = time.Minute
+)
+
+// Scheduler fetches an STH from every log in the Monitor's LogIDMap once every MMD period
+// Each fetch happens MMDAccessDelay seconds after the log's MMDEnd, which is when the log's object for the period can be accessed
+// A log that doesn't serve an STH before the access time of the next period is reported with an Alert
+// After each fetched STH the log's entries are tailed up to the tree size of the STH
+// The SRDs of every CA in the Monitor's CAIDMap are fetched once every MMD of the CA
+type Scheduler struct {
+	m *Monitor
+	now func() time.Time
+	retryInterval time.Duration
+	tailer *Tailer
+}
+
+// Create a new Scheduler for the given Monitor
+func NewScheduler(m *Monitor) *Scheduler {
+	return &Scheduler{m, time.Now, defaultFetchRetryInterval, NewTailer(m)}
+}
+
+// Poll every log and CA until ctx is cancelled. Blocks until all logs and CAs have stopped being polled
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for logID := range s.m.LogIDMap {
+		// Tailing can take longer than an MMD, so it runs separately from polling
+		tailSignal := make(chan struct{}, 1)
+		wg.Add(2)
+		go func(logID string) {
+			defer wg.Done()
+			s.pollLog(ctx, logID, tailSignal)
+		}(logID)
+		go func(logID string) {
+			defer wg.Done()
+			s.tailLog(ctx, logID, tailSignal)
+		}(logID)
+	}
+	for caID := range s.m.CAIDMap {
+		wg.Add(1)
+		go func(caID string) {
+			defer wg.Done()
+			s.pollCA(ctx, caID)
+		}(caID)
+	}
+	wg.Wait()
+}
+
+// Tail the log up to the latest stored STH every time tailSignal receives
+func (s *Scheduler) tailLog(ctx context.Context, logID string, tailSignal <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tailSignal:
+		}
+		sthCT := s.m.GetLatestSTHEntry(logID)
+		if sthCT == nil {
+			continue
+		}
+		sth, err := sthCT.DeconstructSTH()
+		if err != nil {
+			glog.Errorf("failed to tail Logger %s: %v", logID, err)
+			continue
+		}
+		if err := s.tailer.TailLog(ctx, logID, sth.TreeHeadData.TreeSize); err != nil {
+			glog.Errorf("%v", err)
+		}
+	}
+}
+
+// Fetch, store, and gossip an STH from the log at the access time of every MMD period
+func (s *Scheduler) pollLog(ctx context.Context, logID string, tailSignal chan<- struct{}) {
+	logInfo := &s.m.LogIDMap[logID].LogInfo
+	mmd := logMMD(logInfo)
+	accessDelay := mmdAccessDelay(logInfo)
+	mmdEnd := nextMMDEnd(logInfo, s.now())
+	for {
+		pollTime := mmdEnd.Add(accessDelay)
+		glog.V(1).Infof("next STH poll of Logger %s at %v", logID, pollTime)
+		if !s.waitUntil(ctx, pollTime) {
+			return
+		}
+
+		// The log has until the access time of the next period to serve an STH
+		if s.fetchSTHBefore(ctx, logID, pollTime.Add(mmd)) {
+			// Don't block if the previous signal hasn't been picked up. The tailer always tails to the latest STH
+			select {
+			case tailSignal <- struct{}{}:
+			default:
+			}
+		} else {
+			if ctx.Err() != nil {
+				return
+			}
+			if _, err := s.m.AlertNonRespondingLog(logID, mmdEnd); err != nil {
+				glog.Errorf("failed to alert nonresponding Logger %s: %v", logID, err)
+			}
+		}
+
+		// Skip periods that were missed entirely, e.g. while the machine was suspended
+		mmdEnd = mmdEnd.Add(mmd)
+		if s.now().After(mmdEnd.Add(accessDelay).Add(mmd)) {
+			mmdEnd = nextMMDEnd(logInfo, s.now())
+		}
+	}
+}
+
+// Fetch, store, and gossip the SRDs of the CA every MMD of the CA, starting right away
+// A fetch that fails is left to the next MMD, which catches up on the SRDs missed in between
+func (s *Scheduler) pollCA(ctx context.Context, caID string) {
+	mmd := caMMD(&s.m.CAIDMap[caID].CAInfo)
+	for {
+		pollTime := s.now().Add(mmd)
+		fetchCtx, cancel := context.WithDeadline(ctx, pollTime)
+		if _, err := s.m.FetchSRD(fetchCtx, caID); err != nil && ctx.Err() == nil {
+			glog.Errorf("scheduled SRD fetch failed: %v", err)
+		}
+		cancel()
+		glog.V(1).Infof("next SRD poll of CA %s at %v", caID, pollTime)
+		if !s.waitUntil(ctx, pollTime) {
+			return
+		}
+	}
+}
+
+// Fetch an STH from the log, retrying until the deadline
+// An STH proving the log misbehaved counts as served, since the log responded and its PoM was already stored and gossiped
+// Returns false if the log didn't serve an STH before the deadline or ctx was cancelled
+func (s *Scheduler) fetchSTHBefore(ctx context.Context, logID string, deadline time.Time) bool {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	for {
+		_, err := s.m.FetchSTH(ctx, logID)
+		if err == nil {
+			return true
+		}
+		var misbehavingLogErr *MisbehavingLogError
+		if errors.As(err, &misbehavingLogErr) {
+			glog.Errorf("scheduled STH fetch got PoM: %v", err)
+			return true
+		}
+		glog.Errorf("scheduled STH fetch failed: %v", err)
+		retryTime := s.now().Add(s.retryInterval)
+		if !retryTime.Before(deadline) || !s.waitUntil(ctx, retryTime) {
+			return false
+		}
+	}
+}
+
+// Wait until the given time. Returns false if ctx was cancelled first
+func (s *Scheduler) waitUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(t.Sub(s.now()))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Get the MMD of the log
+func logMMD(logInfo *entitylist.LogInfo) time.Duration {
+	if logInfo.MMD <= 0 {
+		return defaultMMD
+	}
+	return time.Duration(logInfo.MMD) * time.Second
+}
+
+// Get the MMD of the CA
+func caMMD(caInfo *entitylist.CAInfo) time.Duration {
+	if caInfo.MMD == 0 {
+		return defaultMMD
+	}
+	return time.Duration(caInfo.MMD) * time.Second
+}
+
+// Get the delay after MMDEnd before the log's object for the period can be accessed
+func mmdAccessDelay(logInfo *entitylist.LogInfo) time.Duration {
+	return time.Duration(logInfo.MMDAccessDelay) * time.Second
+}
+
+// Get the end of the earliest MMD period of the log whose object can't be accessed yet at now
+// MMD periods are aligned to the log's MMDEnd clock time, or to midnight UTC if the log has no MMDEnd
+func nextMMDEnd(logInfo *entitylist.LogInfo, now time.Time) time.Time {
+	now = now.UTC()
+	var hour, minute, second int
+	if logInfo.MMDEnd != nil {
+		hour = int(logInfo.MMDEnd.Hour)
+		minute = int(logInfo.MMDEnd.Minute)
+		second = int(logInfo.MMDEnd.Second)
+	}
+	anchor := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, second, 0, time.UTC)
+
+	// Find the smallest k such that anchor + k*mmd + accessDelay is after now
+	mmd := logMMD(logInfo)
+	elapsed := now.Sub(anchor.Add(mmdAccessDelay(logInfo)))
+	periods := elapsed / mmd
+	if elapsed < 0 && elapsed%mmd != 0 {
+		periods--
+	}
+	return anchor.Add((periods + 1) * mmd)
+}
@@ -0,0 +1,196 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+)
+
+func TestNextMMDEnd(t *testing.T) {
+	day := func(d, h, m, s int) time.Time {
+		return time.Date(2021, time.January, d, h, m, s, 0, time.UTC)
+	}
+	noon := &entitylist.ClockTime{Hour: 12}
+	oneAM := &entitylist.ClockTime{Hour: 1}
+	tests := []struct {
+		mmd 		int32
+		mmdEnd 		*entitylist.ClockTime
+		accessDelay uint32
+		now 		time.Time
+		expected 	time.Time
+	}{
+		{86400, noon, 60, day(1, 11, 0, 0), day(1, 12, 0, 0)},
+		{86400, noon, 60, day(1, 12, 0, 30), day(1, 12, 0, 0)},
+		{86400, noon, 60, day(1, 12, 1, 0), day(2, 12, 0, 0)},
+		{86400, noon, 60, day(1, 13, 0, 0), day(2, 12, 0, 0)},
+		{3600, nil, 0, day(1, 5, 30, 0), day(1, 6, 0, 0)},
+		{7200, oneAM, 0, day(1, 0, 30, 0), day(1, 1, 0, 0)},
+		{7200, oneAM, 0, day(1, 2, 0, 0), day(1, 3, 0, 0)},
+		{0, nil, 0, day(1, 5, 0, 0), day(2, 0, 0, 0)},
+	}
+
+	for _, test := range tests {
+		logInfo := &entitylist.LogInfo{MMD: test.mmd, MMDEnd: test.mmdEnd, MMDAccessDelay: test.accessDelay}
+		mmdEnd := nextMMDEnd(logInfo, test.now)
+		if !mmdEnd.Equal(test.expected) {
+			t.Errorf("nextMMDEnd with mmd (%d), mmdEnd (%v), and accessDelay (%d) at %v is %v, expected %v", test.mmd, test.mmdEnd, test.accessDelay, test.now, mmdEnd, test.expected)
+		}
+	}
+}
+
+// Fetch the STH of the log, which must be stored as a CTObject with the expected TypeID
+func mustFetchSTH(t *testing.T, m *Monitor, logID string, expectedTypeID string) *mtr.CTObject {
+	t.Helper()
+	fetched, err := m.FetchSTH(context.Background(), logID)
+	if err != nil {
+		t.Fatalf("failed to fetch STH: %v", err)
+	}
+	if fetched.TypeID != expectedTypeID || m.GetEntry(fetched.Identifier()) == nil {
+		t.Fatalf("fetch stored %s CTObject, expected %s", fetched.TypeID, expectedTypeID)
+	}
+	return fetched
+}
+
+func TestFetchSTH(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 2)
+
+	// With no previous STH only the STH is fetched
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+
+	// Until the tree grows there is nothing to prove
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+
+	// Once the tree grows the STH comes with a ConsistencyProof from the stored STH
+	log.AddEntries(2)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHPOCTypeID)
+}
+
+func TestFetchSTHFromForkedLog(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 2)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+
+	// The grown tree no longer contains the tree of the stored STH
+	log.AddEntries(2)
+	log.Fork(1)
+	pom := mustFetchSTH(t, monitor, log.LogID(), mtr.InconsistentSTHPOMTypeID)
+	deconPOM, err := pom.DeconstructInconsistentSTHPOM()
+	if err != nil {
+		t.Fatalf("failed to deconstruct PoM: %v", err)
+	}
+	if err := mtr.VerifySTHConsistency(&deconPOM.STH1, &deconPOM.STH2, &deconPOM.ConsistencyProof); err == nil {
+		t.Fatalf("ConsistencyProof within %s PoM verifies", pom.TypeID)
+	}
+
+	// Other monitors accept the PoM once the log serves them the same broken proof
+	if err := monitor.VerifyCTObject(pom); err != nil {
+		t.Fatalf("%s PoM failed verification: %v", pom.TypeID, err)
+	}
+}
+
+func TestFetchSTHDetectsRollback(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 4)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+
+	// The log presents a different tree of the same size
+	log.Fork(2)
+	var misbehavingErr *MisbehavingLogError
+	if _, err := monitor.FetchSTH(context.Background(), log.LogID()); !errors.As(err, &misbehavingErr) {
+		t.Fatalf("fetching STH of rolled back tree returned %v, expected MisbehavingLogError", err)
+	}
+	if poms := monitor.Storage.GetEntries(mtr.NonMonotonicSTHPOMTypeID, log.LogID()); len(poms) != 1 {
+		t.Fatalf("got %d %s PoMs, expected 1", len(poms), mtr.NonMonotonicSTHPOMTypeID)
+	}
+}
+
+func TestSchedulerStopsFetchingFromMisbehavingLog(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 4)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+	log.Fork(2)
+
+	// The log responded, so it isn't retried until the deadline and reported as nonresponding
+	scheduler := NewScheduler(monitor)
+	scheduler.retryInterval = time.Millisecond
+	if !scheduler.fetchSTHBefore(context.Background(), log.LogID(), time.Now().Add(5 * time.Second)) {
+		t.Fatalf("STH of rolled back tree wasn't counted as served")
+	}
+	if poms := monitor.Storage.GetEntries(mtr.NonMonotonicSTHPOMTypeID, log.LogID()); len(poms) != 1 {
+		t.Fatalf("got %d %s PoMs, expected 1", len(poms), mtr.NonMonotonicSTHPOMTypeID)
+	}
+}
+
+func TestFetchSTHRejectsBadSignature(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	log := mustUseFakeLog(t, monitor, 2)
+	log.SetBadSignature(true)
+	if _, err := monitor.FetchSTH(context.Background(), log.LogID()); err == nil {
+		t.Fatalf("fetched STH signed with a key other than the log's key")
+	}
+	if monitor.GetLatestSTHEntry(log.LogID()) != nil {
+		t.Fatalf("stored STH signed with a key other than the log's key")
+	}
+}
+
+// Wait until the monitor has reconstructed the CRV of the CA up to the SRD
+func waitForCRV(t *testing.T, m *Monitor, caID string, srdCT *mtr.CTObject) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		vector := m.RevocationVectors.Get(caID, mtr.LetsRevokeRevocationType)
+		if vector != nil && vector.SRD.RevDigest.Timestamp == srdCT.Timestamp {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("CRV of CA %s never reconstructed up to timestamp %d", caID, srdCT.Timestamp)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchedulerPollsCA(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fakeCA := mustMonitorFakeCA(t, monitor)
+	mustProduceSRD(t, fakeCA, 1)
+	srdCT := mustProduceSRD(t, fakeCA, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewScheduler(monitor).Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The first poll happens right away and the next one an MMD of the CA later
+	waitForCRV(t, monitor, fakeCA.CAID(), srdCT)
+	srdCT = mustProduceSRD(t, fakeCA, 3)
+	waitForCRV(t, monitor, fakeCA.CAID(), srdCT)
+}
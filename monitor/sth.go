@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+// Error returned by AddEntry when an STH proves its log misbehaved
+// The STH isn't stored. The PoM of the misbehaviour is stored and gossiped instead
+type MisbehavingLogError struct {
+	LogID 	string
+	Reason 	string
+	POM 	*mtr.CTObject
+}
+
+func (e *MisbehavingLogError) Error() string {
+	return fmt.Sprintf("Logger %s misbehaved: %s", e.LogID, e.Reason)
+}
+
+// Store the STH or STH_POC CTObject unless it conflicts with the stored STH of the same log and timestamp, or its tree rolls back a stored STH
+// A misbehaving log produces a ConflictingSTHPOM or NonMonotonicSTHPOM which is stored and gossiped, and a MisbehavingLogError is returned
+func (m *Monitor) addSTHEntry(ctObject *mtr.CTObject) error {
+	m.sthLock.Lock()
+	defer m.sthLock.Unlock()
+	misbehaviour, err := m.checkSplitView(ctObject)
+	if err != nil {
+		return fmt.Errorf("failed to check %s CTObject for split view: %w", ctObject.TypeID, err)
+	}
+	if misbehaviour == nil {
+		misbehaviour, err = m.checkRollback(ctObject)
+		if err != nil {
+			return fmt.Errorf("failed to check %s CTObject for rollback: %w", ctObject.TypeID, err)
+		}
+	}
+	if misbehaviour == nil {
+		return m.addEntry(ctObject)
+	}
+	if err := m.addEntry(misbehaviour.POM); err != nil {
+		return err
+	}
+	m.Gossip(misbehaviour.POM)
+	return misbehaviour
+}
+
+// Get the misbehaviour with a ConflictingSTHPOM if the STH within the STH or STH_POC CTObject differs from the STH the monitor stored for the same log and timestamp
+// The STHs are compared regardless of whether each arrived with a ConsistencyProof. Returns nil if they match or none is stored
+func (m *Monitor) checkSplitView(ctObject *mtr.CTObject) (*MisbehavingLogError, error) {
+	sthCT, err := baseSTHCTObject(ctObject)
+	if err != nil {
+		return nil, err
+	}
+	id := ctObject.Identifier()
+	for _, typeID := range []string{mtr.STHTypeID, mtr.STHPOCTypeID} {
+		id.First = typeID
+		storedCT := m.GetEntry(id)
+		if storedCT == nil {
+			continue
+		}
+		storedSTHCT, err := baseSTHCTObject(storedCT)
+		if err != nil {
+			return nil, err
+		}
+		// Only STHs signed for the same timestamp prove a split view
+		sameTimestamp, err := equalTimestamps(storedSTHCT, sthCT)
+		if err != nil {
+			return nil, err
+		}
+		if !sameTimestamp {
+			continue
+		}
+		// Logs may sign the same tree head more than once, so only a different tree head is a split view
+		sameTreeHead, err := equalTreeHeads(storedSTHCT, sthCT)
+		if err != nil {
+			return nil, err
+		}
+		if sameTreeHead {
+			continue
+		}
+		pom, err := mtr.CreateConflictingSTHPOM(storedSTHCT, sthCT)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PoM for split view: %w", err)
+		}
+		return &MisbehavingLogError{LogID: pom.Subject, Reason: fmt.Sprintf("split view of its STH at timestamp %d", pom.Timestamp), POM: pom}, nil
+	}
+	return nil, nil
+}
+
+// Get the misbehaviour with a NonMonotonicSTHPOM if the tree of the STH within the STH or STH_POC CTObject doesn't grow monotonically
+// from the stored STHs of the log with earlier timestamps, or to the stored STHs with later timestamps. Returns nil if it does
+func (m *Monitor) checkRollback(ctObject *mtr.CTObject) (*MisbehavingLogError, error) {
+	sth, err := ctObject.DeconstructSTH()
+	if err != nil {
+		return nil, err
+	}
+	for _, typeID := range []string{mtr.STHTypeID, mtr.STHPOCTypeID} {
+		for _, storedCT := range m.Storage.GetEntries(typeID, sth.LogID) {
+			storedSTH, err := storedCT.DeconstructSTH()
+			if err != nil {
+				return nil, err
+			}
+			var monotonicErr error
+			switch {
+			case storedSTH.TreeHeadData.Timestamp < sth.TreeHeadData.Timestamp:
+				monotonicErr = mtr.VerifySTHsMonotonic(storedSTH, sth)
+			case storedSTH.TreeHeadData.Timestamp > sth.TreeHeadData.Timestamp:
+				monotonicErr = mtr.VerifySTHsMonotonic(sth, storedSTH)
+			}
+			if monotonicErr == nil {
+				continue
+			}
+			pom, err := mtr.CreateNonMonotonicSTHPOM(storedCT, ctObject)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create PoM for rollback: %w", err)
+			}
+			return &MisbehavingLogError{LogID: sth.LogID, Reason: monotonicErr.Error(), POM: pom}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Check whether the STHs within two STH CTObjects have the same tree head, regardless of their signatures
+func equalTreeHeads(sthCT1 *mtr.CTObject, sthCT2 *mtr.CTObject) (bool, error) {
+	if bytes.Equal(sthCT1.Digest, sthCT2.Digest) {
+		return true, nil
+	}
+	sth1, err := sthCT1.DeconstructSTH()
+	if err != nil {
+		return false, err
+	}
+	sth2, err := sthCT2.DeconstructSTH()
+	if err != nil {
+		return false, err
+	}
+	return sth1.LogID == sth2.LogID && sth1.TreeHeadData == sth2.TreeHeadData, nil
+}
+
+// Check whether the STHs within two STH CTObjects were signed for the same timestamp
+func equalTimestamps(sthCT1 *mtr.CTObject, sthCT2 *mtr.CTObject) (bool, error) {
+	sth1, err := sthCT1.DeconstructSTH()
+	if err != nil {
+		return false, err
+	}
+	sth2, err := sthCT2.DeconstructSTH()
+	if err != nil {
+		return false, err
+	}
+	return sth1.TreeHeadData.Timestamp == sth2.TreeHeadData.Timestamp, nil
+}
+
+// Get the STH CTObject of the STH within an STH or STH_POC CTObject
+func baseSTHCTObject(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
+	if ctObject.TypeID == mtr.STHTypeID {
+		return ctObject, nil
+	}
+	sth, err := ctObject.DeconstructSTH()
+	if err != nil {
+		return nil, err
+	}
+	return mtr.ConstructCTObject(sth)
+}
+
+// Verify the STHs within the ConflictingSTHPOM are both signed by the log and differ for the same timestamp
+func (m *Monitor) verifyConflictingSTHPOM(ctObject *mtr.CTObject) error {
+	pom, err := ctObject.DeconstructConflictingSTHPOM()
+	if err != nil {
+		return fmt.Errorf("failed to verify ConflictingSTHPOM: %w", err)
+	}
+	if pom.STH1.LogID != pom.STH2.LogID || pom.STH1.LogID != ctObject.Subject {
+		return fmt.Errorf("ConflictingSTHPOM STHs aren't both from Logger %s", ctObject.Subject)
+	}
+	if pom.STH1.TreeHeadData.Timestamp != pom.STH2.TreeHeadData.Timestamp {
+		return fmt.Errorf("ConflictingSTHPOM STHs have different timestamps (%d and %d)", pom.STH1.TreeHeadData.Timestamp, pom.STH2.TreeHeadData.Timestamp)
+	}
+	if pom.STH1.TreeHeadData == pom.STH2.TreeHeadData {
+		return fmt.Errorf("ConflictingSTHPOM STHs don't conflict")
+	}
+	logKey, err := m.getLogKey(pom.STH1.LogID)
+	if err != nil {
+		return fmt.Errorf("failed to verify ConflictingSTHPOM: %w", err)
+	}
+	for _, sth := range []*mtr.SignedTreeHeadData{&pom.STH1, &pom.STH2} {
+		if err := mtr.VerifySTHSignature(sth, logKey); err != nil {
+			return fmt.Errorf("failed to verify ConflictingSTHPOM: %w", err)
+		}
+	}
+	return nil
+}
+
+// Verify the STHs within the NonMonotonicSTHPOM are both signed by the log and that its tree doesn't grow monotonically between them
+func (m *Monitor) verifyNonMonotonicSTHPOM(ctObject *mtr.CTObject) error {
+	pom, err := ctObject.DeconstructNonMonotonicSTHPOM()
+	if err != nil {
+		return fmt.Errorf("failed to verify NonMonotonicSTHPOM: %w", err)
+	}
+	if pom.STH1.LogID != pom.STH2.LogID || pom.STH1.LogID != ctObject.Subject {
+		return fmt.Errorf("NonMonotonicSTHPOM STHs aren't both from Logger %s", ctObject.Subject)
+	}
+	if pom.STH1.TreeHeadData.Timestamp >= pom.STH2.TreeHeadData.Timestamp {
+		return fmt.Errorf("NonMonotonicSTHPOM STH1 timestamp (%d) isn't before STH2 timestamp (%d)", pom.STH1.TreeHeadData.Timestamp, pom.STH2.TreeHeadData.Timestamp)
+	}
+	if mtr.VerifySTHsMonotonic(&pom.STH1, &pom.STH2) == nil {
+		return fmt.Errorf("NonMonotonicSTHPOM STHs are monotonic")
+	}
+	logKey, err := m.getLogKey(pom.STH1.LogID)
+	if err != nil {
+		return fmt.Errorf("failed to verify NonMonotonicSTHPOM: %w", err)
+	}
+	for _, sth := range []*mtr.SignedTreeHeadData{&pom.STH1, &pom.STH2} {
+		if err := mtr.VerifySTHSignature(sth, logKey); err != nil {
+			return fmt.Errorf("failed to verify NonMonotonicSTHPOM: %w", err)
+		}
+	}
+	return nil
+}
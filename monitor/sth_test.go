@@ -0,0 +1,195 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/gossip"
+)
+
+// Replace the testLogID key with the monitor key so STHs created by the test verify
+func mustUseMonitorKeyForLog(t *testing.T, m *Monitor) {
+	t.Helper()
+	logClient, err := mtr.NewLogClient(&entitylist.LogInfo{LogID: testLogID, Key: testMonitorKey, URL: "http://localhost"})
+	if err != nil {
+		t.Fatalf("failed to create LogClient: %v", err)
+	}
+	m.LogIDMap[testLogID] = logClient
+}
+
+func TestAddEntryDetectsSplitView(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	mustUseMonitorKeyForLog(t, monitor)
+	sth := mustCreateSTH(t, monitor, 1, 1000, []byte("root"))
+	if err := monitor.AddEntry(sth); err != nil {
+		t.Fatalf("failed to add STH: %v", err)
+	}
+	if err := monitor.AddEntry(sth); err != nil {
+		t.Fatalf("failed to add the same STH again: %v", err)
+	}
+	if err := monitor.AddEntry(mustCreateSTH(t, monitor, 1, 1000, []byte("root"))); err != nil {
+		t.Fatalf("failed to add the same tree head signed again: %v", err)
+	}
+
+	// A peer gossips a different STH from the log for the same timestamp
+	forkedSTH := mustCreateSTH(t, monitor, 1, 1000, []byte("forked root"))
+	err = monitor.AddEntry(forkedSTH)
+	var misbehavingLogErr *MisbehavingLogError
+	if !errors.As(err, &misbehavingLogErr) {
+		t.Fatalf("conflicting STH didn't return MisbehavingLogError: %v", err)
+	}
+	pom := misbehavingLogErr.POM
+	if pom.TypeID != mtr.ConflictingSTHPOMTypeID || pom.Subject != testLogID || pom.Timestamp != 1000 {
+		t.Fatalf("split view produced unexpected PoM: %v", pom)
+	}
+	if err := monitor.VerifyCTObject(pom); err != nil {
+		t.Fatalf("ConflictingSTHPOM failed verification: %v", err)
+	}
+	if monitor.GetEntry(pom.Identifier()) == nil {
+		t.Fatalf("ConflictingSTHPOM wasn't stored")
+	}
+	if status := monitor.Gossiper.Status(pom.Identifier()); status != gossip.StatusPending {
+		t.Fatalf("ConflictingSTHPOM gossip status is %s, expected it to be pending", status)
+	}
+	if storedSTH, _ := monitor.GetCorrespondingSTHEntry(sth); storedSTH == nil || bytes.Equal(storedSTH.Digest, forkedSTH.Digest) {
+		t.Fatalf("conflicting STH replaced the stored STH")
+	}
+
+	// The STH within an STH_POC is compared with stored STHs of the same timestamp
+	_, sthPOC := mustCreateSTHWithPOC(t, monitor, nil)
+	if err := monitor.AddEntry(mustCreateSTH(t, monitor, 4, 2000, []byte("other root"))); err != nil {
+		t.Fatalf("failed to add STH: %v", err)
+	}
+	if err := monitor.AddEntry(sthPOC); !errors.As(err, &misbehavingLogErr) {
+		t.Fatalf("STH_POC conflicting with stored STH didn't return MisbehavingLogError: %v", err)
+	}
+}
+
+func TestVerifyCTObjectRejectsForgedConflictingSTHPOM(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	mustUseMonitorKeyForLog(t, monitor)
+
+	// STHs of different timestamps aren't a split view
+	pom, err := mtr.CreateConflictingSTHPOM(mustCreateSTH(t, monitor, 10, 1000, nil), mustCreateSTH(t, monitor, 11, 1001, nil))
+	if err != nil {
+		t.Fatalf("failed to create ConflictingSTHPOM: %v", err)
+	}
+	if err := monitor.VerifyCTObject(pom); err == nil {
+		t.Fatalf("ConflictingSTHPOM of different timestamps passed verification")
+	}
+
+	// STHs not signed by the log don't prove a split view
+	sth1, sth2 := mustCreateSTH(t, monitor, 10, 1000, nil), mustCreateSTH(t, monitor, 11, 1000, nil)
+	delete(monitor.LogIDMap, testLogID)
+	pom, err = mtr.CreateConflictingSTHPOM(sth1, sth2)
+	if err != nil {
+		t.Fatalf("failed to create ConflictingSTHPOM: %v", err)
+	}
+	if err := monitor.VerifyCTObject(pom); err == nil {
+		t.Fatalf("ConflictingSTHPOM of forged STHs passed verification")
+	}
+}
+
+func TestAddEntryDetectsRollback(t *testing.T) {
+	tests := []struct {
+		name 		string
+		treeSize 	uint64
+		timestamp 	uint64
+		rootHash 	[]byte
+		rollback 	bool
+	}{
+		{"growing tree", 20, 2000, []byte("new root"), false},
+		{"same tree", 10, 2000, []byte("root"), false},
+		{"decreased tree size", 5, 2000, []byte("new root"), true},
+		{"timestamp went backwards", 20, 500, []byte("new root"), true},
+		{"changed root hash", 10, 2000, []byte("new root"), true},
+	}
+	for _, test := range tests {
+		monitor, err := mustGetMonitor(t)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		mustUseMonitorKeyForLog(t, monitor)
+		if err := monitor.AddEntry(mustCreateSTH(t, monitor, 10, 1000, []byte("root"))); err != nil {
+			t.Fatalf("failed to add STH: %v", err)
+		}
+		sth := mustCreateSTH(t, monitor, test.treeSize, test.timestamp, test.rootHash)
+		err = monitor.AddEntry(sth)
+		if !test.rollback {
+			if err != nil {
+				t.Fatalf("%s: failed to add STH: %v", test.name, err)
+			}
+			continue
+		}
+
+		var misbehavingLogErr *MisbehavingLogError
+		if !errors.As(err, &misbehavingLogErr) {
+			t.Fatalf("%s: didn't return MisbehavingLogError: %v", test.name, err)
+		}
+		pom := misbehavingLogErr.POM
+		if pom.TypeID != mtr.NonMonotonicSTHPOMTypeID {
+			t.Fatalf("%s: produced %s PoM, expected %s", test.name, pom.TypeID, mtr.NonMonotonicSTHPOMTypeID)
+		}
+		if err := monitor.VerifyCTObject(pom); err != nil {
+			t.Fatalf("%s: NonMonotonicSTHPOM failed verification: %v", test.name, err)
+		}
+		if monitor.GetEntry(pom.Identifier()) == nil || monitor.GetEntry(sth.Identifier()) != nil {
+			t.Fatalf("%s: NonMonotonicSTHPOM should be stored instead of the STH", test.name)
+		}
+		if status := monitor.Gossiper.Status(pom.Identifier()); status != gossip.StatusPending {
+			t.Fatalf("%s: NonMonotonicSTHPOM gossip status is %s, expected it to be pending", test.name, status)
+		}
+	}
+}
+
+func TestAuditIgnoresResignedTreeHeadsAndRevData(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	mustUseMonitorKeyForLog(t, monitor)
+
+	// The same tree head signed again is audited as OK
+	if err := monitor.AddEntry(mustCreateSTH(t, monitor, 10, 1000, []byte("root"))); err != nil {
+		t.Fatalf("failed to add STH: %v", err)
+	}
+	auditResp, err := monitor.Audit(mustCreateSTH(t, monitor, 10, 1000, []byte("root")))
+	if err != nil || auditResp.TypeID != mtr.STHAuditOKTypeID {
+		t.Fatalf("re-signed STH wasn't audited as OK: %v %v", auditResp, err)
+	}
+
+	// The RevData of an SRD isn't signed by the CA, so a different CRVDelta doesn't make the SRDs conflict
+	srdCT := mustCreateSRDWithRevData(t, monitor, 1000, []byte("crv"))
+	if err := monitor.AddEntry(srdCT); err != nil {
+		t.Fatalf("failed to add SRDWithRevData: %v", err)
+	}
+	srdWithRevData := &mtr.SRDWithRevData{}
+	if err := json.Unmarshal(srdCT.Blob, srdWithRevData); err != nil {
+		t.Fatalf("failed to unmarshal SRDWithRevData: %v", err)
+	}
+	srdWithRevData.RevData.CRVDelta = []byte("relayed delta")
+	relayedCT, err := mtr.ConstructCTObject(srdWithRevData)
+	if err != nil {
+		t.Fatalf("failed to construct SRDWithRevData CTObject: %v", err)
+	}
+	auditResp, err = monitor.Audit(relayedCT)
+	if err != nil || auditResp.TypeID != mtr.SRDAuditOKTypeID {
+		t.Fatalf("SRD with different RevData wasn't audited as OK: %v %v", auditResp, err)
+	}
+
+	// A different RevocationDigest for the same timestamp conflicts
+	auditResp, err = monitor.Audit(mustCreateSRDWithRevData(t, monitor, 1000, []byte("forked crv")))
+	if err != nil || auditResp.TypeID != mtr.ConflictingSRDPOMTypeID {
+		t.Fatalf("conflicting SRD wasn't audited as ConflictingSRDPOM: %v %v", auditResp, err)
+	}
+}
@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/bits"
+	"sync"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency-go"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+const (
+	// Entries requested per get-entries request from logs that don't state a MaxGetEntries in the log list
+	defaultGetEntriesBatchSize = 256
+
+	// Number of get-entries requests a Tailer has in flight for a log at once
+	defaultTailerParallelism = 4
+)
+
+// Called with consecutive entries of the log starting at leaf index start, in the order of the log
+type EntryProcessor func(logID string, start uint64, entries []ct.LeafEntry) error
+
+// Tailer walks the entries of each log from the monitor's checkpoint up to a given tree size
+// Logs without a checkpoint are walked from their first entry, or from the tree size they are first tailed to when the
+// Monitor's TailFromLatestSTH is set. Real logs hold billions of entries, so walking them from the first entry takes days
+// Batches are fetched in parallel but processed in order, and the checkpoint is advanced after each processed batch
+// The Merkle root of the entries is checked against every stored STH of the log as the entries are processed
+type Tailer struct {
+	m *Monitor
+	process EntryProcessor
+	parallelism int
+	batchSize uint64	// Overrides the log's batch size when non-zero
+}
+
+// Create a new Tailer that passes every new entry to the Monitor's ProcessEntries
+func NewTailer(m *Monitor) *Tailer {
+	return &Tailer{m: m, process: m.ProcessEntries, parallelism: defaultTailerParallelism}
+}
+
+// Fetch and process the entries of the log from its checkpoint up to treeSize
+func (t *Tailer) TailLog(ctx context.Context, logID string, treeSize uint64) error {
+	logClient, ok := t.m.LogIDMap[logID]
+	if !ok {
+		return fmt.Errorf("LogID (%s) not found in Monitor's LogIDMap", logID)
+	}
+	batchSize := t.getBatchSize(logClient)
+	compactRange := t.m.Checkpoints.Get(logID)
+	sthCTs, err := t.m.getSTHEntriesByTreeSize(logID)
+	if err != nil {
+		return fmt.Errorf("failed to tail Logger %s: %w", logID, err)
+	}
+	if compactRange.Size == 0 && t.m.TailFromLatestSTH && treeSize > 0 {
+		compactRange, err = t.skipToSTH(ctx, logClient, sthCTs[treeSize])
+		if err != nil {
+			return fmt.Errorf("failed to tail Logger %s: %w", logID, err)
+		}
+	}
+	position := compactRange.Size
+	if position < treeSize {
+		glog.V(1).Infof("tailing Logger %s from %d to %d", logID, position, treeSize)
+	}
+	for position < treeSize {
+		// Fetch the next window of batches in parallel
+		var starts []uint64
+		for start := position; start < treeSize && len(starts) < t.parallelism; start += batchSize {
+			starts = append(starts, start)
+		}
+		batches := make([][]ct.LeafEntry, len(starts))
+		errs := make([]error, len(starts))
+		var wg sync.WaitGroup
+		for i, start := range starts {
+			end := start + batchSize
+			if end > treeSize {
+				end = treeSize
+			}
+			wg.Add(1)
+			go func(i int, start, end uint64) {
+				defer wg.Done()
+				batches[i], errs[i] = fetchEntries(ctx, logClient, start, end, batchSize)
+			}(i, start, end)
+		}
+		wg.Wait()
+
+		// Process the batches in order, stopping at the first batch that couldn't be fetched
+		for i, start := range starts {
+			if errs[i] != nil {
+				return fmt.Errorf("failed to tail Logger %s: %w", logID, errs[i])
+			}
+
+			// Check the entries hash to the root of every stored STH whose tree ends within the batch
+			for _, entry := range batches[i] {
+				compactRange.AppendLeaf(entry.LeafInput)
+				sthCT, ok := sthCTs[compactRange.Size]
+				if !ok {
+					continue
+				}
+				pom, err := t.m.VerifyEntriesRoot(sthCT, compactRange)
+				if err != nil {
+					return fmt.Errorf("failed to tail Logger %s: %w", logID, err)
+				}
+				// The PoM is only kept by this monitor since others can't verify it. Retries find it already stored
+				if pom != nil {
+					if err := t.m.AddEntry(pom); err != nil {
+						return fmt.Errorf("failed to tail Logger %s: %w", logID, err)
+					}
+					return fmt.Errorf("entries of Logger %s don't match the root hash of its STH of tree size %d", logID, compactRange.Size)
+				}
+			}
+
+			if err := t.process(logID, start, batches[i]); err != nil {
+				return fmt.Errorf("failed to process entries of Logger %s from %d: %w", logID, start, err)
+			}
+			position = compactRange.Size
+			if err := t.m.Checkpoints.Set(logID, compactRange); err != nil {
+				return fmt.Errorf("failed to tail Logger %s: %w", logID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Checkpoint the log at the tree size of the STH without processing the entries before it
+// The CompactRange of the skipped entries is built from the inclusion proof of the last of them, so the root of
+// later entries can still be checked. Every sibling on the path of the last entry is a subtree to its left
+func (t *Tailer) skipToSTH(ctx context.Context, logClient *mtr.LogClient, sthCT *mtr.CTObject) (*mtr.CompactRange, error) {
+	if sthCT == nil {
+		return nil, fmt.Errorf("no STH stored to start tailing from")
+	}
+	sth, err := sthCT.DeconstructSTH()
+	if err != nil {
+		return nil, err
+	}
+	treeSize := sth.TreeHeadData.TreeSize
+	poi, leafInput, err := logClient.GetEntryAndProof(ctx, treeSize - 1, treeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inclusion proof of entry %d to start tailing from: %w", treeSize - 1, err)
+	}
+	if len(poi.InclusionPath) != bits.OnesCount64(treeSize - 1) {
+		return nil, fmt.Errorf("inclusion proof of entry %d has %d hashes, expected %d", treeSize - 1, len(poi.InclusionPath), bits.OnesCount64(treeSize - 1))
+	}
+	compactRange := &mtr.CompactRange{Size: treeSize - 1}
+	for i := len(poi.InclusionPath) - 1; i >= 0; i-- {
+		compactRange.Hashes = append(compactRange.Hashes, poi.InclusionPath[i])
+	}
+	compactRange.AppendLeaf(leafInput)
+	if !bytes.Equal(compactRange.Root(), sth.TreeHeadData.SHA256RootHash[:]) {
+		return nil, fmt.Errorf("inclusion proof of entry %d doesn't lead to the root hash of the STH of tree size %d", treeSize - 1, treeSize)
+	}
+	if err := t.m.Checkpoints.Set(sth.LogID, compactRange); err != nil {
+		return nil, err
+	}
+	glog.Infof("skipped the first %d entries of Logger %s", treeSize, sth.LogID)
+	return compactRange, nil
+}
+
+// Process consecutive entries of the log starting at leaf index start as they are tailed
+func (m *Monitor) ProcessEntries(logID string, start uint64, entries []ct.LeafEntry) error {
+	return m.matchWatchedDomains(logID, start, entries)
+}
+
+// Get the number of entries to request from the log at once
+func (t *Tailer) getBatchSize(logClient *mtr.LogClient) uint64 {
+	if t.batchSize > 0 {
+		return t.batchSize
+	}
+	if logClient.LogInfo.MaxGetEntries > 0 {
+		return logClient.LogInfo.MaxGetEntries
+	}
+	return defaultGetEntriesBatchSize
+}
+
+// Fetch the entries of the log from index start up to but not including end
+// Logs may serve fewer entries than requested, so requests are repeated until the range is complete
+func fetchEntries(ctx context.Context, logClient *mtr.LogClient, start, end, batchSize uint64) ([]ct.LeafEntry, error) {
+	var entries []ct.LeafEntry
+	for next := start; next < end; next = start + uint64(len(entries)) {
+		last := next + batchSize - 1
+		if last >= end {
+			last = end - 1
+		}
+		batch, err := logClient.GetEntries(ctx, next, last)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, batch...)
+	}
+	return entries, nil
+}
@@ -0,0 +1,185 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/gossip"
+	"github.com/n-ct/ct-monitor/storage"
+)
+
+// Create entries that only need to be distinguishable, not parseable
+func createTestEntries(numEntries int) []ct.LeafEntry {
+	entries := make([]ct.LeafEntry, numEntries)
+	for i := range entries {
+		entries[i].LeafInput = []byte(fmt.Sprintf("leaf%d", i))
+	}
+	return entries
+}
+
+// Create a Tailer that records the leaf index of every processed entry
+func createRecordingTailer(m *Monitor, batchSize uint64, processed *[]uint64) *Tailer {
+	var lock sync.Mutex
+	tailer := NewTailer(m)
+	tailer.batchSize = batchSize
+	tailer.process = func(logID string, start uint64, entries []ct.LeafEntry) error {
+		lock.Lock()
+		defer lock.Unlock()
+		for i, entry := range entries {
+			if string(entry.LeafInput) != fmt.Sprintf("leaf%d", start + uint64(i)) {
+				return fmt.Errorf("entry %d has leaf input %s", start + uint64(i), entry.LeafInput)
+			}
+			*processed = append(*processed, start + uint64(i))
+		}
+		return nil
+	}
+	return tailer
+}
+
+func TestTailLogResumesFromCheckpoint(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoints")
+	monitor.Checkpoints, err = storage.NewCheckpoints(checkpointPath)
+	if err != nil {
+		t.Fatalf("failed to create checkpoints: %v", err)
+	}
+	// The log serves fewer entries than the Tailer asks for
+	mustUseEntriesServer(t, monitor, createTestEntries(50), 3)
+
+	var processed []uint64
+	tailer := createRecordingTailer(monitor, 4, &processed)
+	ctx := context.Background()
+	if err := tailer.TailLog(ctx, testLogID, 21); err != nil {
+		t.Fatalf("failed to tail log: %v", err)
+	}
+	if err := tailer.TailLog(ctx, testLogID, 21); err != nil {
+		t.Fatalf("failed to tail log without new entries: %v", err)
+	}
+
+	// A restarted monitor continues where the previous one stopped
+	monitor.Checkpoints, err = storage.NewCheckpoints(checkpointPath)
+	if err != nil {
+		t.Fatalf("failed to reload checkpoints: %v", err)
+	}
+	if err := tailer.TailLog(ctx, testLogID, 50); err != nil {
+		t.Fatalf("failed to resume tailing log: %v", err)
+	}
+	if len(processed) != 50 {
+		t.Fatalf("processed %d entries, expected each of the 50 entries once", len(processed))
+	}
+	for i, index := range processed {
+		if index != uint64(i) {
+			t.Fatalf("entry %d processed at position %d. Entries must be processed in order", index, i)
+		}
+	}
+	if position := monitor.Checkpoints.Get(testLogID).Size; position != 50 {
+		t.Fatalf("checkpoint is %d after tailing to 50", position)
+	}
+}
+
+func TestTailLogStopsAtFailedBatch(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// The log only has 10 of the 20 entries the Tailer is asked for
+	mustUseEntriesServer(t, monitor, createTestEntries(10), 10)
+
+	var processed []uint64
+	tailer := createRecordingTailer(monitor, 4, &processed)
+	if err := tailer.TailLog(context.Background(), testLogID, 20); err == nil {
+		t.Fatalf("tailing past the end of the log succeeded")
+	}
+	if position := monitor.Checkpoints.Get(testLogID).Size; position != 8 || len(processed) != 8 {
+		t.Fatalf("checkpoint is %d after processing %d entries, expected both to stop at the last complete batch (8)", position, len(processed))
+	}
+}
+
+func TestTailLogVerifiesEntriesRoot(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	entries := createTestEntries(30)
+	mustUseEntriesServer(t, monitor, entries, 30)
+
+	// The STH at tree size 10 matches the entries but the STH at tree size 20 doesn't
+	var compactRange mtr.CompactRange
+	for _, entry := range entries[:10] {
+		compactRange.AppendLeaf(entry.LeafInput)
+	}
+	monitor.AddEntry(mustCreateSTH(t, monitor, 10, 1000, compactRange.Root()))
+	monitor.AddEntry(mustCreateSTH(t, monitor, 20, 2000, compactRange.Root()))
+
+	var processed []uint64
+	tailer := createRecordingTailer(monitor, 4, &processed)
+	if err := tailer.TailLog(context.Background(), testLogID, 30); err == nil {
+		t.Fatalf("tailing entries that don't match the STH root succeeded")
+	}
+	if position := monitor.Checkpoints.Get(testLogID).Size; position != 16 {
+		t.Fatalf("checkpoint is %d, expected it to stop before the batch with the mismatched root (16)", position)
+	}
+
+	// Retrying keeps the single PoM, which other monitors can't verify and so isn't gossiped
+	if err := tailer.TailLog(context.Background(), testLogID, 30); err == nil {
+		t.Fatalf("retrying to tail entries that don't match the STH root succeeded")
+	}
+	poms := monitor.Storage.GetEntries(mtr.MismatchedRootPOMTypeID, testLogID)
+	if len(poms) != 1 {
+		t.Fatalf("got %d MismatchedRootPOMs, expected 1", len(poms))
+	}
+	if status := monitor.Gossiper.Status(poms[0].Identifier()); status != gossip.StatusUnknown {
+		t.Fatalf("MismatchedRootPOM gossip status is %q, expected it not to be gossiped", status)
+	}
+	if err := monitor.VerifyCTObject(poms[0]); err == nil {
+		t.Fatalf("MismatchedRootPOM from another monitor passed verification")
+	}
+}
+
+func TestTailLogFromLatestSTH(t *testing.T) {
+	monitor, err := mustGetMonitor(t)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	monitor.TailFromLatestSTH = true
+	log := mustUseFakeLog(t, monitor, 11)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHTypeID)
+
+	var processed []uint64
+	tailer := NewTailer(monitor)
+	tailer.process = func(logID string, start uint64, entries []ct.LeafEntry) error {
+		for i := range entries {
+			processed = append(processed, start + uint64(i))
+		}
+		return nil
+	}
+	ctx := context.Background()
+	if err := tailer.TailLog(ctx, log.LogID(), 11); err != nil {
+		t.Fatalf("failed to tail log: %v", err)
+	}
+	if position := monitor.Checkpoints.Get(log.LogID()).Size; position != 11 || len(processed) != 0 {
+		t.Fatalf("checkpoint is %d after processing %d entries, expected the 11 entries before the first STH to be skipped", position, len(processed))
+	}
+
+	// Entries added after the first STH are processed and checked against the root of the next STH
+	log.AddEntries(6)
+	mustFetchSTH(t, monitor, log.LogID(), mtr.STHPOCTypeID)
+	if err := tailer.TailLog(ctx, log.LogID(), 17); err != nil {
+		t.Fatalf("failed to tail log: %v", err)
+	}
+	if len(processed) != 6 || processed[0] != 11 || processed[5] != 16 {
+		t.Fatalf("processed entries %v, expected entries 11 to 16", processed)
+	}
+	if poms := monitor.Storage.GetEntries(mtr.MismatchedRootPOMTypeID, log.LogID()); len(poms) != 0 {
+		t.Fatalf("entries after the skipped entries produced %d MismatchedRootPOMs", len(poms))
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+// Checkpoints records how many entries of each log the monitor has processed so tailing resumes after restarts
+// The checkpoint of a log is the CompactRange of its processed entries, so the Merkle root of the entries can keep being checked
+// When created with a path, every update is written to the file before Set returns
+type Checkpoints struct {
+	mu sync.Mutex
+	path string
+	ranges map[string]*mtr.CompactRange	// CompactRange of the processed entries, keyed by LogID
+}
+
+// Create Checkpoints persisted to the file at path, loading the file if it exists
+// An empty path creates Checkpoints kept only in memory
+func NewCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{path: path, ranges: make(map[string]*mtr.CompactRange)}
+	if path == "" {
+		return c, nil
+	}
+	byteData, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening checkpoint file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(byteData, &c.ranges); err != nil {
+		return nil, fmt.Errorf("error loading checkpoint file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Get a copy of the CompactRange of the processed entries of the log. Its Size is the next leaf index to process
+// Logs without a checkpoint start with an empty CompactRange
+func (c *Checkpoints) Get(logID string) *mtr.CompactRange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	compactRange, ok := c.ranges[logID]
+	if !ok {
+		return &mtr.CompactRange{}
+	}
+	return compactRange.Copy()
+}
+
+// Record that every entry of the log covered by the CompactRange has been processed
+func (c *Checkpoints) Set(logID string, compactRange *mtr.CompactRange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.ranges[logID]
+	c.ranges[logID] = compactRange.Copy()
+	if c.path == "" {
+		return nil
+	}
+	byteData, err := json.Marshal(c.ranges)
+	if err == nil {
+		err = utils.WriteFileAtomic(c.path, byteData)
+	}
+	if err != nil {
+		if ok {
+			c.ranges[logID] = prev
+		} else {
+			delete(c.ranges, logID)
+		}
+		return fmt.Errorf("failed to save checkpoint of Logger %s: %w", logID, err)
+	}
+	return nil
+}
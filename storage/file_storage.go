@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+// FileStorage persists CTObjects to an append-only file with one JSON encoded CTObject per line
+// The file is replayed into a MemoryStorage on open, so reads never touch the disk.
+// Later lines replace earlier lines with the same identifier, matching AddEntry semantics.
+// Writes are serialized so the order of lines in the file matches the order entries were added in memory
+type FileStorage struct {
+	*MemoryStorage
+	file *os.File
+	writeLock sync.Mutex
+}
+
+// Open the append-only file at path, creating it if it doesn't exist, and load its entries
+func NewFileStorage(path string) (*FileStorage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening storage file %s: %w", path, err)
+	}
+	memStorage := NewMemoryStorage()
+	validLength, err := loadEntries(file, memStorage)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error loading storage file %s: %w", path, err)
+	}
+
+	// Drop a partially written last entry so new entries start on a fresh line
+	if err := file.Truncate(validLength); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error truncating storage file %s: %w", path, err)
+	}
+	if _, err := file.Seek(validLength, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error seeking storage file %s: %w", path, err)
+	}
+	return &FileStorage{MemoryStorage: memStorage, file: file}, nil
+}
+
+// Read every complete entry in the file into memStorage and return the length of the complete entries
+func loadEntries(file *os.File, memStorage *MemoryStorage) (int64, error) {
+	reader := bufio.NewReader(file)
+	var validLength int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				glog.Warningf("dropping partially written entry at end of storage file %s", file.Name())
+			}
+			return validLength, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var ctObject mtr.CTObject
+		if err := json.Unmarshal(line, &ctObject); err != nil {
+			return 0, fmt.Errorf("corrupt entry at offset %d: %w", validLength, err)
+		}
+		memStorage.AddEntry(&ctObject)
+		validLength += int64(len(line))
+	}
+}
+
+// Append the entry to the file before adding it to memory
+func (s *FileStorage) AddEntry(ctObject *mtr.CTObject) error {
+	line, err := json.Marshal(ctObject)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s ctobject for storage: %w", ctObject.TypeID, err)
+	}
+	line = append(line, '\n')
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write %s ctobject to storage file: %w", ctObject.TypeID, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync storage file: %w", err)
+	}
+	return s.MemoryStorage.AddEntry(ctObject)
+}
+
+// Close the underlying file
+func (s *FileStorage) Close() error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+// CRV the monitor reconstructed for a CA along with the SRD whose CRVHash it matches
+type RevocationVector struct {
+	SRD mtr.SignedRevocationDigest
+	CRV []byte	// Compressed CRV
+}
+
+// RevocationVectors holds the latest reconstructed CRV of each CA and revocation type so deltas keep being applied after restarts
+// When created with a path, every update is written to the file before Set returns
+type RevocationVectors struct {
+	mu sync.Mutex
+	path string
+	vectors map[string]map[string]*RevocationVector	// Keyed by CAID then RevocationType
+}
+
+// Create RevocationVectors persisted to the file at path, loading the file if it exists
+// An empty path creates RevocationVectors kept only in memory
+func NewRevocationVectors(path string) (*RevocationVectors, error) {
+	r := &RevocationVectors{path: path, vectors: make(map[string]map[string]*RevocationVector)}
+	if path == "" {
+		return r, nil
+	}
+	byteData, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening revocation vector file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(byteData, &r.vectors); err != nil {
+		return nil, fmt.Errorf("error loading revocation vector file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Get the latest RevocationVector of the CA for the revocation type. Returns nil if there is none
+func (r *RevocationVectors) Get(caID string, revType string) *RevocationVector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	vector, ok := r.vectors[caID][revType]
+	if !ok {
+		return nil
+	}
+	vectorCopy := *vector
+	return &vectorCopy
+}
+
+// Replace the RevocationVector of the CA for the revocation type
+func (r *RevocationVectors) Set(caID string, revType string, vector *RevocationVector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.vectors[caID]; !ok {
+		r.vectors[caID] = make(map[string]*RevocationVector)
+	}
+	prev, ok := r.vectors[caID][revType]
+	vectorCopy := *vector
+	r.vectors[caID][revType] = &vectorCopy
+	if r.path == "" {
+		return nil
+	}
+	byteData, err := json.Marshal(r.vectors)
+	if err == nil {
+		err = utils.WriteFileAtomic(r.path, byteData)
+	}
+	if err != nil {
+		if ok {
+			r.vectors[caID][revType] = prev
+		} else {
+			delete(r.vectors[caID], revType)
+		}
+		return fmt.Errorf("failed to save revocation vector of CA %s: %w", caID, err)
+	}
+	return nil
+}
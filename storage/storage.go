@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+// Storage holds the CTObjects of a Monitor keyed by their ObjectIdentifier
+// Implementations must be safe for concurrent use since handlers run concurrently
+type Storage interface {
+	// AddEntry stores the CTObject, replacing any stored CTObject with the same identifier
+	AddEntry(ctObject *mtr.CTObject) error
+	// GetEntry returns the CTObject with the given identifier or nil if there is none
+	GetEntry(identifier mtr.ObjectIdentifier) *mtr.CTObject
+	// GetEntries returns every stored CTObject whose identifier starts with first and second, ordered by timestamp
+	GetEntries(first string, second string) []*mtr.CTObject
+	// FindEntries returns every stored CTObject matching the query, ordered by timestamp and then by identifier
+	FindEntries(query *Query) []*mtr.CTObject
+	// Close releases any resources held by the Storage
+	Close() error
+}
+
+// Query selects stored CTObjects by the fields of their ObjectIdentifier. Empty fields match every CTObject
+type Query struct {
+	TypeID 			string
+	Signer 			string
+	Subject 		string
+	StartTimestamp 	uint64	// Earliest timestamp matched
+	EndTimestamp 	uint64	// Latest timestamp matched. 0 matches every timestamp from StartTimestamp on
+	Version 		string	// Major and minor version in the form of VersionData.String
+}
+
+// Check whether the CTObject matches every field of the Query
+func (q *Query) Matches(ctObject *mtr.CTObject) bool {
+	switch {
+	case q.TypeID != "" && ctObject.TypeID != q.TypeID:
+		return false
+	case q.Signer != "" && ctObject.Signer != q.Signer:
+		return false
+	case q.Subject != "" && ctObject.Subject != q.Subject:
+		return false
+	case ctObject.Timestamp < q.StartTimestamp:
+		return false
+	case q.EndTimestamp != 0 && ctObject.Timestamp > q.EndTimestamp:
+		return false
+	case q.Version != "" && ctObject.Version.String() != q.Version:
+		return false
+	}
+	return true
+}
+
+// MemoryStorage keeps CTObjects in a four-level nested map of the identifier fields
+// Nothing is persisted, so all entries are lost once the Monitor stops.
+// Reads share a read lock so concurrent audits don't block each other, writes take the write lock
+type MemoryStorage struct {
+	ctObjectMap map[string]map[string]map[uint64]map[string] *mtr.CTObject
+	mu sync.RWMutex
+}
+
+// Create a new empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	ctObjectMap := make(map[string]map[string]map[uint64]map[string] *mtr.CTObject)
+	return &MemoryStorage{ctObjectMap: ctObjectMap}
+}
+
+// Add a new entry to the map using the data identifier as keys
+func (s *MemoryStorage) AddEntry(ctObject *mtr.CTObject) error {
+	identifier := ctObject.Identifier()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ctObjectMap[identifier.First]; !ok {
+		s.ctObjectMap[identifier.First] = make(map[string]map[uint64]map[string] *mtr.CTObject)
+	}
+	if _, ok := s.ctObjectMap[identifier.First][identifier.Second]; !ok {
+		s.ctObjectMap[identifier.First][identifier.Second] = make(map[uint64]map[string] *mtr.CTObject)
+	}
+	if _, ok := s.ctObjectMap[identifier.First][identifier.Second][identifier.Third]; !ok {
+		s.ctObjectMap[identifier.First][identifier.Second][identifier.Third] = make(map[string] *mtr.CTObject)
+	}
+	s.ctObjectMap[identifier.First][identifier.Second][identifier.Third][identifier.Fourth] = ctObject
+	return nil
+}
+
+// Get the entry with the given identifier
+func (s *MemoryStorage) GetEntry(identifier mtr.ObjectIdentifier) *mtr.CTObject {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ctObjectMap[identifier.First][identifier.Second][identifier.Third][identifier.Fourth]
+}
+
+// Get all entries under first and second ordered by timestamp
+func (s *MemoryStorage) GetEntries(first string, second string) []*mtr.CTObject {
+	var entries []*mtr.CTObject
+	s.mu.RLock()
+	for _, versionMap := range s.ctObjectMap[first][second] {
+		for _, ctObject := range versionMap {
+			entries = append(entries, ctObject)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Timestamp != entries[j].Timestamp {
+			return entries[i].Timestamp < entries[j].Timestamp
+		}
+		return entries[i].Version.String() < entries[j].Version.String()
+	})
+	return entries
+}
+
+// Get all entries matching the query ordered by timestamp and then by identifier
+// Alerts are keyed by their subject rather than their TypeID, so only queries for other TypeIDs can skip the rest of the map
+func (s *MemoryStorage) FindEntries(query *Query) []*mtr.CTObject {
+	var entries []*mtr.CTObject
+	s.mu.RLock()
+	for first, secondMap := range s.ctObjectMap {
+		if query.TypeID != "" && query.TypeID != mtr.AlertTypeID && first != query.TypeID {
+			continue
+		}
+		for _, timestampMap := range secondMap {
+			for _, versionMap := range timestampMap {
+				for _, ctObject := range versionMap {
+					if query.Matches(ctObject) {
+						entries = append(entries, ctObject)
+					}
+				}
+			}
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].Identifier(), entries[j].Identifier()
+		if a.Third != b.Third {
+			return a.Third < b.Third
+		}
+		if a.First != b.First {
+			return a.First < b.First
+		}
+		if a.Second != b.Second {
+			return a.Second < b.Second
+		}
+		return a.Fourth < b.Fourth
+	})
+	return entries
+}
+
+// MemoryStorage holds no resources
+func (s *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+const (
+	testLogID = "testlog"
+)
+
+func mustCreateCTObject(t *testing.T, typeID string, timestamp uint64, blob string) *mtr.CTObject {
+	t.Helper()
+	return &mtr.CTObject{
+		TypeID:    typeID,
+		Version:   mtr.VersionData{Major: 1, Minor: 0, Release: 0},
+		Timestamp: timestamp,
+		Signer:    testLogID,
+		Digest:    []byte(blob),
+		Blob:      []byte(blob),
+	}
+}
+
+func mustCreateFileStorage(t *testing.T, path string) *FileStorage {
+	t.Helper()
+	fileStorage, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("failed to create FileStorage at %s: %v", path, err)
+	}
+	return fileStorage
+}
+
+func testAddGetEntry(t *testing.T, s Storage) {
+	t.Helper()
+	sth := mustCreateCTObject(t, mtr.STHTypeID, 10, "sth")
+	if err := s.AddEntry(sth); err != nil {
+		t.Fatalf("failed to add entry: %v", err)
+	}
+	if entry := s.GetEntry(sth.Identifier()); !reflect.DeepEqual(entry, sth) {
+		t.Fatalf("stored entry (%v) doesn't match added entry (%v)", entry, sth)
+	}
+	missingID := sth.Identifier()
+	missingID.Third = 11
+	if entry := s.GetEntry(missingID); entry != nil {
+		t.Fatalf("got entry (%v) for identifier that was never added", entry)
+	}
+}
+
+func TestMemoryStorageAddGetEntry(t *testing.T) {
+	testAddGetEntry(t, NewMemoryStorage())
+}
+
+func TestFileStorageAddGetEntry(t *testing.T) {
+	fileStorage := mustCreateFileStorage(t, filepath.Join(t.TempDir(), "storage"))
+	defer fileStorage.Close()
+	testAddGetEntry(t, fileStorage)
+}
+
+func TestGetEntriesOrderedByTimestamp(t *testing.T) {
+	s := NewMemoryStorage()
+	for _, timestamp := range []uint64{30, 10, 20} {
+		s.AddEntry(mustCreateCTObject(t, mtr.STHTypeID, timestamp, "sth"))
+	}
+	s.AddEntry(mustCreateCTObject(t, mtr.STHPOCTypeID, 15, "sthpoc"))
+
+	entries := s.GetEntries(mtr.STHTypeID, testLogID)
+	if len(entries) != 3 {
+		t.Fatalf("got %d STH entries, expected 3", len(entries))
+	}
+	for i, timestamp := range []uint64{10, 20, 30} {
+		if entries[i].Timestamp != timestamp {
+			t.Fatalf("entry %d has timestamp %d, expected %d", i, entries[i].Timestamp, timestamp)
+		}
+	}
+}
+
+func TestFileStoragePersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage")
+	fileStorage := mustCreateFileStorage(t, path)
+	sth := mustCreateCTObject(t, mtr.STHTypeID, 10, "sth")
+	replacedSTH := mustCreateCTObject(t, mtr.STHTypeID, 20, "old")
+	newSTH := mustCreateCTObject(t, mtr.STHTypeID, 20, "new")
+	for _, ctObject := range []*mtr.CTObject{sth, replacedSTH, newSTH} {
+		if err := fileStorage.AddEntry(ctObject); err != nil {
+			t.Fatalf("failed to add entry: %v", err)
+		}
+	}
+	fileStorage.Close()
+
+	reopened := mustCreateFileStorage(t, path)
+	defer reopened.Close()
+	if entry := reopened.GetEntry(sth.Identifier()); !reflect.DeepEqual(entry, sth) {
+		t.Fatalf("reloaded entry (%v) doesn't match added entry (%v)", entry, sth)
+	}
+	if entry := reopened.GetEntry(newSTH.Identifier()); !reflect.DeepEqual(entry, newSTH) {
+		t.Fatalf("reloaded entry (%v) doesn't match latest added entry (%v)", entry, newSTH)
+	}
+}
+
+func TestFileStorageDropsPartialEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage")
+	fileStorage := mustCreateFileStorage(t, path)
+	sth := mustCreateCTObject(t, mtr.STHTypeID, 10, "sth")
+	fileStorage.AddEntry(sth)
+	fileStorage.Close()
+
+	// Simulate a crash in the middle of writing an entry
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open storage file: %v", err)
+	}
+	file.Write([]byte(`{"TypeID":"STH","Vers`))
+	file.Close()
+
+	reopened := mustCreateFileStorage(t, path)
+	nextSTH := mustCreateCTObject(t, mtr.STHTypeID, 20, "sth")
+	if err := reopened.AddEntry(nextSTH); err != nil {
+		t.Fatalf("failed to add entry after partial write: %v", err)
+	}
+	reopened.Close()
+
+	reopened = mustCreateFileStorage(t, path)
+	defer reopened.Close()
+	if len(reopened.GetEntries(mtr.STHTypeID, testLogID)) != 2 {
+		t.Fatalf("expected both complete entries to survive the partial write")
+	}
+}
+
+// Run with -race to check concurrent reads and writes are synchronized
+func testConcurrentAccess(t *testing.T, s Storage) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(timestamp uint64) {
+			defer wg.Done()
+			sth := mustCreateCTObject(t, mtr.STHTypeID, timestamp, "sth")
+			for j := 0; j < 20; j++ {
+				s.AddEntry(sth)
+				s.GetEntry(sth.Identifier())
+				s.GetEntries(mtr.STHTypeID, testLogID)
+			}
+		}(uint64(i))
+	}
+	wg.Wait()
+	if len(s.GetEntries(mtr.STHTypeID, testLogID)) != 20 {
+		t.Fatalf("expected 20 entries after concurrent adds")
+	}
+}
+
+func TestMemoryStorageConcurrentAccess(t *testing.T) {
+	testConcurrentAccess(t, NewMemoryStorage())
+}
+
+func TestFileStorageConcurrentAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage")
+	fileStorage := mustCreateFileStorage(t, path)
+	testConcurrentAccess(t, fileStorage)
+	fileStorage.Close()
+
+	reopened := mustCreateFileStorage(t, path)
+	defer reopened.Close()
+	if len(reopened.GetEntries(mtr.STHTypeID, testLogID)) != 20 {
+		t.Fatalf("expected 20 entries after reloading concurrently written file")
+	}
+}
+
+func TestFindEntries(t *testing.T) {
+	s := NewMemoryStorage()
+	for _, timestamp := range []uint64{30, 10, 20} {
+		s.AddEntry(mustCreateCTObject(t, mtr.STHTypeID, timestamp, "sth"))
+	}
+	s.AddEntry(mustCreateCTObject(t, mtr.STHPOCTypeID, 20, "sthpoc"))
+	alert := mustCreateCTObject(t, mtr.AlertTypeID, 20, "alert")
+	alert.Signer, alert.Subject = "testmonitor", testLogID
+	s.AddEntry(alert)
+
+	tests := []struct {
+		query 		Query
+		typeIDs 	[]string
+		timestamps 	[]uint64
+	}{
+		{Query{}, []string{mtr.STHTypeID, mtr.STHTypeID, mtr.STHPOCTypeID, mtr.AlertTypeID, mtr.STHTypeID}, []uint64{10, 20, 20, 20, 30}},
+		{Query{TypeID: mtr.STHTypeID, StartTimestamp: 15}, []string{mtr.STHTypeID, mtr.STHTypeID}, []uint64{20, 30}},
+		{Query{StartTimestamp: 20, EndTimestamp: 20, Signer: testLogID}, []string{mtr.STHTypeID, mtr.STHPOCTypeID}, []uint64{20, 20}},
+		{Query{TypeID: mtr.AlertTypeID, Subject: testLogID}, []string{mtr.AlertTypeID}, []uint64{20}},
+		{Query{Version: "2.0"}, nil, nil},
+	}
+	for i, test := range tests {
+		entries := s.FindEntries(&test.query)
+		if len(entries) != len(test.typeIDs) {
+			t.Fatalf("query %d found %d entries, expected %d", i, len(entries), len(test.typeIDs))
+		}
+		for j, entry := range entries {
+			if entry.TypeID != test.typeIDs[j] || entry.Timestamp != test.timestamps[j] {
+				t.Fatalf("query %d entry %d is %s at %d, expected %s at %d", i, j, entry.TypeID, entry.Timestamp, test.typeIDs[j], test.timestamps[j])
+			}
+		}
+	}
+}
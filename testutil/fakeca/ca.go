@@ -0,0 +1,359 @@
+// Package fakeca runs an in-process revocation transparency CA for tests
+// The CA signs SRDWithRevData objects with real compressed CRV deltas and can be made to equivocate
+package fakeca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Workiva/go-datastructures/bitarray"
+	"github.com/google/certificate-transparency-go/tls"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/signature"
+
+	ctca "github.com/n-ct/ct-certificate-authority"
+	"github.com/n-ct/ct-certificate-authority/ca"
+)
+
+// Seconds between the SRDs of the CA
+const defaultMMD = 1
+
+// CA is a fake revocation transparency CA served over httptest
+// Every MMD the CA applies the revocations made since the previous MMD to its CRV and signs an SRDWithRevData for the new CRV
+// Once equivocating, the CA also keeps a forked CRV missing the hidden revocations and serves the SRDs of the forked CRV instead
+type CA struct {
+	mu sync.Mutex
+	server *httptest.Server
+	signer *signature.Signer
+	caID string
+	key string
+	mmd uint64
+	nextTimestamp uint64	// Timestamp of the next SRD
+	pending []uint64	// Revocations made since the previous MMD
+	honest *view
+	forked *view	// nil until the CA equivocates
+	hidden map[uint64]bool	// Revocations left out of the forked CRV
+}
+
+// CRV of the CA along with every SRD of it
+type view struct {
+	crv *bitarray.BitArray
+	srds map[uint64]*mtr.SRDWithRevData
+	diverged bool	// Whether the CRV of a forked view differs from the honest CRV
+}
+
+// Start a new CA with an empty CRV that is shut down when the test finishes
+func New(t *testing.T) *CA {
+	t.Helper()
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate fake CA key: %v", err)
+	}
+	derPrivKey, err := x509.MarshalECPrivateKey(privKey)
+	if err != nil {
+		t.Fatalf("failed to marshal fake CA key: %v", err)
+	}
+	signer, err := signature.NewSigner(base64.StdEncoding.EncodeToString(derPrivKey))
+	if err != nil {
+		t.Fatalf("failed to create fake CA signer: %v", err)
+	}
+	derPubKey, err := x509.MarshalPKIXPublicKey(&privKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal fake CA public key: %v", err)
+	}
+	caID := sha256.Sum256(derPubKey)
+	c := &CA{
+		signer: signer,
+		caID: base64.StdEncoding.EncodeToString(caID[:]),
+		key: base64.StdEncoding.EncodeToString(derPubKey),
+		mmd: defaultMMD,
+		nextTimestamp: uint64(time.Now().Unix()),
+		honest: &view{crv: ctca.CreateCRV([]uint64{}, 0), srds: make(map[uint64]*mtr.SRDWithRevData)},
+	}
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc(ctca.RevokeAndProduceSRDPath, c.handleRevokeAndProduceSRD)
+	serveMux.HandleFunc(ctca.GetRevocationStatusPath, c.handleGetRevocationStatus)
+	serveMux.HandleFunc(mtr.GetSRDPath, c.handleGetSRD)
+	serveMux.HandleFunc(mtr.GetRevDataPath, c.handleGetRevData)
+	c.server = httptest.NewServer(serveMux)
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+// Get the URL the CA is served at
+func (c *CA) URL() string {
+	return c.server.URL
+}
+
+// Get the CAID of the CA, the base64 encoded SHA-256 hash of its DER public key
+func (c *CA) CAID() string {
+	return c.caID
+}
+
+// Get the base64 encoded DER public key of the CA
+func (c *CA) Key() string {
+	return c.key
+}
+
+// Get the CAInfo of the CA as it would be found in the CA list
+func (c *CA) CAInfo() *entitylist.CAInfo {
+	return &entitylist.CAInfo{
+		CAID: c.caID,
+		CAKey: c.key,
+		CAURL: c.URL(),
+		MMD: c.mmd,
+	}
+}
+
+// Revoke the certificates with the given revocation numbers at the end of the current MMD
+func (c *CA) Revoke(revocationNumbers ...uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, revocationNumbers...)
+}
+
+// Start equivocating by keeping a forked CRV that never holds the hidden revocation numbers
+// The forked CRV starts from the current CRV and its SRDs are served in place of the honest ones from then on
+func (c *CA) Equivocate(hidden ...uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.forked == nil {
+		c.forked = &view{crv: c.honest.crv, srds: make(map[uint64]*mtr.SRDWithRevData)}
+		c.hidden = make(map[uint64]bool)
+	}
+	for _, revocationNumber := range hidden {
+		c.hidden[revocationNumber] = true
+	}
+}
+
+// End the MMD by applying the revocations made during it to the CRV and signing an SRDWithRevData for the new CRV
+// Returns the SRDWithRevData CTObject of the honest CRV even while the CA equivocates
+func (c *CA) ProduceSRD() (*mtr.CTObject, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timestamp := c.nextTimestamp
+	if _, err := c.produceSRD(); err != nil {
+		return nil, err
+	}
+	return mtr.ConstructCTObject(c.honest.srds[timestamp])
+}
+
+// Get the SRDWithRevData CTObject of the honest CRV at the given timestamp. Returns nil if the CA has no SRD at timestamp
+func (c *CA) SRD(timestamp uint64) *mtr.CTObject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.honest.srdCT(timestamp)
+}
+
+// Get the SRDWithRevData CTObject of the forked CRV at the given timestamp
+// Returns nil if the CA wasn't equivocating at timestamp
+func (c *CA) ForkedSRD(timestamp uint64) *mtr.CTObject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.forked == nil {
+		return nil
+	}
+	return c.forked.srdCT(timestamp)
+}
+
+// Get the SRDWithRevData the CA serves at timestamp, which is the forked one while the CA equivocates. Must be called with the lock held
+// Returns nil if the CA has no SRD at timestamp
+func (c *CA) servedSRD(timestamp uint64) *mtr.SRDWithRevData {
+	if c.forked != nil {
+		if srd, ok := c.forked.srds[timestamp]; ok {
+			return srd
+		}
+	}
+	return c.honest.srds[timestamp]
+}
+
+// Apply the pending revocations to every CRV of the CA and sign their SRDs. Must be called with the lock held
+// Returns the SRDWithRevData of the CRV the CA serves
+func (c *CA) produceSRD() (*mtr.SRDWithRevData, error) {
+	timestamp := c.nextTimestamp
+	honestSRD, err := c.honest.produceSRD(c.pending, timestamp, c.caID, c.signer)
+	if err != nil {
+		return nil, err
+	}
+	served := honestSRD
+	if c.forked != nil {
+		var forkedRevocations []uint64
+		for _, revocationNumber := range c.pending {
+			if !c.hidden[revocationNumber] {
+				forkedRevocations = append(forkedRevocations, revocationNumber)
+			}
+		}
+
+		// Until a hidden revocation splits the CRVs the forked CRV shares the honest SRDs, as re-signing the same CRV isn't equivocating
+		if !c.forked.diverged && len(forkedRevocations) == len(c.pending) {
+			c.forked.crv = c.honest.crv
+			c.forked.srds[timestamp] = honestSRD
+		} else {
+			c.forked.diverged = true
+			served, err = c.forked.produceSRD(forkedRevocations, timestamp, c.caID, c.signer)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	c.pending = nil
+	c.nextTimestamp += c.mmd
+	return served, nil
+}
+
+// Apply the revocations to the CRV of the view and sign an SRDWithRevData for the new CRV at timestamp
+func (v *view) produceSRD(revocationNumbers []uint64, timestamp uint64, caID string, signer *signature.Signer) (*mtr.SRDWithRevData, error) {
+	delta := ctca.GetCRVDelta(revocationNumbers)
+	crv := ctca.ApplyCRVDeltaToCRV(v.crv, delta)
+	srd, err := ca.CreateSRDWithRevData(crv, delta, timestamp, caID, tls.SHA256, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to produce SRD at timestamp %d: %w", timestamp, err)
+	}
+	v.crv = crv
+	v.srds[timestamp] = srd
+	return srd, nil
+}
+
+// Get the SRDWithRevData CTObject of the view at timestamp. Returns nil if the view has no SRD at timestamp
+func (v *view) srdCT(timestamp uint64) *mtr.CTObject {
+	srd, ok := v.srds[timestamp]
+	if !ok {
+		return nil
+	}
+	srdCT, err := mtr.ConstructCTObject(srd)
+	if err != nil {
+		return nil
+	}
+	return srdCT
+}
+
+// Revoke the given percent of TotalCerts and end the MMD like the RevokeAndProduceSRD endpoint of a CA
+// The lowest revocation numbers not yet revoked are revoked so responses are deterministic
+func (c *CA) handleRevokeAndProduceSRD(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		rw.Header().Add("Allow", "GET")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var revAndProdSRDReq ctca.RevokeAndProduceSRDRequest
+	if err := json.NewDecoder(req.Body).Decode(&revAndProdSRDReq); err != nil {
+		http.Error(rw, fmt.Sprintf("Invalid RevokeAndProduceSRDRequest: %v", err), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	numToRevoke := uint64(math.Floor(float64(revAndProdSRDReq.TotalCerts) * float64(revAndProdSRDReq.PercentRevoked) / 100))
+	for revocationNumber := uint64(0); numToRevoke > 0 && revocationNumber < revAndProdSRDReq.TotalCerts; revocationNumber++ {
+		revoked := false
+		if revocationNumber < (*c.honest.crv).Capacity() {
+			revoked, _ = (*c.honest.crv).GetBit(revocationNumber)
+		}
+		if !revoked {
+			c.pending = append(c.pending, revocationNumber)
+			numToRevoke--
+		}
+	}
+	srd, err := c.produceSRD()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(srd)
+}
+
+// Serve the latest SRD of the CA within its revocation status like the GetRevocationStatus endpoint of a CA
+func (c *CA) handleGetRevocationStatus(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		rw.Header().Add("Allow", "GET")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	srd := c.servedSRD(c.nextTimestamp - c.mmd)
+	if srd == nil {
+		http.Error(rw, "CA has not produced an SRD", http.StatusNotFound)
+		return
+	}
+	srdCT, err := mtr.ConstructCTObject(srd)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(&ctca.RevocationStatus{CASRD: *srdCT, LogSRDs: []mtr.CTObject{}})
+}
+
+// Serve the SRDWithRevData at the timestamp query parameter
+func (c *CA) handleGetSRD(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		rw.Header().Add("Allow", "GET")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	timestamp, err := strconv.ParseUint(req.URL.Query().Get("timestamp"), 10, 64)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Invalid timestamp: %v", err), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	srd := c.servedSRD(timestamp)
+	if srd == nil {
+		http.Error(rw, fmt.Sprintf("No SRD at timestamp %d", timestamp), http.StatusNotFound)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(srd)
+}
+
+// Serve the SRDWithRevData of every MMD from the start to the end query parameters inclusive
+func (c *CA) handleGetRevData(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		rw.Header().Add("Allow", "GET")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	start, err := strconv.ParseUint(req.URL.Query().Get("start"), 10, 64)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Invalid start: %v", err), http.StatusBadRequest)
+		return
+	}
+	end, err := strconv.ParseUint(req.URL.Query().Get("end"), 10, 64)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Invalid end: %v", err), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	revDataRsp := mtr.GetRevDataResponse{SRDs: []mtr.SRDWithRevData{}}
+	var timestamps []uint64
+	for timestamp := range c.honest.srds {
+		if timestamp >= start && timestamp <= end {
+			timestamps = append(timestamps, timestamp)
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	for _, timestamp := range timestamps {
+		revDataRsp.SRDs = append(revDataRsp.SRDs, *c.servedSRD(timestamp))
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(&revDataRsp)
+}
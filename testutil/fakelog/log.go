@@ -0,0 +1,384 @@
+// Package fakelog runs an in-process RFC 6962 CT log for tests
+// The log keeps a real Merkle tree, signs its STHs with a real ECDSA key, and can be made to misbehave
+package fakelog
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+
+	"github.com/n-ct/ct-monitor/entitylist"
+)
+
+// Entries served by a single get-entries request when MaxGetEntries isn't set
+const defaultMaxGetEntries = 1000
+
+// Log is a fake CT log served over httptest
+// Its STHs cover every entry added so far and are timestamped when the tree last changed
+type Log struct {
+	mu sync.Mutex
+	server *httptest.Server
+	privKey *ecdsa.PrivateKey
+	badKey *ecdsa.PrivateKey	// Key signing STHs in place of privKey while badSignature is set
+	badSignature bool
+	unavailable bool	// Whether every request is answered with 503
+	entries []ct.LeafEntry
+	leafHashes [][]byte
+	timestamp uint64	// Timestamp of the STH of the current tree
+	MaxGetEntries int	// Most entries returned by a get-entries request. 0 returns defaultMaxGetEntries
+}
+
+// Start a new empty Log that is shut down when the test finishes
+func New(t *testing.T) *Log {
+	t.Helper()
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate fake log key: %v", err)
+	}
+	badKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate fake log key: %v", err)
+	}
+	l := &Log{privKey: privKey, badKey: badKey, timestamp: nowMillis()}
+	l.server = httptest.NewServer(l.handler())
+	t.Cleanup(l.server.Close)
+	return l
+}
+
+// Start a copy of the Log at another URL that is shut down when the test finishes
+// The copy signs with the same key, so forking either one presents a split view of the same log to their clients
+func (l *Log) Clone(t *testing.T) *Log {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clone := &Log{
+		privKey: l.privKey,
+		badKey: l.badKey,
+		badSignature: l.badSignature,
+		entries: append([]ct.LeafEntry(nil), l.entries...),
+		leafHashes: append([][]byte(nil), l.leafHashes...),
+		timestamp: l.timestamp,
+		MaxGetEntries: l.MaxGetEntries,
+	}
+	clone.server = httptest.NewServer(clone.handler())
+	t.Cleanup(clone.server.Close)
+	return clone
+}
+
+// Get the URL the Log is served at
+func (l *Log) URL() string {
+	return l.server.URL
+}
+
+// Get the base64 encoded DER public key of the Log
+func (l *Log) Key() string {
+	der, _ := x509.MarshalPKIXPublicKey(&l.privKey.PublicKey)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// Get the LogID of the Log, the base64 encoded SHA-256 hash of its DER public key
+func (l *Log) LogID() string {
+	der, _ := x509.MarshalPKIXPublicKey(&l.privKey.PublicKey)
+	logID := sha256.Sum256(der)
+	return base64.StdEncoding.EncodeToString(logID[:])
+}
+
+// Get the LogInfo of the Log as it would be found in the log list
+func (l *Log) LogInfo() *entitylist.LogInfo {
+	return &entitylist.LogInfo{
+		Description: "Fake log",
+		LogID: l.LogID(),
+		Key: l.Key(),
+		URL: l.URL(),
+		MMD: 1,
+	}
+}
+
+// Append the entries to the Log and advance the timestamp of its STH
+func (l *Log) AddLeafEntries(entries ...ct.LeafEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, entry := range entries {
+		l.entries = append(l.entries, entry)
+		l.leafHashes = append(l.leafHashes, hashLeaf(entry.LeafInput))
+	}
+	l.advanceTimestamp()
+}
+
+// Append entries with the given leaf inputs to the Log
+func (l *Log) AddLeaves(leafInputs ...[]byte) {
+	entries := make([]ct.LeafEntry, len(leafInputs))
+	for i, leafInput := range leafInputs {
+		entries[i].LeafInput = leafInput
+	}
+	l.AddLeafEntries(entries...)
+}
+
+// Append an entry for the DER encoded certificate logged at the given timestamp
+func (l *Log) AddCertificate(der []byte, timestamp uint64) {
+	l.AddLeaves(createLeafInput(der, timestamp))
+}
+
+// Append n entries with placeholder certificates
+func (l *Log) AddEntries(n int) {
+	leafInputs := make([][]byte, n)
+	size := l.TreeSize()
+	for i := range leafInputs {
+		leafInputs[i] = createLeafInput([]byte(fmt.Sprintf("certificate %d", size + uint64(i))), nowMillis())
+	}
+	l.AddLeaves(leafInputs...)
+}
+
+// Get the number of entries in the Log
+func (l *Log) TreeSize() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.entries))
+}
+
+// Get the root hash of the tree of the first treeSize entries
+func (l *Log) RootHash(treeSize uint64) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return rootHash(l.leafHashes[:treeSize])
+}
+
+// Get the leaf hash of the entry at the given index
+func (l *Log) LeafHash(index uint64) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leafHashes[index]
+}
+
+// Fork the tree of the Log by replacing every entry from index at onwards, as a log presenting a different history would
+// Consistency proofs from trees larger than at no longer verify against the roots of the Log's earlier STHs
+func (l *Log) Fork(at uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := at; i < uint64(len(l.entries)); i++ {
+		l.entries[i] = ct.LeafEntry{LeafInput: createLeafInput([]byte(fmt.Sprintf("forked certificate %d", i)), nowMillis())}
+		l.leafHashes[i] = hashLeaf(l.entries[i].LeafInput)
+	}
+	l.advanceTimestamp()
+}
+
+// Sign STHs with a key other than the Log's key while bad is set
+func (l *Log) SetBadSignature(bad bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.badSignature = bad
+}
+
+// Answer every request with 503 Service Unavailable while unavailable is set, as a log failing to respond would
+func (l *Log) SetUnavailable(unavailable bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unavailable = unavailable
+}
+
+// Set the timestamp of the STH of the current tree, e.g. to sign two different trees with the same timestamp
+func (l *Log) SetTimestamp(timestamp uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.timestamp = timestamp
+}
+
+// Get the timestamp of the STH of the current tree
+func (l *Log) Timestamp() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.timestamp
+}
+
+// Get the STH of the current tree as served by get-sth
+func (l *Log) GetSTH() (*ct.GetSTHResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.getSTH()
+}
+
+// Move the timestamp of the STH past the timestamp of the previous STH. Must be called with the lock held
+func (l *Log) advanceTimestamp() {
+	now := nowMillis()
+	if now <= l.timestamp {
+		now = l.timestamp + 1
+	}
+	l.timestamp = now
+}
+
+// Sign the tree head of the current tree. Must be called with the lock held
+func (l *Log) getSTH() (*ct.GetSTHResponse, error) {
+	treeHead := ct.TreeHeadSignature{
+		Version: ct.V1,
+		SignatureType: ct.TreeHashSignatureType,
+		Timestamp: l.timestamp,
+		TreeSize: uint64(len(l.entries)),
+	}
+	copy(treeHead.SHA256RootHash[:], rootHash(l.leafHashes))
+	data, err := tls.Marshal(treeHead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize tree head: %w", err)
+	}
+	key := l.privKey
+	if l.badSignature {
+		key = l.badKey
+	}
+	sig, err := tls.CreateSignature(*key, tls.SHA256, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tree head: %w", err)
+	}
+	sigBytes, err := tls.Marshal(sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize tree head signature: %w", err)
+	}
+	return &ct.GetSTHResponse{
+		TreeSize: treeHead.TreeSize,
+		Timestamp: treeHead.Timestamp,
+		SHA256RootHash: treeHead.SHA256RootHash[:],
+		TreeHeadSignature: sigBytes,
+	}, nil
+}
+
+// Create the handler serving the RFC 6962 endpoints of the Log
+func (l *Log) handler() http.Handler {
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc(ct.GetSTHPath, l.handleGetSTH)
+	serveMux.HandleFunc(ct.GetSTHConsistencyPath, l.handleGetSTHConsistency)
+	serveMux.HandleFunc(ct.GetEntriesPath, l.handleGetEntries)
+	serveMux.HandleFunc(ct.GetEntryAndProofPath, l.handleGetEntryAndProof)
+	serveMux.HandleFunc(ct.GetProofByHashPath, l.handleGetProofByHash)
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		l.mu.Lock()
+		unavailable := l.unavailable
+		l.mu.Unlock()
+		if unavailable {
+			http.Error(rw, "log unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		serveMux.ServeHTTP(rw, req)
+	})
+}
+
+func (l *Log) handleGetSTH(rw http.ResponseWriter, req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sth, err := l.getSTH()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(rw, sth)
+}
+
+func (l *Log) handleGetSTHConsistency(rw http.ResponseWriter, req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first, err1 := parseParam(req, "first")
+	second, err2 := parseParam(req, "second")
+	if err1 != nil || err2 != nil || first > second || second > uint64(len(l.entries)) {
+		http.Error(rw, "invalid first and second tree sizes", http.StatusBadRequest)
+		return
+	}
+	writeResponse(rw, ct.GetSTHConsistencyResponse{Consistency: consistencyProof(int(first), l.leafHashes[:second])})
+}
+
+func (l *Log) handleGetEntries(rw http.ResponseWriter, req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start, err1 := parseParam(req, "start")
+	end, err2 := parseParam(req, "end")
+	if err1 != nil || err2 != nil || start > end || start >= uint64(len(l.entries)) {
+		http.Error(rw, "invalid start and end", http.StatusBadRequest)
+		return
+	}
+	maxEntries := uint64(l.MaxGetEntries)
+	if maxEntries == 0 {
+		maxEntries = defaultMaxGetEntries
+	}
+	if end >= start + maxEntries {
+		end = start + maxEntries - 1
+	}
+	if end >= uint64(len(l.entries)) {
+		end = uint64(len(l.entries)) - 1
+	}
+	writeResponse(rw, ct.GetEntriesResponse{Entries: l.entries[start:end + 1]})
+}
+
+func (l *Log) handleGetEntryAndProof(rw http.ResponseWriter, req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	index, err1 := parseParam(req, "leaf_index")
+	treeSize, err2 := parseParam(req, "tree_size")
+	if err1 != nil || err2 != nil || index >= treeSize || treeSize > uint64(len(l.entries)) {
+		http.Error(rw, "invalid leaf_index and tree_size", http.StatusBadRequest)
+		return
+	}
+	writeResponse(rw, ct.GetEntryAndProofResponse{
+		LeafInput: l.entries[index].LeafInput,
+		ExtraData: l.entries[index].ExtraData,
+		AuditPath: auditPath(int(index), l.leafHashes[:treeSize]),
+	})
+}
+
+func (l *Log) handleGetProofByHash(rw http.ResponseWriter, req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	leafHash, err1 := base64.StdEncoding.DecodeString(req.URL.Query().Get("hash"))
+	treeSize, err2 := parseParam(req, "tree_size")
+	if err1 != nil || err2 != nil || treeSize > uint64(len(l.entries)) {
+		http.Error(rw, "invalid hash and tree_size", http.StatusBadRequest)
+		return
+	}
+	for index, hash := range l.leafHashes[:treeSize] {
+		if string(hash) == string(leafHash) {
+			writeResponse(rw, ct.GetProofByHashResponse{LeafIndex: int64(index), AuditPath: auditPath(index, l.leafHashes[:treeSize])})
+			return
+		}
+	}
+	http.Error(rw, "leaf hash not found", http.StatusNotFound)
+}
+
+// Serialize the MerkleTreeLeaf of an X509 entry for the DER encoded certificate
+func createLeafInput(der []byte, timestamp uint64) []byte {
+	leaf := ct.MerkleTreeLeaf{
+		Version: ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			Timestamp: timestamp,
+			EntryType: ct.X509LogEntryType,
+			X509Entry: &ct.ASN1Cert{Data: der},
+		},
+	}
+	leafInput, err := tls.Marshal(leaf)
+	if err != nil {
+		panic(fmt.Sprintf("failed to serialize MerkleTreeLeaf: %v", err))
+	}
+	return leafInput
+}
+
+func parseParam(req *http.Request, name string) (uint64, error) {
+	return strconv.ParseUint(req.URL.Query().Get(name), 10, 64)
+}
+
+func writeResponse(rw http.ResponseWriter, resp interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(resp)
+}
+
+func nowMillis() uint64 {
+	return uint64(time.Now().UnixNano() / int64(time.Millisecond))
+}
@@ -0,0 +1,81 @@
+package fakelog
+
+import (
+	"crypto/sha256"
+)
+
+// RFC 6962 Merkle tree over the leaf hashes of the log. The hashing is repeated here rather than taken from the
+// mtr package so the tests of the mtr package can use the fake log
+
+func hashLeaf(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func hashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Get the largest power of two smaller than n. n must be greater than 1
+func splitPoint(n int) int {
+	k := 1
+	for k << 1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// Get the Merkle tree hash MTH of the leaves as defined in RFC 6962 section 2.1
+func rootHash(leafHashes [][]byte) []byte {
+	switch len(leafHashes) {
+	case 0:
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	case 1:
+		return leafHashes[0]
+	}
+	k := splitPoint(len(leafHashes))
+	return hashChildren(rootHash(leafHashes[:k]), rootHash(leafHashes[k:]))
+}
+
+// Get the audit path PATH(m, D[n]) of the leaf at index m as defined in RFC 6962 section 2.1.1
+func auditPath(m int, leafHashes [][]byte) [][]byte {
+	if len(leafHashes) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leafHashes))
+	if m < k {
+		return append(auditPath(m, leafHashes[:k]), rootHash(leafHashes[k:]))
+	}
+	return append(auditPath(m - k, leafHashes[k:]), rootHash(leafHashes[:k]))
+}
+
+// Get the consistency proof PROOF(m, D[n]) between the tree of the first m leaves and the whole tree as defined in RFC 6962 section 2.1.2
+func consistencyProof(m int, leafHashes [][]byte) [][]byte {
+	if m == 0 || m == len(leafHashes) {
+		return nil
+	}
+	return subproof(m, leafHashes, true)
+}
+
+// Get SUBPROOF(m, D[n], b) as defined in RFC 6962 section 2.1.2
+func subproof(m int, leafHashes [][]byte, b bool) [][]byte {
+	n := len(leafHashes)
+	if m == n {
+		if b {
+			return nil
+		}
+		return [][]byte{rootHash(leafHashes)}
+	}
+	k := splitPoint(n)
+	if m <= k {
+		return append(subproof(m, leafHashes[:k], b), rootHash(leafHashes[k:]))
+	}
+	return append(subproof(m - k, leafHashes[k:], false), rootHash(leafHashes[:k]))
+}
@@ -0,0 +1,149 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/golang/glog"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/gossip"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+// Gossiper is a fake gossiper for a single monitor served over httptest
+// It relays every CTObject gossiped by its monitor to the new-info endpoint of the other monitors along with the original
+// signature headers, so the receiving monitors authenticate the monitor the CTObject came from
+type Gossiper struct {
+	mu sync.Mutex
+	server *httptest.Server
+	monitorID string
+	monitorList *entitylist.MonitorList
+	relayed []*mtr.CTObject	// CTObjects relayed to the other monitors
+	paused bool
+	held []heldObject	// CTObjects gossiped while paused
+}
+
+// CTObject gossiped while the Gossiper was paused along with the headers it was gossiped with
+type heldObject struct {
+	header http.Header
+	ctObject *mtr.CTObject
+}
+
+// Create a Gossiper for the monitor with the given monitorID that isn't serving yet
+func newGossiper(monitorID string) *Gossiper {
+	g := &Gossiper{monitorID: monitorID}
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc(gossip.GossipPath, g.handleGossip)
+	g.server = httptest.NewUnstartedServer(serveMux)
+	return g
+}
+
+// Get the URL the Gossiper is served at
+func (g *Gossiper) URL() string {
+	return "http://" + g.server.Listener.Addr().String()
+}
+
+// Get every CTObject the monitor gossiped that was relayed to the other monitors, in the order they were relayed
+func (g *Gossiper) Relayed() []*mtr.CTObject {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*mtr.CTObject(nil), g.relayed...)
+}
+
+// Acknowledge gossiped CTObjects without relaying them until Resume, as a partitioned gossiper would
+func (g *Gossiper) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = true
+}
+
+// Relay the CTObjects gossiped while paused and relay gossiped CTObjects as they arrive again
+func (g *Gossiper) Resume() {
+	g.mu.Lock()
+	held := g.held
+	g.paused = false
+	g.held = nil
+	g.mu.Unlock()
+	for _, h := range held {
+		g.relay(h.header, h.ctObject)
+	}
+}
+
+// Start serving once the monitor list of every monitor is known
+func (g *Gossiper) start(monitorList *entitylist.MonitorList) {
+	g.monitorList = monitorList
+	g.server.Start()
+}
+
+func (g *Gossiper) close() {
+	g.server.Close()
+}
+
+// Handle a CTObject gossiped by the monitor by relaying it to every other monitor
+// Responses of the other monitors are ignored, as a monitor rejecting an object doesn't make the gossip fail
+func (g *Gossiper) handleGossip(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		rw.Header().Add("Allow", "POST")
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var ctObject mtr.CTObject
+	if err := json.NewDecoder(req.Body).Decode(&ctObject); err != nil {
+		http.Error(rw, fmt.Sprintf("Invalid Gossip Request: %v", err), http.StatusBadRequest)
+		return
+	}
+	monitorID, err := gossip.VerifyRequest(req, g.monitorList, &ctObject, gossip.DefaultMaxRequestAge)
+	if err != nil || monitorID != g.monitorID {
+		http.Error(rw, fmt.Sprintf("Unauthenticated Gossip Request: %v", err), http.StatusUnauthorized)
+		return
+	}
+
+	g.mu.Lock()
+	if g.paused {
+		g.held = append(g.held, heldObject{req.Header.Clone(), &ctObject})
+		g.mu.Unlock()
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+	g.mu.Unlock()
+	g.relay(req.Header, &ctObject)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// Post the CTObject with the given signature headers to the new-info endpoint of every other monitor and record it as relayed
+func (g *Gossiper) relay(header http.Header, ctObject *mtr.CTObject) {
+	jsonBytes, err := json.Marshal(ctObject)
+	if err != nil {
+		glog.Errorf("failed to marshal %s CTObject to relay: %v", ctObject.TypeID, err)
+		return
+	}
+	for _, monitorInfo := range g.monitorList.Monitors() {
+		if monitorInfo.MonitorID == g.monitorID {
+			continue
+		}
+		req, err := http.NewRequest("POST", utils.CreateRequestURL(monitorInfo.MonitorURL, mtr.NewInfoPath), bytes.NewBuffer(jsonBytes))
+		if err != nil {
+			glog.Errorf("failed to create relay request: %v", err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(gossip.MonitorIDHeader, header.Get(gossip.MonitorIDHeader))
+		req.Header.Set(gossip.SignatureHeader, header.Get(gossip.SignatureHeader))
+		req.Header.Set(gossip.TimestampHeader, header.Get(gossip.TimestampHeader))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			glog.Errorf("failed to relay %s CTObject to monitor %s: %v", ctObject.TypeID, monitorInfo.MonitorID, err)
+			continue
+		}
+		resp.Body.Close()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.relayed = append(g.relayed, ctObject)
+}
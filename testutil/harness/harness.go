@@ -0,0 +1,269 @@
+// Package harness runs several monitors together on loopback for integration tests
+// Every monitor serves its handler mux and gossips through its own fake gossiper, which relays to the other monitors
+package harness
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/entitylist"
+	"github.com/n-ct/ct-monitor/handler"
+	"github.com/n-ct/ct-monitor/monitor"
+	"github.com/n-ct/ct-monitor/storage"
+	"github.com/n-ct/ct-monitor/testutil/fakelog"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+const (
+	// Time Eventually waits for a condition to hold
+	eventuallyTimeout = 10 * time.Second
+	eventuallyInterval = 20 * time.Millisecond
+)
+
+// TypeIDs of the PoMs a monitor can hold about a log
+var logPOMTypeIDs = map[string]bool{
+	mtr.ConflictingSTHPOMTypeID: true,
+	mtr.InconsistentSTHPOMTypeID: true,
+	mtr.MismatchedRootPOMTypeID: true,
+	mtr.NonMonotonicSTHPOMTypeID: true,
+	mtr.NonRespondingLogPOMTypeID: true,
+}
+
+// Harness is a group of monitors that monitor the same fake log and gossip with each other
+type Harness struct {
+	Log *fakelog.Log
+	Nodes []*Node
+}
+
+// Node is a single monitor of the Harness along with its server and fake gossiper
+type Node struct {
+	Monitor *monitor.Monitor
+	Gossiper *Gossiper
+	server *httptest.Server
+	ctx context.Context	// Cancelled once the test finishes
+	wg *sync.WaitGroup	// Background goroutines of the Node
+}
+
+// Start numMonitors monitors of the log that are shut down when the test finishes
+// Every monitor is created from its own config, monitor list, log list, and CA list files like a deployed monitor
+func New(t *testing.T, numMonitors int, log *fakelog.Log) *Harness {
+	t.Helper()
+	dir := t.TempDir()
+	h := &Harness{Log: log}
+	operator := &entitylist.MonitorOperator{Name: "Harness"}
+	var configs []*monitor.MonitorConfig
+	for i := 0; i < numMonitors; i++ {
+		monitorInfo, config := mustCreateMonitorIdentity(t, log.LogID())
+		node := &Node{Gossiper: newGossiper(monitorInfo.MonitorID), server: httptest.NewUnstartedServer(nil)}
+		monitorInfo.MonitorURL = "http://" + node.server.Listener.Addr().String()
+		monitorInfo.GossiperURL = node.Gossiper.URL()
+		operator.Monitors = append(operator.Monitors, monitorInfo)
+		configs = append(configs, config)
+		h.Nodes = append(h.Nodes, node)
+	}
+
+	monitorListName := mustWriteJSON(t, dir, "monitor_list.json", &entitylist.MonitorList{MonitorOperators: []*entitylist.MonitorOperator{operator}})
+	logListName := mustWriteJSON(t, dir, "log_list.json", &entitylist.LogList{Operators: []*entitylist.Operator{{Name: "Fake", Logs: []*entitylist.LogInfo{log.LogInfo()}}}})
+	caListName := mustWriteJSON(t, dir, "ca_list.json", &entitylist.CAList{CAOperators: []*entitylist.CAOperator{}})
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i, node := range h.Nodes {
+		configName := mustWriteJSON(t, dir, fmt.Sprintf("monitor_config_%d.json", i), configs[i])
+		m, err := monitor.NewMonitor(configName, monitorListName, logListName, caListName)
+		if err != nil {
+			t.Fatalf("failed to create monitor %d: %v", i, err)
+		}
+		node.Monitor = m
+		node.ctx = ctx
+		node.wg = &wg
+		node.server.Config.Handler = handler.NewServeMux(m)
+		node.server.Start()
+		node.Gossiper.start(m.MonitorList)
+		t.Cleanup(node.server.Close)
+		t.Cleanup(node.Gossiper.close)
+	}
+
+	// Stop the background goroutines before the servers they talk to are closed
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	for _, node := range h.Nodes {
+		node.run(node.Monitor.Gossiper.Run)
+	}
+	return h
+}
+
+// Fetch an STH from the log with every monitor in order, as each monitor's Scheduler would at the access time of an MMD
+// Returns the error of each monitor's fetch
+func (h *Harness) FetchSTHs(ctx context.Context) []error {
+	errs := make([]error, len(h.Nodes))
+	for i, node := range h.Nodes {
+		_, errs[i] = node.Monitor.FetchSTH(ctx, h.Log.LogID())
+	}
+	return errs
+}
+
+// Hold back the gossip of every monitor until ResumeGossip, partitioning the monitors from each other
+func (h *Harness) PauseGossip() {
+	for _, node := range h.Nodes {
+		node.Gossiper.Pause()
+	}
+}
+
+// Relay the gossip held back since PauseGossip and keep relaying gossip as it arrives
+func (h *Harness) ResumeGossip() {
+	for _, node := range h.Nodes {
+		node.Gossiper.Resume()
+	}
+}
+
+// Start the Scheduler of every monitor
+func (h *Harness) StartSchedulers() {
+	for _, node := range h.Nodes {
+		node.StartScheduler()
+	}
+}
+
+// Wait until cond holds for every monitor, failing the test if it doesn't within eventuallyTimeout
+func (h *Harness) Eventually(t *testing.T, desc string, cond func(node *Node) bool) {
+	t.Helper()
+	deadline := time.Now().Add(eventuallyTimeout)
+	for {
+		pending := -1
+		for i, node := range h.Nodes {
+			if !cond(node) {
+				pending = i
+				break
+			}
+		}
+		if pending < 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("monitor %d never %s", pending, desc)
+		}
+		time.Sleep(eventuallyInterval)
+	}
+}
+
+// Get the URL the Node's handler mux is served at
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// Fetch STHs of the log from the given fake log, e.g. a clone of the log presenting a split view to this monitor
+// Must be called before the Scheduler of the Node starts
+func (n *Node) ServeLog(log *fakelog.Log) error {
+	logClient, err := mtr.NewLogClient(log.LogInfo())
+	if err != nil {
+		return fmt.Errorf("failed to serve log to monitor %s: %w", n.Monitor.MonitorID, err)
+	}
+	n.Monitor.LogIDMap[log.LogID()] = logClient
+	return nil
+}
+
+// Start the Scheduler of the monitor, which runs until the test finishes
+func (n *Node) StartScheduler() {
+	n.run(monitor.NewScheduler(n.Monitor).Run)
+}
+
+// Get every PoM about the log the monitor has stored
+func (n *Node) POMs(logID string) []*mtr.CTObject {
+	var poms []*mtr.CTObject
+	for _, ctObject := range n.Monitor.FindEntries(&storage.Query{Subject: logID}) {
+		if logPOMTypeIDs[ctObject.TypeID] {
+			poms = append(poms, ctObject)
+		}
+	}
+	return poms
+}
+
+// Get the PoMs of the given TypeID about the log the monitor has stored
+func (n *Node) POMsOfType(logID string, typeID string) []*mtr.CTObject {
+	return n.Monitor.FindEntries(&storage.Query{TypeID: typeID, Subject: logID})
+}
+
+// Audit the STH or SRD CTObject through the audit endpoint of the monitor like a Relying Party
+// Returns the AuditOK or PoM the monitor responded with
+func (n *Node) Audit(ctObject *mtr.CTObject) (*mtr.CTObject, error) {
+	jsonBytes, err := json.Marshal(ctObject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s CTObject to audit: %w", ctObject.TypeID, err)
+	}
+	resp, err := http.Post(utils.CreateRequestURL(n.URL(), mtr.AuditPath), "application/json", bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send audit request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("monitor responded %d to audit request: %s", resp.StatusCode, body)
+	}
+	var auditResp mtr.CTObject
+	if err := json.NewDecoder(resp.Body).Decode(&auditResp); err != nil {
+		return nil, fmt.Errorf("failed to decode audit response: %w", err)
+	}
+	return &auditResp, nil
+}
+
+// Run f in the background until the test finishes
+func (n *Node) run(f func(ctx context.Context)) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		f(n.ctx)
+	}()
+}
+
+// Generate the key of a monitor of the log, returning its entry in the monitor list and its config
+func mustCreateMonitorIdentity(t *testing.T, logID string) (*entitylist.MonitorInfo, *monitor.MonitorConfig) {
+	t.Helper()
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate monitor key: %v", err)
+	}
+	derPrivKey, err := x509.MarshalECPrivateKey(privKey)
+	if err != nil {
+		t.Fatalf("failed to marshal monitor private key: %v", err)
+	}
+	derPubKey, err := x509.MarshalPKIXPublicKey(&privKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal monitor public key: %v", err)
+	}
+	monitorIDHash := sha256.Sum256(derPubKey)
+	monitorID := base64.StdEncoding.EncodeToString(monitorIDHash[:])
+	monitorInfo := &entitylist.MonitorInfo{MonitorID: monitorID, MonitorKey: base64.StdEncoding.EncodeToString(derPubKey)}
+	config := &monitor.MonitorConfig{LogIDs: []string{logID}, MonitorID: monitorID, StrPrivKey: base64.StdEncoding.EncodeToString(derPrivKey)}
+	return monitorInfo, config
+}
+
+// Write v as JSON to the named file within dir, returning the path of the file
+func mustWriteJSON(t *testing.T, dir string, name string, v interface{}) string {
+	t.Helper()
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, jsonBytes, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
@@ -0,0 +1,208 @@
+package harness
+
+import (
+	"context"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/signature"
+	"github.com/n-ct/ct-monitor/storage"
+	"github.com/n-ct/ct-monitor/testutil/fakelog"
+)
+
+// Monitors in entitylist/monitor_list.json
+const numMonitors = 5
+
+func mustCreateHarness(t *testing.T, numEntries int) *Harness {
+	t.Helper()
+	log := fakelog.New(t)
+	log.AddEntries(numEntries)
+	return New(t, numMonitors, log)
+}
+
+// Get the STH of the log the way a Relying Party would, signed separately from the STHs the monitors fetched
+func mustGetRelyingPartySTH(t *testing.T, log *fakelog.Log) *mtr.CTObject {
+	t.Helper()
+	logClient, err := mtr.NewLogClient(log.LogInfo())
+	if err != nil {
+		t.Fatalf("failed to create log client: %v", err)
+	}
+	sth, err := logClient.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get STH: %v", err)
+	}
+	return sth
+}
+
+// Audit the STH with the monitor and check that the response has the expected TypeID
+func mustAudit(t *testing.T, i int, node *Node, sth *mtr.CTObject, typeID string) *mtr.CTObject {
+	t.Helper()
+	auditResp, err := node.Audit(sth)
+	if err != nil {
+		t.Fatalf("monitor %d failed to audit STH: %v", i, err)
+	}
+	if auditResp.TypeID != typeID {
+		t.Fatalf("monitor %d responded to audit with %s CTObject, want %s", i, auditResp.TypeID, typeID)
+	}
+	return auditResp
+}
+
+func TestHonestLog(t *testing.T) {
+	h := mustCreateHarness(t, 10)
+	logID := h.Log.LogID()
+	ctx := context.Background()
+	for i, err := range h.FetchSTHs(ctx) {
+		if err != nil {
+			t.Fatalf("monitor %d failed to fetch STH: %v", i, err)
+		}
+	}
+
+	// The log grows and every monitor gets a ConsistencyProof from the tree it already has
+	h.Log.AddEntries(5)
+	for i, err := range h.FetchSTHs(ctx) {
+		if err != nil {
+			t.Fatalf("monitor %d failed to fetch STH: %v", i, err)
+		}
+	}
+	h.Eventually(t, "relayed its STHs", func(node *Node) bool {
+		return len(node.Gossiper.Relayed()) >= 2
+	})
+
+	// Each monitor vouches for the tree head the Relying Party got from the log, despite the different signature
+	sth := mustGetRelyingPartySTH(t, h.Log)
+	for i, node := range h.Nodes {
+		auditOK, err := mustAudit(t, i, node, sth, mtr.STHAuditOKTypeID).DeconstructSTHAuditOK()
+		if err != nil {
+			t.Fatalf("monitor %d: %v", i, err)
+		}
+		monitorKey := node.Monitor.MonitorList.FindMonitorByMonitorID(node.Monitor.MonitorID).MonitorKey
+		if err := signature.VerifySignature(monitorKey, auditOK.STH, auditOK.Signature); err != nil {
+			t.Fatalf("monitor %d STHAuditOK signature doesn't verify: %v", i, err)
+		}
+		if poms := node.POMs(logID); len(poms) != 0 {
+			t.Fatalf("monitor %d has %d PoMs of honest log, first %s", i, len(poms), poms[0].TypeID)
+		}
+	}
+}
+
+func TestSplitView(t *testing.T) {
+	h := mustCreateHarness(t, 10)
+	logID := h.Log.LogID()
+	ctx := context.Background()
+	for i, err := range h.FetchSTHs(ctx) {
+		if err != nil {
+			t.Fatalf("monitor %d failed to fetch STH: %v", i, err)
+		}
+	}
+
+	// The log shows the first two monitors a different tree of the same size at the same timestamp
+	forked := h.Log.Clone(t)
+	h.Log.AddEntries(5)
+	forked.AddEntries(5)
+	forked.Fork(10)
+	forked.SetTimestamp(h.Log.Timestamp())
+	numForked := 2
+	for _, node := range h.Nodes[:numForked] {
+		if err := node.ServeLog(forked); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	// While partitioned each monitor only knows its own view and vouches for it
+	h.PauseGossip()
+	for i, err := range h.FetchSTHs(ctx) {
+		if err != nil {
+			t.Fatalf("monitor %d failed to fetch STH: %v", i, err)
+		}
+	}
+	honestSTH := mustGetRelyingPartySTH(t, h.Log)
+	forkedSTH := mustGetRelyingPartySTH(t, forked)
+	for i, node := range h.Nodes {
+		ownSTH, otherSTH := honestSTH, forkedSTH
+		if i < numForked {
+			ownSTH, otherSTH = forkedSTH, honestSTH
+		}
+		mustAudit(t, i, node, ownSTH, mtr.STHAuditOKTypeID)
+		mustAudit(t, i, node, otherSTH, mtr.ConflictingSTHPOMTypeID)
+		if poms := node.POMs(logID); len(poms) != 0 {
+			t.Fatalf("monitor %d has %d PoMs before gossip, first %s", i, len(poms), poms[0].TypeID)
+		}
+	}
+
+	// Once the views are gossiped every monitor ends up with the ConflictingSTHPOM
+	h.ResumeGossip()
+	h.Eventually(t, "stored a ConflictingSTHPOM", func(node *Node) bool {
+		return len(node.POMsOfType(logID, mtr.ConflictingSTHPOMTypeID)) > 0
+	})
+	for i, node := range h.Nodes {
+		for _, pom := range node.POMs(logID) {
+			if pom.TypeID != mtr.ConflictingSTHPOMTypeID {
+				t.Fatalf("monitor %d has %s PoM of split view", i, pom.TypeID)
+			}
+			if pom.Timestamp != h.Log.Timestamp() {
+				t.Fatalf("monitor %d has ConflictingSTHPOM at timestamp %d, want %d", i, pom.Timestamp, h.Log.Timestamp())
+			}
+			if err := node.Monitor.VerifyCTObject(pom); err != nil {
+				t.Fatalf("monitor %d ConflictingSTHPOM failed verification: %v", i, err)
+			}
+		}
+	}
+}
+
+func TestNonRespondingLog(t *testing.T) {
+	h := mustCreateHarness(t, 10)
+	logID := h.Log.LogID()
+	h.Log.SetUnavailable(true)
+	h.StartSchedulers()
+
+	// Every monitor alerts each MMD, so a majority of Alerts for the same MMD completes a NonRespondingLogPOM
+	h.Eventually(t, "stored a NonRespondingLogPOM", func(node *Node) bool {
+		return len(node.POMsOfType(logID, mtr.NonRespondingLogPOMTypeID)) > 0
+	})
+	for i, node := range h.Nodes {
+		for _, pom := range node.POMs(logID) {
+			if pom.TypeID != mtr.NonRespondingLogPOMTypeID {
+				t.Fatalf("monitor %d has %s PoM of nonresponding log", i, pom.TypeID)
+			}
+			if err := node.Monitor.VerifyCTObject(pom); err != nil {
+				t.Fatalf("monitor %d NonRespondingLogPOM failed verification: %v", i, err)
+			}
+		}
+	}
+}
+
+func TestLogNonRespondingToMinority(t *testing.T) {
+	h := mustCreateHarness(t, 10)
+	logID := h.Log.LogID()
+
+	// The log stops responding to two of the five monitors only
+	unavailable := h.Log.Clone(t)
+	unavailable.SetUnavailable(true)
+	numAlerting := 2
+	for _, node := range h.Nodes[:numAlerting] {
+		if err := node.ServeLog(unavailable); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	h.StartSchedulers()
+
+	// Wait until every monitor holds Alerts from both alerting monitors for the same MMD
+	h.Eventually(t, "stored the Alerts of both alerting monitors", func(node *Node) bool {
+		signers := make(map[uint64]map[string]bool)
+		for _, alertCT := range node.Monitor.FindEntries(&storage.Query{TypeID: mtr.AlertTypeID, Subject: logID}) {
+			if signers[alertCT.Timestamp] == nil {
+				signers[alertCT.Timestamp] = make(map[string]bool)
+			}
+			signers[alertCT.Timestamp][alertCT.Signer] = true
+			if len(signers[alertCT.Timestamp]) == numAlerting {
+				return true
+			}
+		}
+		return false
+	})
+	for i, node := range h.Nodes {
+		if poms := node.POMs(logID); len(poms) != 0 {
+			t.Fatalf("monitor %d has %d PoMs from a minority of Alerts, first %s", i, len(poms), poms[0].TypeID)
+		}
+	}
+}
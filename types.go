@@ -19,16 +19,94 @@ const (
 	STHGossipPath	  			= "/ct/v1/sth-gossip"
 	STHWithPOCGossipPath 		= "/ct/v1/sth-with-poc-gossip"
 	SRDWithRevDataGossipPath 	= "/ct/v1/srd-with-revdata-gossip"
+	InclusionAuditPath 			= "/ct/v1/inclusion-audit"
+	RevocationStatusPath 		= "/ct/v1/revocation-status"
+	ObjectsPath 				= "/ct/v1/objects"
+	ObjectPath 					= "/ct/v1/object"
+	StreamPath 					= "/ct/v1/stream"
 )
 
+// Endpoint paths of revocation transparency CAs that serve their past SRDs
+const (
+	GetSRDPath 		= "/ct/v1/get-srd"		// Takes the timestamp of the SRD as a query parameter
+	GetRevDataPath 	= "/ct/v1/get-rev-data"	// Takes the start and end timestamps of the SRDs as query parameters
+)
+
+// SRDWithRevData of every MMD of a CA within the timestamps of a request to the GetRevDataPath, ordered by timestamp
+type GetRevDataResponse struct {
+	SRDs 			[]SRDWithRevData
+}
+
+// Page of the stored CTObjects matching a query to the ObjectsPath, ordered by timestamp
+// The next page starts at NextOffset when HasMore is set
+type ObjectsResponse struct {
+	CTObjects 		[]CTObject
+	Total 			int		// Number of stored CTObjects matching the query
+	HasMore 		bool
+	NextOffset 		int
+}
+
 type STHWithPOCGossipRequest struct {
 	LogID 			string
 	FirstTreeSize 	uint64
 	SecondTreeSize 	uint64
 }
 
-type SRDWithRevDataGossipRequest struct {
+// Request from a Relying Party to audit the inclusion of a certificate within the tree of the given STH
+// LeafHash is used if present. Otherwise the leaf hash is computed from Certificate and SCTTimestamp
+// Precertificates and certificates with embedded SCTs are logged as precertificate entries, which also need IssuerCertificate
+type InclusionAuditRequest struct {
+	STH 				CTObject
+	Certificate 		[]byte	// DER encoded X509 certificate or precertificate
+	IssuerCertificate 	[]byte	// DER encoded X509 certificate of the CA that issued Certificate
+	SCTTimestamp 		uint64	// Timestamp of the SCT the log issued for Certificate
+	LeafHash 			[]byte
+}
+
+// Request from a Relying Party to watch the logs for certificates issued for a domain
+// Domain is either a domain name or a wildcard of the form *.example.com that matches every name below example.com
+type MonitorDomainRequest struct {
+	Domain 			string
+}
+
+// Response to a MonitorDomainRequest with the normalized domain and the matching log entries found so far
+type MonitorDomainResponse struct {
+	Domain 			string
+	Matches 		[]DomainMatch
+}
+
+// A log entry whose certificate or precertificate names a watched domain
+type DomainMatch struct {
+	Domain 			string		// Watched domain that the entry matched
 	LogID 			string
+	LeafIndex 		uint64
+	Timestamp 		uint64		// Timestamp of the log entry
+	IsPrecert 		bool
+	Names 			[]string	// Subject CommonName and DNS SANs of the certificate
+	SerialNumber 	string		// Hex encoded serial number of the certificate
+}
+
+// Request from a Relying Party for whether a certificate of a CA was revoked as of a timestamp
+// The certificate is identified by its RevocationNumber, the serial indexing it within the CA's CRV
+type RevocationStatusRequest struct {
+	CAID 				string
+	RevocationType 		string	// Defaults to LetsRevokeRevocationType
+	RevocationNumber 	uint64
+	Timestamp 			uint64	// Answer from the latest SRD of the CA at or before Timestamp. 0 uses the latest SRD
+}
+
+// Response to a RevocationStatusRequest from the CRV the monitor reconstructed and checked against SRD
+// SRD is the SRD CTObject signed by the CA whose CRVHash matches the CRV the answer is from
+type RevocationStatusResponse struct {
+	CAID 				string
+	RevocationNumber 	uint64
+	Revoked 			bool
+	SRD 				CTObject
+}
+
+// Request for the monitor to have the CA with CAID revoke PercentRevoked of TotalCerts and gossip the SRDWithRevData it produces
+type SRDWithRevDataGossipRequest struct {
+	CAID 			string
 	PercentRevoked 	uint8
 	TotalCerts 		uint64
 }
@@ -40,9 +118,14 @@ const (
 	AlertTypeID 				= "ALERT"
 	ConflictingSTHPOMTypeID 	= "POM_CONFLICTING_STH"
 	ConflictingSRDPOMTypeID 	= "POM_CONFLICTING_SRD"
+	InconsistentSTHPOMTypeID 	= "POM_INCONSISTENT_STH"
+	MismatchedRootPOMTypeID 	= "POM_MISMATCHED_ROOT"
+	NonMonotonicSTHPOMTypeID 	= "POM_NONMONOTONIC_STH"
+	InconsistentCRVPOMTypeID 	= "POM_INCONSISTENT_CRV"
 	NonRespondingLogPOMTypeID 	= "POM_NONRESPONDING_LOG"
 	SRDAuditOKTypeID			= "SRD_AUDIT_OK"
 	STHAuditOKTypeID			= "STH_AUDIT_OK"
+	InclusionAuditOKTypeID		= "INCLUSION_AUDIT_OK"
 
 	// Revocation transparency data
 	SRDWithRevDataTypeID 		= "SRD_REVDATA"
@@ -79,6 +162,16 @@ type Alert struct {
 	Signature 	ct.DigitallySigned
 }
 
+// AlertType const variables
+const (
+	NonRespondingLogAlertType 	= "NONRESPONDING_LOG"
+)
+
+// Revocation types of RevocationData
+const (
+	LetsRevokeRevocationType 	= "Let's-Revoke"
+)
+
 type AlertSignedFields struct {
 	AlertType 	 string // Type of Alert. Currently only have Logger Alert for nonresponding Logger
 	Signer 		 string	// Signer of the Alert This will be entityID (base64 encoded string of sha256 hash of public key)
@@ -96,6 +189,37 @@ type ConflictingSRDPOM struct {
 	SRD2	SignedRevocationDigest
 }
 
+type InconsistentSTHPOM struct {
+	STH1				SignedTreeHeadData
+	STH2				SignedTreeHeadData
+	ConsistencyProof	ConsistencyProofData
+}
+
+// PoM that the entries served by a log don't hash to the root hash of its STH
+// CompactRange holds the subtree hashes of the entries from index 0 up to the tree size of the STH
+// The CompactRange isn't signed by the log, so the PoM is only evidence to the monitor that fetched the entries and isn't gossiped
+type MismatchedRootPOM struct {
+	STH 				SignedTreeHeadData
+	CompactRange 		CompactRange
+	ComputedRootHash 	[]byte
+}
+
+// PoM that a log rolled back its tree. STH1 has an earlier timestamp than STH2
+// Either the tree size of STH2 is smaller than STH1, or they have the same tree size but different root hashes
+type NonMonotonicSTHPOM struct {
+	STH1	SignedTreeHeadData
+	STH2	SignedTreeHeadData
+}
+
+// PoM that the RevocationDigest a CA signed doesn't match the CRV built from its RevocationData
+// The CRVDelta of SRDWithRevData hashes to its CRVDeltaHash, but applying it to PrevCRV doesn't give its CRVHash
+// PrevSRD is the SRD of the CA one MMD earlier and PrevCRV the compressed CRV that hashes to its CRVHash
+type InconsistentCRVPOM struct {
+	PrevSRD 			SignedRevocationDigest
+	PrevCRV 			[]byte
+	SRDWithRevData 		SRDWithRevData
+}
+
 type NonRespondingLogPOM struct {
 	AlertList []Alert
 }
@@ -115,6 +239,17 @@ type STHAuditOK struct {
 	Signature 	ct.DigitallySigned
 }
 
+type InclusionAuditOK struct {
+	TBS 		InclusionAuditSignedFields	// Signed fields of the InclusionAuditOK
+	Signature 	ct.DigitallySigned
+}
+
+type InclusionAuditSignedFields struct {
+	STH 			SignedTreeHeadData	// STH of the tree the leaf is included in
+	LeafHash 		[]byte
+	InclusionProof 	InclusionProofData	// Verified proof of the leaf's inclusion
+}
+
 // Revocation transparency data
 type RevocationData struct {
 	EntityID 	string
@@ -174,6 +309,16 @@ func (v VersionData) String() string {
 	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
 }
 
+// Parse a version in the form of VersionData.String
+func ParseVersion(version string) (VersionData, error) {
+	var v VersionData
+	var rest string
+	if n, _ := fmt.Sscanf(version, "%d.%d%s", &v.Major, &v.Minor, &rest); n != 2 {
+		return VersionData{}, fmt.Errorf("invalid version %q, expected major.minor", version)
+	}
+	return v, nil
+}
+
 type CTObject struct {
 	TypeID 		string // What type of object is found in the blob
 	Version		VersionData	// Version of the CTObject
@@ -253,6 +398,16 @@ func (c *CTObject) DeconstructSTHAuditOK() (*STHAuditOK, error) {
 	return &auditOK, nil
 }
 
+// Deconstruct InclusionAuditOK CTObject
+func (c *CTObject) DeconstructInclusionAuditOK() (*InclusionAuditOK, error) {
+	var auditOK InclusionAuditOK
+	err := json.Unmarshal(c.Blob, &auditOK)
+	if err != nil {
+		return nil, fmt.Errorf("error deconstructing InclusionAuditOK from %s CTObject: %w", c.TypeID, err)
+	}
+	return &auditOK, nil
+}
+
 // Deconstruct ConflictingSTHPOM CTObject
 func (c *CTObject) DeconstructConflictingSTHPOM() (*ConflictingSTHPOM, error) {
 	var pom ConflictingSTHPOM
@@ -273,6 +428,46 @@ func (c *CTObject) DeconstructConflictingSRDPOM() (*ConflictingSRDPOM, error) {
 	return &pom, nil
 }
 
+// Deconstruct InconsistentSTHPOM CTObject
+func (c *CTObject) DeconstructInconsistentSTHPOM() (*InconsistentSTHPOM, error) {
+	var pom InconsistentSTHPOM
+	err := json.Unmarshal(c.Blob, &pom)
+	if err != nil {
+		return nil, fmt.Errorf("error deconstructing InconsistentSTHPOM from %s CTObject: %w", c.TypeID, err)
+	}
+	return &pom, nil
+}
+
+// Deconstruct MismatchedRootPOM CTObject
+func (c *CTObject) DeconstructMismatchedRootPOM() (*MismatchedRootPOM, error) {
+	var pom MismatchedRootPOM
+	err := json.Unmarshal(c.Blob, &pom)
+	if err != nil {
+		return nil, fmt.Errorf("error deconstructing MismatchedRootPOM from %s CTObject: %w", c.TypeID, err)
+	}
+	return &pom, nil
+}
+
+// Deconstruct NonMonotonicSTHPOM CTObject
+func (c *CTObject) DeconstructNonMonotonicSTHPOM() (*NonMonotonicSTHPOM, error) {
+	var pom NonMonotonicSTHPOM
+	err := json.Unmarshal(c.Blob, &pom)
+	if err != nil {
+		return nil, fmt.Errorf("error deconstructing NonMonotonicSTHPOM from %s CTObject: %w", c.TypeID, err)
+	}
+	return &pom, nil
+}
+
+// Deconstruct InconsistentCRVPOM CTObject
+func (c *CTObject) DeconstructInconsistentCRVPOM() (*InconsistentCRVPOM, error) {
+	var pom InconsistentCRVPOM
+	err := json.Unmarshal(c.Blob, &pom)
+	if err != nil {
+		return nil, fmt.Errorf("error deconstructing InconsistentCRVPOM from %s CTObject: %w", c.TypeID, err)
+	}
+	return &pom, nil
+}
+
 // Deconstruct ConflictingSTHPOM CTObject
 func (c *CTObject) DeconstructNonRespondingLogPOM() (*NonRespondingLogPOM, error) {
 	var pom NonRespondingLogPOM
@@ -355,6 +550,7 @@ func ConstructCTObject(i interface{}) (*CTObject, error) {
 		typeID = AlertTypeID
 		timestamp = alert.TBS.Timestamp
 		signer = alert.TBS.Signer
+		subject = alert.TBS.Subject
 		blob, err = signature.SerializeData(alert)
 		if err != nil {
 			return nil, fmt.Errorf("error constructing Alert CTObject serializing data: %w", err)
@@ -364,6 +560,23 @@ func ConstructCTObject(i interface{}) (*CTObject, error) {
 			return nil, fmt.Errorf("error constructing Alert CTObject generating hash: %w", err)
 		}
 	
+	case *NonRespondingLogPOM:
+		pom := i.(*NonRespondingLogPOM)
+		if len(pom.AlertList) == 0 {
+			return nil, fmt.Errorf("error constructing NonRespondingLogPOM CTObject: no Alerts")
+		}
+		typeID = NonRespondingLogPOMTypeID
+		timestamp = pom.AlertList[0].TBS.Timestamp
+		subject = pom.AlertList[0].TBS.Subject
+		blob, err = signature.SerializeData(pom)
+		if err != nil {
+			return nil, fmt.Errorf("error constructing NonRespondingLogPOM CTObject serializing data: %w", err)
+		}
+		digest, _, err = signature.GenerateHash(pom.AlertList[0].Signature.Algorithm.Hash, blob)
+		if err != nil {
+			return nil, fmt.Errorf("error constructing NonRespondingLogPOM CTObject generating hash: %w", err)
+		}
+
 	case *SignedTreeHeadWithConsistencyProof:
 		sth_poc := i.(*SignedTreeHeadWithConsistencyProof)
 		typeID = STHPOCTypeID
@@ -455,6 +668,176 @@ func CreateConflictingSTHPOM(obj1 *CTObject, obj2 *CTObject) (*CTObject, error)
 	return ctObject, nil
 }
 
+// Given two CtObjects that contain STH and a ConsistencyProof that fails to link them, create PoM of inconsistent STHs
+func CreateInconsistentSTHPOM(obj1 *CTObject, obj2 *CTObject, poc *ConsistencyProofData) (*CTObject, error) {
+	if !(obj1.TypeID == STHTypeID || obj1.TypeID == STHPOCTypeID) || !(obj2.TypeID == STHTypeID || obj2.TypeID == STHPOCTypeID) {
+		return nil, fmt.Errorf("Not valid STH or STH_POC CTObjects")
+	}
+
+	var signer string
+	version := VersionData{1,0,0}
+	sth1, err := obj1.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("error creating InconsistentSTHPOM: %w", err)
+	}
+
+	sth2, err := obj2.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("error creating InconsistentSTHPOM: %w", err)
+	}
+
+	if sth1.LogID != sth2.LogID || sth1.LogID != poc.LogID {
+		return nil, fmt.Errorf("STHs and ConsistencyProof are not from the same log. Error creating PoM")
+	}
+
+	// Create fields of the PoM CTObject
+	typeID := InconsistentSTHPOMTypeID
+	timestamp := sth2.TreeHeadData.Timestamp
+	subject := sth2.LogID
+	proof := InconsistentSTHPOM{*sth1, *sth2, *poc}
+	blob, err := signature.SerializeData(proof)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing InconsistentSTHPOM serializing data: %w", err)
+	}
+	digest, _, err := signature.GenerateHash(sth2.Signature.Algorithm.Hash, blob)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing InconsistentSTHPOM generating hash: %w", err)
+	}
+
+	// Create the POM CTObject
+	ctObject := &CTObject{typeID, version, timestamp, signer, subject, digest, blob}
+	return ctObject, nil
+}
+
+// Given a CtObject that contains STH and the CompactRange of the entries the log served up to its tree size, create PoM of a mismatched root hash
+func CreateMismatchedRootPOM(sthCT *CTObject, compactRange *CompactRange) (*CTObject, error) {
+	if sthCT.TypeID != STHTypeID && sthCT.TypeID != STHPOCTypeID {
+		return nil, fmt.Errorf("Not valid STH or STH_POC CTObject")
+	}
+
+	var signer string
+	version := VersionData{1,0,0}
+	sth, err := sthCT.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("error creating MismatchedRootPOM: %w", err)
+	}
+	if sth.TreeHeadData.TreeSize != compactRange.Size {
+		return nil, fmt.Errorf("STH tree size (%d) doesn't match CompactRange size (%d). Error creating PoM", sth.TreeHeadData.TreeSize, compactRange.Size)
+	}
+	computedRoot := compactRange.Root()
+	if bytes.Equal(computedRoot, sth.TreeHeadData.SHA256RootHash[:]) {
+		return nil, fmt.Errorf("entries match STH root hash. Error creating PoM")
+	}
+
+	// Create fields of the PoM CTObject
+	typeID := MismatchedRootPOMTypeID
+	timestamp := sth.TreeHeadData.Timestamp
+	subject := sth.LogID
+	proof := MismatchedRootPOM{*sth, *compactRange, computedRoot}
+	blob, err := signature.SerializeData(proof)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing MismatchedRootPOM serializing data: %w", err)
+	}
+	digest, _, err := signature.GenerateHash(sth.Signature.Algorithm.Hash, blob)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing MismatchedRootPOM generating hash: %w", err)
+	}
+
+	// Create the POM CTObject
+	ctObject := &CTObject{typeID, version, timestamp, signer, subject, digest, blob}
+	return ctObject, nil
+}
+
+// Given two CtObjects that contain STHs of the same log at different timestamps whose tree doesn't grow monotonically, create PoM of non-monotonic STHs
+// The STHs may be given in either order
+func CreateNonMonotonicSTHPOM(obj1 *CTObject, obj2 *CTObject) (*CTObject, error) {
+	if !(obj1.TypeID == STHTypeID || obj1.TypeID == STHPOCTypeID) || !(obj2.TypeID == STHTypeID || obj2.TypeID == STHPOCTypeID) {
+		return nil, fmt.Errorf("Not valid STH or STH_POC CTObjects")
+	}
+
+	var signer string
+	version := VersionData{1,0,0}
+	sth1, err := obj1.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("error creating NonMonotonicSTHPOM: %w", err)
+	}
+
+	sth2, err := obj2.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("error creating NonMonotonicSTHPOM: %w", err)
+	}
+
+	if sth1.LogID != sth2.LogID {
+		return nil, fmt.Errorf("STHs are not from the same log. Error creating PoM")
+	}
+	if sth1.TreeHeadData.Timestamp == sth2.TreeHeadData.Timestamp {
+		return nil, fmt.Errorf("STHs have the same timestamp. Error creating PoM")
+	}
+	if sth1.TreeHeadData.Timestamp > sth2.TreeHeadData.Timestamp {
+		sth1, sth2 = sth2, sth1
+	}
+	if VerifySTHsMonotonic(sth1, sth2) == nil {
+		return nil, fmt.Errorf("STHs are monotonic. Error creating PoM")
+	}
+
+	// Create fields of the PoM CTObject
+	typeID := NonMonotonicSTHPOMTypeID
+	timestamp := sth2.TreeHeadData.Timestamp
+	subject := sth2.LogID
+	proof := NonMonotonicSTHPOM{*sth1, *sth2}
+	blob, err := signature.SerializeData(proof)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing NonMonotonicSTHPOM serializing data: %w", err)
+	}
+	digest, _, err := signature.GenerateHash(sth2.Signature.Algorithm.Hash, blob)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing NonMonotonicSTHPOM generating hash: %w", err)
+	}
+
+	// Create the POM CTObject
+	ctObject := &CTObject{typeID, version, timestamp, signer, subject, digest, blob}
+	return ctObject, nil
+}
+
+// Given the previous SRD of a CA with its compressed CRV and an SRDWithRevData CTObject whose digest doesn't match, create InconsistentCRVPOM
+func CreateInconsistentCRVPOM(prevSRD *SignedRevocationDigest, prevCRV []byte, srdCT *CTObject) (*CTObject, error) {
+	if srdCT.TypeID != SRDWithRevDataTypeID {
+		return nil, fmt.Errorf("Not valid SRDWithRevData CTObject")
+	}
+	if prevSRD == nil {
+		return nil, fmt.Errorf("no previous SRD. Error creating InconsistentCRVPOM")
+	}
+
+	var signer string
+	version := VersionData{1,0,0}
+	srdWithRevData, err := srdCT.deconstructSRDWithRevData()
+	if err != nil {
+		return nil, fmt.Errorf("error creating InconsistentCRVPOM: %w", err)
+	}
+	srd := srdWithRevData.SRD
+	if prevSRD.EntityID != srd.EntityID || prevSRD.RevDigest.Timestamp >= srd.RevDigest.Timestamp {
+		return nil, fmt.Errorf("previous SRD isn't an earlier SRD of CA %s. Error creating PoM", srd.EntityID)
+	}
+	proof := InconsistentCRVPOM{PrevSRD: *prevSRD, PrevCRV: prevCRV, SRDWithRevData: *srdWithRevData}
+
+	// Create fields of the PoM CTObject
+	typeID := InconsistentCRVPOMTypeID
+	timestamp := srd.RevDigest.Timestamp
+	subject := srd.EntityID
+	blob, err := signature.SerializeData(proof)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing InconsistentCRVPOM serializing data: %w", err)
+	}
+	digest, _, err := signature.GenerateHash(srd.Signature.Algorithm.Hash, blob)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing InconsistentCRVPOM generating hash: %w", err)
+	}
+
+	// Create the POM CTObject
+	ctObject := &CTObject{typeID, version, timestamp, signer, subject, digest, blob}
+	return ctObject, nil
+}
+
 // Given two CtObjects that contain STH, create PoM of conflicting STHs
 func CreateConflictingSRDPOM(obj1 *CTObject, obj2 *CTObject) (*CTObject, error) {
 	if obj1.TypeID != SRDWithRevDataTypeID || obj2.TypeID != SRDWithRevDataTypeID {
@@ -496,6 +879,49 @@ func CreateConflictingSRDPOM(obj1 *CTObject, obj2 *CTObject) (*CTObject, error)
 	return ctObject, nil
 }
 
+// Given Alert CTObjects from different monitors about the same log and MMD, create PoM of a nonresponding log
+func CreateNonRespondingLogPOM(alertCTs []*CTObject) (*CTObject, error) {
+	if len(alertCTs) == 0 {
+		return nil, fmt.Errorf("no Alerts to create NonRespondingLogPOM from")
+	}
+	signers := make(map[string]bool)
+	var pom NonRespondingLogPOM
+	for _, alertCT := range alertCTs {
+		if alertCT.TypeID != AlertTypeID {
+			return nil, fmt.Errorf("Not valid Alert CTObject: %s", alertCT.TypeID)
+		}
+		alert, err := alertCT.DeconstructAlert()
+		if err != nil {
+			return nil, fmt.Errorf("error creating NonRespondingLogPOM: %w", err)
+		}
+		if len(pom.AlertList) > 0 && (alert.TBS.Subject != pom.AlertList[0].TBS.Subject || alert.TBS.Timestamp != pom.AlertList[0].TBS.Timestamp) {
+			return nil, fmt.Errorf("Alerts are not about the same Logger and MMD. Error creating PoM")
+		}
+		if signers[alert.TBS.Signer] {
+			return nil, fmt.Errorf("multiple Alerts from monitor %s. Error creating PoM", alert.TBS.Signer)
+		}
+		signers[alert.TBS.Signer] = true
+		pom.AlertList = append(pom.AlertList, *alert)
+	}
+	return ConstructCTObject(&pom)
+}
+
+// Given signer, the entityID of the signer, and a nonresponding log, create Alert for the MMD ending at timestamp
+func CreateAlert(sigSigner *signature.Signer, signerID string, logID string, timestamp uint64) (*CTObject, error) {
+	tbs := AlertSignedFields{
+		AlertType: NonRespondingLogAlertType,
+		Signer: signerID,
+		Subject: logID,
+		Timestamp: timestamp,
+	}
+	sig, err := sigSigner.CreateSignature(tls.SHA256, tbs)
+	if err != nil {
+		return nil, fmt.Errorf("error signing Alert: %w", err)
+	}
+	alert := Alert{tbs, *sig}
+	return ConstructCTObject(&alert)
+}
+
 // Given signer and sth ctobject, create AuditOK
 func CreateSTHAuditOK(sigSigner *signature.Signer, sthCT *CTObject) (*CTObject, error){
 	var signer string
@@ -554,6 +980,40 @@ func CreateSRDAuditOK(sigSigner *signature.Signer, srdCT *CTObject) (*CTObject,
 	}
 	
 	// Create the CTObject PoM
+	ctObject := &CTObject{typeID, version, timestamp, signer, subject, digest, blob}
+	return ctObject, nil
+}
+
+// Given signer, sth ctobject, and verified inclusion proof of the leaf with leafHash, create InclusionAuditOK
+func CreateInclusionAuditOK(sigSigner *signature.Signer, sthCT *CTObject, leafHash []byte, poi *InclusionProofData) (*CTObject, error){
+	var signer string
+	version := VersionData{1,0,0}
+	sth, err := sthCT.DeconstructSTH()
+	if err != nil {
+		return nil, fmt.Errorf("error creating InclusionAuditOK: %w", err)
+	}
+
+	// Create fields of InclusionAuditOK CTObject
+	typeID := InclusionAuditOKTypeID
+	timestamp := sth.TreeHeadData.Timestamp
+	subject := sth.LogID
+
+	// Sign the STH, leafHash, and inclusion proof and create the InclusionAuditOK
+	tbs := InclusionAuditSignedFields{*sth, leafHash, *poi}
+	sig, err := sigSigner.CreateSignature(tls.SHA256, tbs)
+	if err != nil {
+		return nil, fmt.Errorf("error signing InclusionAuditOK: %w", err)
+	}
+	auditOK := InclusionAuditOK{tbs, *sig}
+	blob, err := signature.SerializeData(auditOK)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing InclusionAuditOK serializing data: %w", err)
+	}
+	digest, _, err := signature.GenerateHash(sth.Signature.Algorithm.Hash, blob)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing InclusionAuditOK generating hash: %w", err)
+	}
+
 	ctObject := &CTObject{typeID, version, timestamp, signer, subject, digest, blob}
 	return ctObject, nil
 }
\ No newline at end of file
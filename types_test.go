@@ -175,4 +175,40 @@ func TestConstructDeconstructSRDRoundTrip(t *testing.T) {
 	if !reflect.DeepEqual(baseSRD, deconSRD) {
 		t.Fatalf("deconstructed srd\n(%v) doesn't match original srd\n (%v)", deconSRD, baseSRD)
 	}
-}
\ No newline at end of file
+}
+func TestCreateDeconstructNonRespondingLogPOMRoundTrip(t *testing.T) {
+	signer, err := mustCreateSigner(t, testValidECDSAPrivKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	var alertCTs []*CTObject
+	for _, signerID := range []string{"monitor1", "monitor2"} {
+		alertCT, err := CreateAlert(signer, signerID, "testlog", 1000)
+		if err != nil {
+			t.Fatalf("failed to create Alert: %v", err)
+		}
+		alertCTs = append(alertCTs, alertCT)
+	}
+	pomCT, err := CreateNonRespondingLogPOM(alertCTs)
+	if err != nil {
+		t.Fatalf("failed to create NonRespondingLogPOM: %v", err)
+	}
+	if pomCT.Subject != "testlog" || pomCT.Timestamp != 1000 {
+		t.Fatalf("NonRespondingLogPOM CTObject has wrong subject (%s) or timestamp (%d)", pomCT.Subject, pomCT.Timestamp)
+	}
+	pom, err := pomCT.DeconstructNonRespondingLogPOM()
+	if err != nil {
+		t.Fatalf("failed to deconstruct NonRespondingLogPOM: %v", err)
+	}
+	for i, alertCT := range alertCTs {
+		alert, _ := alertCT.DeconstructAlert()
+		if !reflect.DeepEqual(*alert, pom.AlertList[i]) {
+			t.Fatalf("deconstructed Alert (%v) doesn't match original Alert (%v)", pom.AlertList[i], *alert)
+		}
+	}
+
+	// Two Alerts from the same monitor are not a PoM
+	if _, err := CreateNonRespondingLogPOM([]*CTObject{alertCTs[0], alertCTs[0]}); err == nil {
+		t.Fatalf("created NonRespondingLogPOM from duplicate Alerts")
+	}
+}
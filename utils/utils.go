@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"io/ioutil"
 	"bytes"
@@ -43,4 +44,28 @@ func GetSize(i interface{}) (int, error) {
 		return 0, fmt.Errorf("Failed to encode object of type (%T): %v", i, err)
 	}
 	return b.Len(), nil
-}
\ No newline at end of file
+}
+
+// Write data to a temporary file and rename it over fileName so a crash never leaves a partially written file
+func WriteFileAtomic(fileName string, data []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName) + ".tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file for %s: %v", fileName, err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error writing temporary file for %s: %v", fileName, err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error syncing temporary file for %s: %v", fileName, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file for %s: %v", fileName, err)
+	}
+	if err := os.Rename(tmpFile.Name(), fileName); err != nil {
+		return fmt.Errorf("error replacing file %s: %v", fileName, err)
+	}
+	return nil
+}
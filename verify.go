@@ -0,0 +1,74 @@
+package mtr
+
+import (
+	"fmt"
+
+	"github.com/n-ct/ct-monitor/signature"
+)
+
+// VerifySTHSignature checks that the TreeHeadSignature of the given STH was signed by the log's public key.
+// logKey is the base64 encoded DER public key found in the log list
+func VerifySTHSignature(sth *SignedTreeHeadData, logKey string) error {
+	if err := signature.VerifySignature(logKey, sth.TreeHeadData, sth.Signature); err != nil {
+		return fmt.Errorf("invalid STH signature from Logger %s: %w", sth.LogID, err)
+	}
+	return nil
+}
+
+// VerifySTHConsistency checks that the ConsistencyProof links the root hashes of the two STHs
+// sth1 and sth2 must have the tree sizes TreeSize1 and TreeSize2 of the ConsistencyProof
+func VerifySTHConsistency(sth1, sth2 *SignedTreeHeadData, poc *ConsistencyProofData) error {
+	if sth1.TreeHeadData.TreeSize != poc.TreeSize1 || sth2.TreeHeadData.TreeSize != poc.TreeSize2 {
+		return fmt.Errorf("STH tree sizes (%d, %d) don't match ConsistencyProof tree sizes (%d, %d)", sth1.TreeHeadData.TreeSize, sth2.TreeHeadData.TreeSize, poc.TreeSize1, poc.TreeSize2)
+	}
+	root1 := sth1.TreeHeadData.SHA256RootHash[:]
+	root2 := sth2.TreeHeadData.SHA256RootHash[:]
+	if err := VerifyConsistencyProof(poc.TreeSize1, poc.TreeSize2, root1, root2, poc.ConsistencyPath); err != nil {
+		return fmt.Errorf("invalid ConsistencyProof from Logger %s: %w", poc.LogID, err)
+	}
+	return nil
+}
+
+// VerifySTHsMonotonic checks that the tree of a log only grows between two of its STHs.
+// sth1 must have an earlier timestamp than sth2. A smaller tree size at the later timestamp, which is also the timestamp of a
+// larger tree going backwards, or the same tree size with a different root hash means the log rolled back its tree
+func VerifySTHsMonotonic(sth1, sth2 *SignedTreeHeadData) error {
+	size1, size2 := sth1.TreeHeadData.TreeSize, sth2.TreeHeadData.TreeSize
+	if size2 < size1 {
+		return fmt.Errorf("tree size of Logger %s decreased from %d at timestamp %d to %d at timestamp %d", sth2.LogID, size1, sth1.TreeHeadData.Timestamp, size2, sth2.TreeHeadData.Timestamp)
+	}
+	if size1 == size2 && sth1.TreeHeadData.SHA256RootHash != sth2.TreeHeadData.SHA256RootHash {
+		return fmt.Errorf("root hash of Logger %s changed at tree size %d between timestamps %d and %d", sth2.LogID, size1, sth1.TreeHeadData.Timestamp, sth2.TreeHeadData.Timestamp)
+	}
+	return nil
+}
+
+// VerifySTHInclusion checks that the InclusionProof shows the leaf with leafHash is included in the tree of the STH
+func VerifySTHInclusion(sth *SignedTreeHeadData, leafHash []byte, poi *InclusionProofData) error {
+	if sth.TreeHeadData.TreeSize != poi.TreeSize {
+		return fmt.Errorf("STH tree size (%d) doesn't match InclusionProof tree size (%d)", sth.TreeHeadData.TreeSize, poi.TreeSize)
+	}
+	root := sth.TreeHeadData.SHA256RootHash[:]
+	if err := VerifyInclusionProof(poi.LeadIndex, poi.TreeSize, leafHash, root, poi.InclusionPath); err != nil {
+		return fmt.Errorf("invalid InclusionProof from Logger %s: %w", poi.LogID, err)
+	}
+	return nil
+}
+
+// VerifyAlertSignature checks that the Alert was signed by the monitor with the given public key.
+// monitorKey is the base64 encoded DER public key found in the monitor list
+func VerifyAlertSignature(alert *Alert, monitorKey string) error {
+	if err := signature.VerifySignature(monitorKey, alert.TBS, alert.Signature); err != nil {
+		return fmt.Errorf("invalid Alert signature from monitor %s: %w", alert.TBS.Signer, err)
+	}
+	return nil
+}
+
+// VerifySRDSignature checks that the RevocationDigest of the given SRD was signed by the CA's public key.
+// caKey is the base64 encoded DER public key found in the CA list
+func VerifySRDSignature(srd *SignedRevocationDigest, caKey string) error {
+	if err := signature.VerifySignature(caKey, srd.RevDigest, srd.Signature); err != nil {
+		return fmt.Errorf("invalid SRD signature from CA %s: %w", srd.EntityID, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package mtr
+
+import (
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+var (
+	testValidECDSAPubKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE2HQc8jcuoOj/H/4+HQItNBEolurr547rC5i4O61Wf0mxvV9anHz+kIcTy7n9hnStoK+WGkI3fF6k7l2IO3OiyA=="
+	testOtherECDSAPubKey = "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEmFk6QT48Ts4oxSkBPM4mQ/mnWICKVmZUP6urQVBH0vhDzJVYHc2ShvF2KjWzorVu2C+tY6lIU+61iiPLsGvZXw=="
+)
+
+func mustCreateSignedSTH(t *testing.T, treeSize uint64, timestamp uint64) *SignedTreeHeadData {
+	t.Helper()
+	signer, err := mustCreateSigner(t, testValidECDSAPrivKey)
+	if err != nil {
+		t.Fatalf("failed to create signer to sign STH: %v", err)
+	}
+	treeHead := ct.TreeHeadSignature{
+		Version:       ct.V1,
+		SignatureType: ct.TreeHashSignatureType,
+		Timestamp:     timestamp,
+		TreeSize:      treeSize,
+	}
+	sig, err := signer.CreateSignature(tls.SHA256, treeHead)
+	if err != nil {
+		t.Fatalf("failed to sign STH: %v", err)
+	}
+	return &SignedTreeHeadData{"testlog", treeHead, *sig}
+}
+
+func TestVerifySTHSignature(t *testing.T) {
+	sth := mustCreateSignedSTH(t, 10, 1000)
+	if err := VerifySTHSignature(sth, testValidECDSAPubKey); err != nil {
+		t.Fatalf("failed to verify validly signed STH: %v", err)
+	}
+}
+
+func TestVerifySTHSignatureInvalid(t *testing.T) {
+	wrongKeySTH := mustCreateSignedSTH(t, 10, 1000)
+	if err := VerifySTHSignature(wrongKeySTH, testOtherECDSAPubKey); err == nil {
+		t.Fatalf("verified STH against the wrong log key")
+	}
+
+	modifiedSTH := mustCreateSignedSTH(t, 10, 1000)
+	modifiedSTH.TreeHeadData.TreeSize = 11
+	if err := VerifySTHSignature(modifiedSTH, testValidECDSAPubKey); err == nil {
+		t.Fatalf("verified STH whose tree size was modified after signing")
+	}
+}
+
+func TestVerifySTHsMonotonic(t *testing.T) {
+	changedRoot := mustCreateSignedSTH(t, 10, 2000)
+	changedRoot.TreeHeadData.SHA256RootHash[0] = 1
+	tests := []struct {
+		sth1 		*SignedTreeHeadData
+		sth2 		*SignedTreeHeadData
+		monotonic 	bool
+	}{
+		{mustCreateSignedSTH(t, 10, 1000), mustCreateSignedSTH(t, 20, 2000), true},
+		{mustCreateSignedSTH(t, 10, 1000), mustCreateSignedSTH(t, 10, 2000), true},
+		{mustCreateSignedSTH(t, 10, 1000), mustCreateSignedSTH(t, 5, 2000), false},
+		{mustCreateSignedSTH(t, 10, 1000), changedRoot, false},
+	}
+	for _, test := range tests {
+		err := VerifySTHsMonotonic(test.sth1, test.sth2)
+		if (err == nil) != test.monotonic {
+			t.Fatalf("tree size %d at %d to %d at %d: got error %v, expected monotonic %v", test.sth1.TreeHeadData.TreeSize, test.sth1.TreeHeadData.Timestamp, test.sth2.TreeHeadData.TreeSize, test.sth2.TreeHeadData.Timestamp, err, test.monotonic)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	mtr "github.com/n-ct/ct-monitor"
+	"github.com/n-ct/ct-monitor/utils"
+)
+
+// Watchlist holds the domains Relying Parties have asked the monitor to watch and the log entries found for them
+// When created with a path, every change is written to the file so subscriptions and matches survive restarts
+type Watchlist struct {
+	mu sync.RWMutex
+	path string
+	domains map[string]bool
+	matches map[string][]mtr.DomainMatch	// Matches keyed by watched domain
+	seen map[matchKey]bool
+}
+
+// Identifies a match so rescanning the same entries doesn't record it twice
+type matchKey struct {
+	domain string
+	logID string
+	leafIndex uint64
+}
+
+// Contents of the watchlist file
+type watchlistFile struct {
+	Domains []string
+	Matches []mtr.DomainMatch
+}
+
+// Create a Watchlist persisted to the file at path, loading the file if it exists
+// An empty path creates a Watchlist kept only in memory
+func NewWatchlist(path string) (*Watchlist, error) {
+	w := &Watchlist{
+		path: path,
+		domains: make(map[string]bool),
+		matches: make(map[string][]mtr.DomainMatch),
+		seen: make(map[matchKey]bool),
+	}
+	if path == "" {
+		return w, nil
+	}
+	byteData, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening watchlist file %s: %w", path, err)
+	}
+	var contents watchlistFile
+	if err := json.Unmarshal(byteData, &contents); err != nil {
+		return nil, fmt.Errorf("error loading watchlist file %s: %w", path, err)
+	}
+	for _, domain := range contents.Domains {
+		w.domains[domain] = true
+	}
+	for _, match := range contents.Matches {
+		w.addMatch(match)
+	}
+	return w, nil
+}
+
+// Add the domain to the watchlist and return its normalized form
+func (w *Watchlist) AddDomain(domain string) (string, error) {
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return "", err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.domains[domain] {
+		return domain, nil
+	}
+	w.domains[domain] = true
+	if err := w.save(); err != nil {
+		delete(w.domains, domain)
+		return "", err
+	}
+	return domain, nil
+}
+
+// Get every watched domain in sorted order
+func (w *Watchlist) Domains() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	domains := make([]string, 0, len(w.domains))
+	for domain := range w.domains {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Get the watched domains that any of the given certificate names match
+func (w *Watchlist) MatchNames(names []string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var matched []string
+	for domain := range w.domains {
+		for _, name := range names {
+			if MatchesDomain(domain, name) {
+				matched = append(matched, domain)
+				break
+			}
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// Record the matches, ignoring matches that were already recorded
+func (w *Watchlist) AddMatches(matches []mtr.DomainMatch) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	added := false
+	for _, match := range matches {
+		added = w.addMatch(match) || added
+	}
+	if !added {
+		return nil
+	}
+	return w.save()
+}
+
+// Record the match in memory. Returns false if it was already recorded
+func (w *Watchlist) addMatch(match mtr.DomainMatch) bool {
+	key := matchKey{match.Domain, match.LogID, match.LeafIndex}
+	if w.seen[key] {
+		return false
+	}
+	w.seen[key] = true
+	w.matches[match.Domain] = append(w.matches[match.Domain], match)
+	return true
+}
+
+// Get the recorded matches of the watched domain
+func (w *Watchlist) GetMatches(domain string) []mtr.DomainMatch {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	matches := make([]mtr.DomainMatch, len(w.matches[domain]))
+	copy(matches, w.matches[domain])
+	return matches
+}
+
+// Write the watchlist to its file
+// Must be called with the write lock held
+func (w *Watchlist) save() error {
+	if w.path == "" {
+		return nil
+	}
+	contents := watchlistFile{Domains: make([]string, 0, len(w.domains))}
+	for domain := range w.domains {
+		contents.Domains = append(contents.Domains, domain)
+	}
+	sort.Strings(contents.Domains)
+	for _, domain := range contents.Domains {
+		contents.Matches = append(contents.Matches, w.matches[domain]...)
+	}
+	byteData, err := json.Marshal(contents)
+	if err != nil {
+		return fmt.Errorf("failed to marshal watchlist: %w", err)
+	}
+	if err := utils.WriteFileAtomic(w.path, byteData); err != nil {
+		return fmt.Errorf("failed to save watchlist: %w", err)
+	}
+	return nil
+}
+
+// Lowercase the domain and strip any trailing dot
+// Returns an error if the domain isn't a domain name or a wildcard of the form *.example.com
+func NormalizeDomain(domain string) (string, error) {
+	normalized := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	labels := strings.Split(strings.TrimPrefix(normalized, "*."), ".")
+	for _, label := range labels {
+		if label == "" || strings.ContainsAny(label, "* /:") {
+			return "", fmt.Errorf("invalid domain %q", domain)
+		}
+	}
+	return normalized, nil
+}
+
+// Check whether the certificate name matches the watched domain
+// A watched wildcard *.example.com matches every name below example.com
+// A watched exact domain matches the same name, or a wildcard certificate name that covers it
+func MatchesDomain(watched string, name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if strings.HasPrefix(watched, "*.") {
+		return strings.HasSuffix(name, watched[1:])
+	}
+	if name == watched {
+		return true
+	}
+
+	// Wildcard certificate names only cover a single label
+	if strings.HasPrefix(name, "*.") {
+		dot := strings.Index(watched, ".")
+		return dot > 0 && watched[dot:] == name[1:]
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package watchlist
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	mtr "github.com/n-ct/ct-monitor"
+)
+
+func mustCreateWatchlist(t *testing.T, path string) *Watchlist {
+	t.Helper()
+	w, err := NewWatchlist(path)
+	if err != nil {
+		t.Fatalf("failed to create Watchlist at %s: %v", path, err)
+	}
+	return w
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		domain 		string
+		expected 	string
+		valid 		bool
+	}{
+		{"Example.COM", "example.com", true},
+		{" www.example.com. ", "www.example.com", true},
+		{"*.example.com", "*.example.com", true},
+		{"", "", false},
+		{"*", "", false},
+		{"www.*.example.com", "", false},
+		{"example..com", "", false},
+		{"https://example.com", "", false},
+	}
+	for _, test := range tests {
+		normalized, err := NormalizeDomain(test.domain)
+		if test.valid != (err == nil) {
+			t.Errorf("NormalizeDomain(%q) returned error %v, expected valid %v", test.domain, err, test.valid)
+			continue
+		}
+		if normalized != test.expected {
+			t.Errorf("NormalizeDomain(%q) is %q, expected %q", test.domain, normalized, test.expected)
+		}
+	}
+}
+
+func TestMatchesDomain(t *testing.T) {
+	tests := []struct {
+		watched 	string
+		name 		string
+		expected 	bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.com.", true},
+		{"example.com", "www.example.com", false},
+		{"www.example.com", "*.example.com", true},
+		{"a.www.example.com", "*.example.com", false},
+		{"example.com", "*.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "*.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+	}
+	for _, test := range tests {
+		if matches := MatchesDomain(test.watched, test.name); matches != test.expected {
+			t.Errorf("MatchesDomain(%q, %q) is %v, expected %v", test.watched, test.name, matches, test.expected)
+		}
+	}
+}
+
+func TestWatchlistPersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchlist")
+	w := mustCreateWatchlist(t, path)
+	for _, domain := range []string{"*.example.com", "Example.org"} {
+		if _, err := w.AddDomain(domain); err != nil {
+			t.Fatalf("failed to add domain %s: %v", domain, err)
+		}
+	}
+	match := mtr.DomainMatch{Domain: "example.org", LogID: "testlog", LeafIndex: 3, Names: []string{"example.org"}}
+	if err := w.AddMatches([]mtr.DomainMatch{match, match}); err != nil {
+		t.Fatalf("failed to add matches: %v", err)
+	}
+
+	reopened := mustCreateWatchlist(t, path)
+	expectedDomains := []string{"*.example.com", "example.org"}
+	if domains := reopened.Domains(); !reflect.DeepEqual(domains, expectedDomains) {
+		t.Fatalf("reloaded domains (%v) don't match added domains (%v)", domains, expectedDomains)
+	}
+	if matches := reopened.GetMatches("example.org"); !reflect.DeepEqual(matches, []mtr.DomainMatch{match}) {
+		t.Fatalf("reloaded matches (%v) don't match the single added match (%v)", matches, match)
+	}
+	if matches := reopened.MatchNames([]string{"www.example.com", "example.net"}); !reflect.DeepEqual(matches, []string{"*.example.com"}) {
+		t.Fatalf("MatchNames returned %v, expected [*.example.com]", matches)
+	}
+}